@@ -0,0 +1,24 @@
+package args
+
+import "fmt"
+
+// BwLimit is a maximum transfer rate, as used by --bwlimit in rsync.
+//
+// See parseSize for the accepted forms; without a unit, the rate is in units
+// of 1024 bytes per second. A rate of 0 means no limit.
+type BwLimit string
+
+// Validate returns an error if the rate is invalid.
+func (b BwLimit) Validate() error {
+	if _, ok := parseSize(string(b), "k"); !ok && b != "" {
+		return fmt.Errorf("invalid rate '%s' for --bwlimit", b)
+	}
+	return nil
+}
+
+// Bytes returns the rate in bytes per second, or 0 if there is no limit.
+// The rate must be valid.
+func (b BwLimit) Bytes() int64 {
+	out, _ := parseSize(string(b), "k")
+	return out
+}
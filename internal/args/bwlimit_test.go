@@ -0,0 +1,48 @@
+package args
+
+import "testing"
+
+func TestBwLimit(t *testing.T) {
+	tests := []struct {
+		input    BwLimit
+		expected int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"100", 102400},
+		{"1.5", 1536},
+		{"2k", 2048},
+		{"2KiB", 2048},
+		{"2KB", 2000},
+		{"1m", 1048576},
+		{"1MB", 1000000},
+		{"1G", 1073741824},
+		{"512b", 512},
+		{"512B", 512},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.input), func(t *testing.T) {
+			if err := tt.input.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := tt.input.Bytes(); got != tt.expected {
+				t.Errorf("Bytes() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBwLimitInvalid(t *testing.T) {
+	for _, input := range []BwLimit{"x", "-1", "10q", "1kk", "k", "1.2.3"} {
+		t.Run(string(input), func(t *testing.T) {
+			err := input.Validate()
+			if err == nil {
+				t.Fatal("unexpectedly accepted")
+			}
+			if err.Error() != "invalid rate '"+string(input)+"' for --bwlimit" {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,489 @@
+package args
+
+import (
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// optionKind describes the syntax of an rsync option.
+type optionKind int
+
+const (
+	// An option which takes no value.
+	optionFlag optionKind = iota
+
+	// An option which takes no value and may be turned off by --no-OPTION,
+	// e.g. --no-perms or --no-p.
+	optionNegatable
+
+	// An option which requires a value.
+	optionValue
+)
+
+// rsyncOption is a single option in rsync's grammar.
+type rsyncOption struct {
+	long  string
+	short byte
+	kind  optionKind
+}
+
+// rsyncOptions contains every option accepted by rsync for a client transfer.
+// Options D, F and P exist only in short form.
+var rsyncOptions = []rsyncOption{
+	{"verbose", 'v', optionNegatable},
+	{"info", 0, optionValue},
+	{"debug", 0, optionValue},
+	{"stderr", 0, optionValue},
+	{"quiet", 'q', optionFlag},
+	{"no-motd", 0, optionFlag},
+	{"checksum", 'c', optionNegatable},
+	{"archive", 'a', optionFlag},
+	{"recursive", 'r', optionNegatable},
+	{"relative", 'R', optionNegatable},
+	{"implied-dirs", 0, optionNegatable},
+	{"backup", 'b', optionNegatable},
+	{"backup-dir", 0, optionValue},
+	{"suffix", 0, optionValue},
+	{"update", 'u', optionFlag},
+	{"inplace", 0, optionNegatable},
+	{"append", 0, optionFlag},
+	{"append-verify", 0, optionFlag},
+	{"dirs", 'd', optionNegatable},
+	{"old-dirs", 0, optionFlag},
+	{"mkpath", 0, optionNegatable},
+	{"links", 'l', optionNegatable},
+	{"copy-links", 'L', optionFlag},
+	{"copy-unsafe-links", 0, optionFlag},
+	{"safe-links", 0, optionFlag},
+	{"munge-links", 0, optionNegatable},
+	{"copy-dirlinks", 'k', optionFlag},
+	{"keep-dirlinks", 'K', optionFlag},
+	{"hard-links", 'H', optionNegatable},
+	{"perms", 'p', optionNegatable},
+	{"executability", 'E', optionFlag},
+	{"chmod", 0, optionValue},
+	{"acls", 'A', optionNegatable},
+	{"xattrs", 'X', optionNegatable},
+	{"owner", 'o', optionNegatable},
+	{"group", 'g', optionNegatable},
+	{"devices", 0, optionNegatable},
+	{"copy-devices", 0, optionFlag},
+	{"write-devices", 0, optionNegatable},
+	{"specials", 0, optionNegatable},
+	{"", 'D', optionNegatable},
+	{"times", 't', optionNegatable},
+	{"atimes", 'U', optionNegatable},
+	{"open-noatime", 0, optionNegatable},
+	{"crtimes", 'N', optionNegatable},
+	{"omit-dir-times", 'O', optionNegatable},
+	{"omit-link-times", 'J', optionNegatable},
+	{"super", 0, optionNegatable},
+	{"fake-super", 0, optionFlag},
+	{"sparse", 'S', optionNegatable},
+	{"preallocate", 0, optionFlag},
+	{"write-batch", 0, optionValue},
+	{"only-write-batch", 0, optionValue},
+	{"read-batch", 0, optionValue},
+	{"protocol", 0, optionValue},
+	{"iconv", 0, optionValue},
+	{"checksum-seed", 0, optionValue},
+	{"checksum-choice", 0, optionValue},
+	{"block-size", 'B', optionValue},
+	{"rsh", 'e', optionValue},
+	{"rsync-path", 0, optionValue},
+	{"dry-run", 'n', optionFlag},
+	{"whole-file", 'W', optionNegatable},
+	{"one-file-system", 'x', optionNegatable},
+	{"existing", 0, optionFlag},
+	{"ignore-existing", 0, optionFlag},
+	{"remove-source-files", 0, optionFlag},
+	{"del", 0, optionFlag},
+	{"delete", 0, optionFlag},
+	{"delete-before", 0, optionFlag},
+	{"delete-during", 0, optionFlag},
+	{"delete-delay", 0, optionFlag},
+	{"delete-after", 0, optionFlag},
+	{"delete-excluded", 0, optionFlag},
+	{"ignore-missing-args", 0, optionFlag},
+	{"delete-missing-args", 0, optionFlag},
+	{"ignore-errors", 0, optionFlag},
+	{"force", 0, optionNegatable},
+	{"max-delete", 0, optionValue},
+	{"max-size", 0, optionValue},
+	{"min-size", 0, optionValue},
+	{"max-alloc", 0, optionValue},
+	{"partial", 0, optionNegatable},
+	{"partial-dir", 0, optionValue},
+	{"delay-updates", 0, optionFlag},
+	{"prune-empty-dirs", 'm', optionNegatable},
+	{"numeric-ids", 0, optionNegatable},
+	{"usermap", 0, optionValue},
+	{"groupmap", 0, optionValue},
+	{"chown", 0, optionValue},
+	{"timeout", 0, optionValue},
+	{"contimeout", 0, optionValue},
+	{"ignore-times", 'I', optionFlag},
+	{"size-only", 0, optionFlag},
+	{"modify-window", '@', optionValue},
+	{"temp-dir", 'T', optionValue},
+	{"fuzzy", 'y', optionNegatable},
+	{"compare-dest", 0, optionValue},
+	{"copy-dest", 0, optionValue},
+	{"link-dest", 0, optionValue},
+	{"compress", 'z', optionNegatable},
+	{"compress-choice", 0, optionValue},
+	{"compress-level", 0, optionValue},
+	{"skip-compress", 0, optionValue},
+	{"cvs-exclude", 'C', optionFlag},
+	{"filter", 'f', optionValue},
+	{"", 'F', optionFlag},
+	{"exclude", 0, optionValue},
+	{"exclude-from", 0, optionValue},
+	{"include", 0, optionValue},
+	{"include-from", 0, optionValue},
+	{"files-from", 0, optionValue},
+	{"from0", '0', optionNegatable},
+	{"old-args", 0, optionNegatable},
+	{"secluded-args", 's', optionNegatable},
+	{"trust-sender", 0, optionFlag},
+	{"copy-as", 0, optionValue},
+	{"address", 0, optionValue},
+	{"port", 0, optionValue},
+	{"sockopts", 0, optionValue},
+	{"blocking-io", 0, optionNegatable},
+	{"outbuf", 0, optionValue},
+	{"stats", 0, optionFlag},
+	{"8-bit-output", '8', optionNegatable},
+	{"human-readable", 'h', optionNegatable},
+	{"progress", 0, optionNegatable},
+	{"", 'P', optionFlag},
+	{"itemize-changes", 'i', optionNegatable},
+	{"remote-option", 'M', optionValue},
+	{"out-format", 0, optionValue},
+	{"log-file", 0, optionValue},
+	{"log-file-format", 0, optionValue},
+	{"password-file", 0, optionValue},
+	{"early-input", 0, optionValue},
+	{"list-only", 0, optionFlag},
+	{"bwlimit", 0, optionValue},
+	{"stop-after", 0, optionValue},
+	{"stop-at", 0, optionValue},
+	{"inc-recursive", 0, optionNegatable},
+	{"msgs2stderr", 0, optionNegatable},
+	{"ipv4", '4', optionFlag},
+	{"ipv6", '6', optionFlag},
+}
+
+// rsyncAliases maps alternative names of rsync options onto the names used
+// in rsyncOptions.
+var rsyncAliases = map[string]string{
+	"i-d":                 "implied-dirs",
+	"old-d":               "old-dirs",
+	"ignore-non-existing": "existing",
+	"cc":                  "checksum-choice",
+	"zc":                  "compress-choice",
+	"zl":                  "compress-level",
+	"protect-args":        "secluded-args",
+	"log-format":          "out-format",
+	"remove-sent-files":   "remove-source-files",
+	"i-r":                 "inc-recursive",
+}
+
+// rsyncImplied maps options onto another option which they imply, and which
+// is handled by kong.
+var rsyncImplied = map[string]string{
+	"del":           "delete",
+	"delete-before": "delete",
+	"delete-during": "delete",
+	"delete-delay":  "delete",
+	"delete-after":  "delete",
+}
+
+// rsyncFilterFiles maps options reading filter patterns from a file onto the
+// equivalent filter rule, so that the patterns are evaluated in order with
+// all other filter rules.
+var rsyncFilterFiles = map[string]string{
+	"exclude-from": "merge,- ",
+	"include-from": "merge,+ ",
+}
+
+// name returns the preferred name of the option, without leading dashes.
+func (o *rsyncOption) name() string {
+	if o.long != "" {
+		return o.long
+	}
+	return string(o.short)
+}
+
+func lookupLong(name string) *rsyncOption {
+	if name == "" {
+		return nil
+	}
+	if alias, ok := rsyncAliases[name]; ok {
+		name = alias
+	}
+	for i := range rsyncOptions {
+		if rsyncOptions[i].long == name {
+			return &rsyncOptions[i]
+		}
+	}
+	return nil
+}
+
+func lookupShort(short byte) *rsyncOption {
+	for i := range rsyncOptions {
+		if rsyncOptions[i].short == short {
+			return &rsyncOptions[i]
+		}
+	}
+	return nil
+}
+
+// lookupNegated returns the option turned off by --no-<name>, where name may
+// be either the long or short name of the option.
+func lookupNegated(name string) *rsyncOption {
+	opt := lookupLong(name)
+	if opt == nil && len(name) == 1 {
+		opt = lookupShort(name[0])
+	}
+	if opt == nil || opt.kind != optionNegatable {
+		return nil
+	}
+	return opt
+}
+
+// argToken is a single argument found by compatArgs, possibly spanning more
+// than one command-line argument.
+type argToken struct {
+	// The rsync option used, or nil for positional arguments and anything
+	// not recognized, which are left to kong.
+	opt *rsyncOption
+
+	// Original arguments, used if opt is nil.
+	raw []string
+
+	// Name of the option as given, if given in long form. Aliases are
+	// retained, as not every version of rsync accepts every name.
+	name string
+
+	value   string
+	negated bool
+}
+
+// compatArgs translates command-line arguments in the full syntax accepted by
+// rsync into arguments for kong, with each option spelled out in long form
+// (--option or --option=value). This includes:
+//
+//   - clusters of short options, e.g. -avzP or -essh
+//   - aliases, e.g. --protect-args for --secluded-args
+//   - negation of options, e.g. -a --no-perms, with the last of an option and
+//     its negation taking effect
+//   - --exclude-from and --include-from, as the equivalent --filter
+//   - --del and --delete-WHEN, which imply --delete
+//
+// Options accepted by rsync but unknown to kong are returned separately, in
+// the order given, for passing through to rsync.
+func compatArgs(args []string, flags []*kong.Flag) ([]string, []string) {
+	// As in rsync, -h alone requests help rather than --human-readable.
+	if len(args) == 1 && args[0] == "-h" {
+		return []string{"--help"}, nil
+	}
+
+	tokens := resolveNegations(tokenizeArgs(args))
+
+	kongArgs := []string{}
+	var other []string
+
+	for _, tok := range tokens {
+		if tok.opt == nil {
+			kongArgs = append(kongArgs, tok.raw...)
+			continue
+		}
+
+		if rule, ok := rsyncFilterFiles[tok.opt.long]; ok {
+			kongArgs = append(kongArgs, "--filter="+rule+tok.value)
+			continue
+		}
+
+		flag := kongFlag(flags, tok)
+
+		name := tok.name
+		if name == "" {
+			name = tok.opt.name()
+		}
+
+		var arg string
+		switch {
+		case flag != nil:
+			arg = "--" + flag.Name
+		case tok.negated:
+			arg = "--no-" + name
+		default:
+			arg = "--" + name
+		}
+		if tok.opt.kind == optionValue {
+			arg += "=" + tok.value
+		}
+
+		if flag != nil {
+			kongArgs = append(kongArgs, arg)
+		} else {
+			other = append(other, arg)
+		}
+
+		if implied, ok := rsyncImplied[tok.opt.long]; ok && !tok.negated {
+			kongArgs = append(kongArgs, "--"+implied)
+		}
+	}
+
+	return kongArgs, other
+}
+
+// kongFlag returns the kong flag which handles the given token, if any.
+// Flags are matched by long name, or otherwise by short name.
+func kongFlag(flags []*kong.Flag, tok argToken) *kong.Flag {
+	if tok.negated {
+		// Negations are only known to kong if there's an explicit flag,
+		// such as --no-relative.
+		return findFlag(flags, func(flag *kong.Flag) bool {
+			return flag.Name == "no-"+tok.opt.long
+		})
+	}
+
+	if flag := findFlag(flags, func(flag *kong.Flag) bool {
+		return flag.Name == tok.opt.long
+	}); flag != nil {
+		return flag
+	}
+
+	return findFlag(flags, func(flag *kong.Flag) bool {
+		return tok.opt.short != 0 && flag.Short == rune(tok.opt.short)
+	})
+}
+
+func findFlag(flags []*kong.Flag, match func(*kong.Flag) bool) *kong.Flag {
+	for _, flag := range flags {
+		if match(flag) {
+			return flag
+		}
+	}
+	return nil
+}
+
+func tokenizeArgs(args []string) []argToken {
+	var out []argToken
+
+	// Consumes the value of an option from the next argument.
+	nextValue := func(i *int) (string, bool) {
+		if *i+1 >= len(args) {
+			return "", false
+		}
+		*i++
+		return args[*i], true
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			out = append(out, argToken{raw: args[i:]})
+			return out
+
+		case strings.HasPrefix(arg, "--"):
+			name, value := arg[2:], ""
+			hasValue := false
+			if idx := strings.Index(name, "="); idx != -1 {
+				name, value, hasValue = name[:idx], name[idx+1:], true
+			}
+
+			opt := lookupLong(name)
+			negated := false
+			if opt == nil && strings.HasPrefix(name, "no-") {
+				name = strings.TrimPrefix(name, "no-")
+				opt = lookupNegated(name)
+				negated = opt != nil
+			}
+
+			switch {
+			case opt != nil && opt.kind == optionValue && !negated:
+				if !hasValue {
+					var ok bool
+					if value, ok = nextValue(&i); !ok {
+						// Missing value; let kong complain.
+						out = append(out, argToken{raw: []string{arg}})
+						continue
+					}
+				}
+				out = append(out, argToken{opt: opt, name: name, value: value})
+			case opt != nil && !hasValue:
+				out = append(out, argToken{opt: opt, name: name, negated: negated})
+			default:
+				// Unknown, or a value for an option which takes none;
+				// let kong handle it.
+				out = append(out, argToken{raw: []string{arg}})
+			}
+
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j := 1; j < len(arg); j++ {
+				opt := lookupShort(arg[j])
+				if opt == nil {
+					// Unknown; let kong complain.
+					out = append(out, argToken{raw: []string{"-" + arg[j:]}})
+					break
+				}
+				if opt.kind != optionValue {
+					out = append(out, argToken{opt: opt})
+					continue
+				}
+				// The value is the rest of this argument, or else the next.
+				value := arg[j+1:]
+				if value == "" {
+					var ok bool
+					if value, ok = nextValue(&i); !ok {
+						out = append(out, argToken{raw: []string{"-" + string(arg[j])}})
+						break
+					}
+				}
+				out = append(out, argToken{opt: opt, value: value})
+				break
+			}
+
+		default:
+			out = append(out, argToken{raw: []string{arg}})
+		}
+	}
+
+	return out
+}
+
+// resolveNegations applies each --no-OPTION to any earlier usage of OPTION,
+// which is removed. The negation itself is retained only if OPTION isn't
+// used again later.
+func resolveNegations(tokens []argToken) []argToken {
+	out := []argToken{}
+
+	for _, tok := range tokens {
+		if tok.opt != nil && tok.negated {
+			kept := out[:0]
+			for _, prev := range out {
+				if prev.opt != tok.opt {
+					kept = append(kept, prev)
+				}
+			}
+			out = kept
+		} else if tok.opt != nil {
+			kept := out[:0]
+			for _, prev := range out {
+				if prev.opt != tok.opt || !prev.negated {
+					kept = append(kept, prev)
+				}
+			}
+			out = kept
+		}
+		out = append(out, tok)
+	}
+
+	return out
+}
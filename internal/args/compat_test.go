@@ -0,0 +1,66 @@
+package args
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+func TestCompatArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantKong  []string
+		wantOther []string
+	}{
+		{"help", []string{"-h"}, []string{"--help"}, nil},
+		{"human-readable", []string{"-h", "x", "y"}, []string{"--human-readable", "x", "y"}, nil},
+		{"clusters",
+			[]string{"-vrf- *.tmp", "-tB", "512", "x", "y"},
+			[]string{"--verbose", "--recursive", "--filter=- *.tmp", "--times", "x", "y"},
+			[]string{"--block-size=512"}},
+		{"aliases",
+			[]string{"--log-format=%n", "--old-d", "--remove-sent-files", "x", "y"},
+			[]string{"--out-format=%n", "--remove-source-files", "x", "y"},
+			[]string{"--old-d"}},
+		{"negation of short-only option",
+			[]string{"-aD", "--no-D", "x", "y"},
+			[]string{"--archive", "x", "y"},
+			[]string{"--no-D"}},
+		{"option after negation",
+			[]string{"--no-c", "-c", "--no-relative", "x", "y"},
+			[]string{"--no-relative", "x", "y"},
+			[]string{"--checksum"}},
+		{"end of options",
+			[]string{"-v", "--", "-x", "y"},
+			[]string{"--verbose", "--", "-x", "y"},
+			nil},
+		{"missing values",
+			[]string{"x", "y", "-B"},
+			[]string{"x", "y", "-B"},
+			nil},
+		{"exodus options",
+			[]string{"--exodus-conf", "my.conf", "x", "y"},
+			[]string{"--exodus-conf", "my.conf", "x", "y"},
+			nil},
+	}
+
+	out := Config{}
+	parser, err := kong.New(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotKong, gotOther := compatArgs(tt.args, parser.Model.Flags)
+			if !reflect.DeepEqual(gotKong, tt.wantKong) {
+				t.Errorf("kong args = %q, want %q", gotKong, tt.wantKong)
+			}
+			if !reflect.DeepEqual(gotOther, tt.wantOther) {
+				t.Errorf("other args = %q, want %q", gotOther, tt.wantOther)
+			}
+		})
+	}
+}
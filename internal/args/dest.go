@@ -0,0 +1,111 @@
+package args
+
+import (
+	"net"
+	"strings"
+)
+
+// Destination is a DEST argument broken into its components.
+type Destination struct {
+	// User to connect as, if any.
+	User string
+
+	// Remote host, or empty for a local destination. An IPv6 address is
+	// given without brackets.
+	Host string
+
+	// Port of an rsync daemon, only set for rsync:// destinations.
+	Port string
+
+	// True for destinations naming an rsync daemon, i.e. host::module/path
+	// or rsync://host/module/path.
+	Daemon bool
+
+	// Module on the rsync daemon; only set if Daemon is true.
+	Module string
+
+	// Path on the remote host, or within the module for daemon destinations.
+	Path string
+}
+
+// ParseDest parses a DEST argument in any of the forms accepted by rsync:
+//
+//	[USER@]HOST:DEST
+//	[USER@]HOST::MODULE/DEST
+//	rsync://[USER@]HOST[:PORT]/MODULE/DEST
+//
+// An IPv6 address as HOST must be in brackets, as in "[::1]:DEST". A DEST
+// without any host is returned as a local destination.
+func ParseDest(dest string) Destination {
+	out := Destination{}
+
+	if strings.HasPrefix(dest, "rsync://") {
+		hostPort := strings.TrimPrefix(dest, "rsync://")
+		modulePath := ""
+		if i := strings.Index(hostPort, "/"); i != -1 {
+			hostPort, modulePath = hostPort[:i], hostPort[i+1:]
+		}
+		out.User, hostPort = splitUser(hostPort)
+		out.Host, out.Port = splitHostPort(hostPort)
+		out.Daemon = true
+		out.Module, out.Path = splitModule(modulePath)
+		return out
+	}
+
+	i := hostEnd(dest)
+	if i == -1 {
+		out.Path = dest
+		return out
+	}
+
+	out.User, out.Host = splitUser(dest[:i])
+	out.Host = strings.TrimSuffix(strings.TrimPrefix(out.Host, "["), "]")
+	out.Path = dest[i+1:]
+
+	if strings.HasPrefix(out.Path, ":") {
+		out.Daemon = true
+		out.Module, out.Path = splitModule(out.Path[1:])
+	}
+
+	return out
+}
+
+// hostEnd returns the index of the ":" following the host in dest, or -1 if
+// there's no host. Colons within brackets are part of an IPv6 address.
+func hostEnd(dest string) int {
+	inBrackets := false
+	for i, c := range dest {
+		switch {
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case c == ':' && !inBrackets:
+			return i
+		}
+	}
+	return -1
+}
+
+// splitHostPort splits HOST[:PORT], where an IPv6 HOST is in brackets.
+func splitHostPort(s string) (string, string) {
+	if host, port, err := net.SplitHostPort(s); err == nil {
+		return host, port
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), ""
+}
+
+func splitUser(s string) (string, string) {
+	if i := strings.LastIndex(s, "@"); i != -1 {
+		return s[:i], s[i+1:]
+	}
+	return "", s
+}
+
+func splitModule(s string) (string, string) {
+	s = strings.TrimPrefix(s, "/")
+	if i := strings.Index(s, "/"); i != -1 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
@@ -0,0 +1,45 @@
+package args
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDest(t *testing.T) {
+	tests := []struct {
+		dest string
+		want Destination
+	}{
+		{"some/local/dir", Destination{Path: "some/local/dir"}},
+		{"host:/some/dir", Destination{Host: "host", Path: "/some/dir"}},
+		{"user@host:dir", Destination{User: "user", Host: "host", Path: "dir"}},
+		{"host::pub", Destination{Host: "host", Daemon: true, Module: "pub"}},
+		{
+			"user@mirror.example.com::pub/rhel/",
+			Destination{User: "user", Host: "mirror.example.com", Daemon: true, Module: "pub", Path: "rhel/"},
+		},
+		{
+			"rsync://mirror.example.com/pub/rhel",
+			Destination{Host: "mirror.example.com", Daemon: true, Module: "pub", Path: "rhel"},
+		},
+		{
+			"rsync://user@mirror.example.com:8873/pub/rhel/9",
+			Destination{User: "user", Host: "mirror.example.com", Port: "8873", Daemon: true, Module: "pub", Path: "rhel/9"},
+		},
+		{"rsync://host", Destination{Host: "host", Daemon: true}},
+		{"rsync://[::1]/pub", Destination{Host: "::1", Daemon: true, Module: "pub"}},
+		{
+			"rsync://user@[2001:db8::1]:873/pub/rhel",
+			Destination{User: "user", Host: "2001:db8::1", Port: "873", Daemon: true, Module: "pub", Path: "rhel"},
+		},
+		{"[::1]:/some/dir", Destination{Host: "::1", Path: "/some/dir"}},
+		{"user@[::1]::pub/rhel", Destination{User: "user", Host: "::1", Daemon: true, Module: "pub", Path: "rhel"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dest, func(t *testing.T) {
+			if got := ParseDest(tt.dest); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
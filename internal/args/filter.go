@@ -0,0 +1,169 @@
+package args
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterAction is the action of a filter rule.
+type FilterAction byte
+
+// Actions of filter rules, using the same characters as the short form of
+// each rule in rsync.
+const (
+	FilterExclude  FilterAction = '-'
+	FilterInclude  FilterAction = '+'
+	FilterMerge    FilterAction = '.'
+	FilterDirMerge FilterAction = ':'
+	FilterClear    FilterAction = '!'
+)
+
+// FilterSide determines whether a filter rule applies to the sending side
+// (i.e. which files are published), the receiving side (i.e. which files are
+// protected from deletion), or both.
+type FilterSide int
+
+// Sides to which a filter rule may apply.
+const (
+	FilterBothSides FilterSide = iota
+	FilterSender
+	FilterReceiver
+)
+
+// FilterRule is a single parsed rsync filter rule, as described in the
+// FILTER RULES section of "man rsync".
+type FilterRule struct {
+	Action FilterAction
+	Side   FilterSide
+
+	// The pattern for include/exclude rules, or the name of the file to be
+	// read for merge rules.
+	Pattern string
+
+	// Modifiers for include/exclude rules.
+	Absolute   bool // "/": match against absolute path names
+	Negate     bool // "!": match if the pattern does not match
+	Perishable bool // "p": ignored when deleting directories
+
+	// Modifiers for merge rules.
+	MergeAction FilterAction // "+" or "-": file contains only patterns of this action
+	ExcludeSelf bool         // "e": exclude the merge file itself
+	NoInherit   bool         // "n": rules are not inherited by subdirectories
+	WordSplit   bool         // "w": split rules on whitespace
+
+	// "C": for an exclude rule, which has no pattern, insert the CVS
+	// excludes in place of the rule. For merge rules, read the file as a
+	// .cvsignore file, which is the default filename.
+	CVS bool
+}
+
+// Long names of filter rules and their equivalent short form.
+var filterRuleNames = map[string]string{
+	"exclude":   "-",
+	"include":   "+",
+	"merge":     ".",
+	"dir-merge": ":",
+	"hide":      "H",
+	"show":      "S",
+	"protect":   "P",
+	"risk":      "R",
+	"clear":     "!",
+}
+
+// ParseFilterRule parses a single filter rule such as "- *.tmp",
+// "exclude,! /keep" or "dir-merge,n- .exclude".
+func ParseFilterRule(rule string) (FilterRule, error) {
+	out := FilterRule{}
+
+	// Rule name & modifiers are separated from the pattern by a single
+	// space or underscore.
+	head, pattern := rule, ""
+	if idx := strings.IndexAny(rule, " _"); idx != -1 {
+		head, pattern = rule[:idx], rule[idx+1:]
+	}
+
+	name, modifiers := head, ""
+	if idx := strings.Index(head, ","); idx != -1 {
+		name, modifiers = head[:idx], head[idx+1:]
+	}
+	if short, ok := filterRuleNames[name]; ok {
+		name = short
+	} else if len(head) > 0 && modifiers == "" {
+		// Short rules may be directly followed by modifiers.
+		name, modifiers = head[:1], head[1:]
+	}
+
+	switch name {
+	case "-", "+", ".", ":", "!":
+		out.Action = FilterAction(name[0])
+	case "H":
+		out.Action, out.Side = FilterExclude, FilterSender
+	case "S":
+		out.Action, out.Side = FilterInclude, FilterSender
+	case "P":
+		out.Action, out.Side = FilterExclude, FilterReceiver
+	case "R":
+		out.Action, out.Side = FilterInclude, FilterReceiver
+	default:
+		return out, fmt.Errorf("unsupported filter '%s'", rule)
+	}
+
+	isMerge := out.Action == FilterMerge || out.Action == FilterDirMerge
+
+	for _, mod := range modifiers {
+		switch {
+		case mod == '/' && !isMerge:
+			out.Absolute = true
+		case mod == '!' && !isMerge:
+			out.Negate = true
+		case mod == 'p' && !isMerge:
+			out.Perishable = true
+		case mod == 's' && out.Side != FilterReceiver:
+			out.Side = FilterSender
+		case mod == 'r' && out.Side != FilterSender:
+			out.Side = FilterReceiver
+		case (mod == '-' || mod == '+') && isMerge:
+			out.MergeAction = FilterAction(mod)
+		case mod == 'e' && isMerge:
+			out.ExcludeSelf = true
+		case mod == 'n' && isMerge:
+			out.NoInherit = true
+		case mod == 'w' && isMerge:
+			out.WordSplit = true
+		case mod == 'C' && isMerge:
+			out.CVS = true
+			out.NoInherit = true
+			out.WordSplit = true
+			out.MergeAction = FilterExclude
+		case mod == 'C' && out.Action == FilterExclude && out.Side == FilterBothSides:
+			out.CVS = true
+		default:
+			return out, fmt.Errorf("unsupported modifier '%c' in filter '%s'", mod, rule)
+		}
+	}
+
+	if out.Action == FilterClear {
+		if modifiers != "" || pattern != "" {
+			return out, fmt.Errorf("unsupported filter '%s': clear takes no arguments", rule)
+		}
+		return out, nil
+	}
+
+	if out.CVS && !isMerge {
+		if pattern != "" {
+			return out, fmt.Errorf("unsupported filter '%s': -C takes no pattern", rule)
+		}
+		return out, nil
+	}
+
+	if out.CVS && pattern == "" {
+		pattern = ".cvsignore"
+	}
+
+	if pattern == "" {
+		return out, fmt.Errorf("unsupported filter '%s': missing pattern", rule)
+	}
+	out.Pattern = pattern
+
+	return out, nil
+}
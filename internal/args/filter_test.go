@@ -0,0 +1,88 @@
+package args
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilterRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want FilterRule
+	}{
+		{"- *.tmp", FilterRule{Action: FilterExclude, Pattern: "*.tmp"}},
+		{"-_*.tmp", FilterRule{Action: FilterExclude, Pattern: "*.tmp"}},
+		{"+ dir/", FilterRule{Action: FilterInclude, Pattern: "dir/"}},
+		{"+ has space", FilterRule{Action: FilterInclude, Pattern: "has space"}},
+		{"-/ /abs", FilterRule{Action: FilterExclude, Pattern: "/abs", Absolute: true}},
+		{"-! */", FilterRule{Action: FilterExclude, Pattern: "*/", Negate: true}},
+		{"-,p core", FilterRule{Action: FilterExclude, Pattern: "core", Perishable: true}},
+		{"exclude foo", FilterRule{Action: FilterExclude, Pattern: "foo"}},
+		{"include,s foo", FilterRule{Action: FilterInclude, Pattern: "foo", Side: FilterSender}},
+		{"-r foo", FilterRule{Action: FilterExclude, Pattern: "foo", Side: FilterReceiver}},
+		{"H foo", FilterRule{Action: FilterExclude, Pattern: "foo", Side: FilterSender}},
+		{"hide foo", FilterRule{Action: FilterExclude, Pattern: "foo", Side: FilterSender}},
+		{"S foo", FilterRule{Action: FilterInclude, Pattern: "foo", Side: FilterSender}},
+		{"show foo", FilterRule{Action: FilterInclude, Pattern: "foo", Side: FilterSender}},
+		{"P foo", FilterRule{Action: FilterExclude, Pattern: "foo", Side: FilterReceiver}},
+		{"protect foo", FilterRule{Action: FilterExclude, Pattern: "foo", Side: FilterReceiver}},
+		{"R foo", FilterRule{Action: FilterInclude, Pattern: "foo", Side: FilterReceiver}},
+		{"risk foo", FilterRule{Action: FilterInclude, Pattern: "foo", Side: FilterReceiver}},
+		{"!", FilterRule{Action: FilterClear}},
+		{"clear", FilterRule{Action: FilterClear}},
+		{". /etc/rules", FilterRule{Action: FilterMerge, Pattern: "/etc/rules"}},
+		{"merge /etc/rules", FilterRule{Action: FilterMerge, Pattern: "/etc/rules"}},
+		{": .rules", FilterRule{Action: FilterDirMerge, Pattern: ".rules"}},
+		{"dir-merge,n- .excl", FilterRule{
+			Action: FilterDirMerge, Pattern: ".excl", NoInherit: true, MergeAction: FilterExclude}},
+		{":+ew .incl", FilterRule{
+			Action: FilterDirMerge, Pattern: ".incl", MergeAction: FilterInclude,
+			ExcludeSelf: true, WordSplit: true}},
+		{"-C", FilterRule{Action: FilterExclude, CVS: true}},
+		{":C", FilterRule{
+			Action: FilterDirMerge, Pattern: ".cvsignore", CVS: true,
+			NoInherit: true, WordSplit: true, MergeAction: FilterExclude}},
+		{"dir-merge,C .ignore", FilterRule{
+			Action: FilterDirMerge, Pattern: ".ignore", CVS: true,
+			NoInherit: true, WordSplit: true, MergeAction: FilterExclude}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseFilterRule(tt.rule)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr string
+	}{
+		{"quux", "unsupported filter 'quux'"},
+		{"x foo", "unsupported filter 'x foo'"},
+		{"-", "unsupported filter '-': missing pattern"},
+		{"- ", "unsupported filter '- ': missing pattern"},
+		{"! foo", "unsupported filter '! foo': clear takes no arguments"},
+		{"-e foo", "unsupported modifier 'e' in filter '-e foo'"},
+		{".! foo", "unsupported modifier '!' in filter '.! foo'"},
+		{"Hr foo", "unsupported modifier 'r' in filter 'Hr foo'"},
+		{"+C", "unsupported modifier 'C' in filter '+C'"},
+		{"-C foo", "unsupported filter '-C foo': -C takes no pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := ParseFilterRule(tt.rule)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("did not get expected error, got %v", err)
+			}
+		})
+	}
+}
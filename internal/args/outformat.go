@@ -0,0 +1,130 @@
+package args
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Escapes accepted within an OutFormat, other than "%%". These are all of
+// the escapes supported by rsync, though some always expand to an empty
+// string.
+const outFormatEscapes = "abBcCfGhilLmMnopPtuU"
+
+// OutFormat is a format for per-item output, as used by --out-format in rsync
+// (see "log format" in "man rsyncd.conf").
+//
+// Each escape consists of "%", an optional "-" for left alignment, an optional
+// field width, any number of "'" to group the digits of a number with commas,
+// then one of the following characters:
+//
+//	%b  the number of bytes uploaded
+//	%B  the permission bits of the file, as in "rwxr-xr-x"
+//	%c  the total size of block checksums received; always 0
+//	%C  the checksum of the file's content
+//	%f  the path of the item in the source tree
+//	%G  the group ID of the file
+//	%i  a summary of the changes made to the item (see --itemize-changes)
+//	%l  the length of the file in bytes
+//	%L  the string " -> TARGET" if the item is a link, otherwise empty
+//	%M  the last-modified time of the file
+//	%n  the path of the item relative to the destination
+//	%o  the operation: "send" or "del."
+//	%p  the process ID
+//	%t  the current date and time
+//	%U  the user ID of the file
+//
+// The escapes %a, %h, %m, %P and %u relate to an rsync daemon, so they are
+// accepted but expand to an empty string.
+type OutFormat string
+
+// expand returns the format with each escape replaced by the value returned
+// by value, or an error if the format is invalid.
+func (f OutFormat) expand(value func(escape byte) string) (string, error) {
+	var out strings.Builder
+
+	s := string(f)
+	for len(s) > 0 {
+		idx := strings.IndexByte(s, '%')
+		if idx == -1 {
+			out.WriteString(s)
+			break
+		}
+		out.WriteString(s[:idx])
+		s = s[idx+1:]
+
+		if strings.HasPrefix(s, "%") {
+			out.WriteByte('%')
+			s = s[1:]
+			continue
+		}
+
+		left := strings.HasPrefix(s, "-")
+		if left {
+			s = s[1:]
+		}
+
+		digits := len(s) - len(strings.TrimLeft(s, "0123456789"))
+		width := 0
+		if digits > 0 {
+			width, _ = strconv.Atoi(s[:digits])
+			s = s[digits:]
+		}
+
+		grouped := strings.HasPrefix(s, "'")
+		s = strings.TrimLeft(s, "'")
+
+		if len(s) == 0 || !strings.ContainsRune(outFormatEscapes, rune(s[0])) {
+			return "", fmt.Errorf("unsupported escape in format '%s'", f)
+		}
+
+		v := value(s[0])
+		if grouped {
+			v = groupDigits(v)
+		}
+
+		if left {
+			out.WriteString(fmt.Sprintf("%-*s", width, v))
+		} else {
+			out.WriteString(fmt.Sprintf("%*s", width, v))
+		}
+		s = s[1:]
+	}
+
+	return out.String(), nil
+}
+
+// groupDigits returns s with commas separating each group of three digits,
+// if s is a number, or otherwise s unchanged.
+func groupDigits(s string) string {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return s
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// Validate returns an error if the format uses any unsupported escapes.
+func (f OutFormat) Validate() error {
+	_, err := f.expand(func(byte) string { return "" })
+	return err
+}
+
+// Expand returns the format with each escape replaced by the value returned
+// by value. The format must be valid.
+func (f OutFormat) Expand(value func(escape byte) string) string {
+	out, _ := f.expand(value)
+	return out
+}
+
+// Uses returns true if the format contains the given escape.
+func (f OutFormat) Uses(escape byte) bool {
+	used := false
+	f.Expand(func(e byte) string {
+		used = used || e == escape
+		return ""
+	})
+	return used
+}
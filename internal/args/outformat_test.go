@@ -0,0 +1,59 @@
+package args
+
+import "testing"
+
+func TestOutFormatExpand(t *testing.T) {
+	values := map[byte]string{'n': "some/file", 'l': "123", 'o': "send", 'i': ".f         ", 'b': "1234567"}
+	value := func(escape byte) string { return values[escape] }
+
+	tests := []struct {
+		format   OutFormat
+		expected string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"%n", "some/file"},
+		{"%o %n (%l bytes)", "send some/file (123 bytes)"},
+		{"100%% %n", "100% some/file"},
+		{"[%6l]", "[   123]"},
+		{"[%-6l]", "[123   ]"},
+		{"%i %L%n", ".f          some/file"},
+		{"%'b bytes", "1,234,567 bytes"},
+		{"[%-10''b]", "[1,234,567 ]"},
+		{"%'n %'l", "some/file 123"},
+		{"[%a%h%m%P%u]", "[]"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.Expand(value); got != tt.expected {
+			t.Errorf("Expand(%q) = %q, expected %q", tt.format, got, tt.expected)
+		}
+	}
+}
+
+func TestOutFormatValidate(t *testing.T) {
+	for _, format := range []OutFormat{"%", "%x", "abc %-", "%10", "%'", "%'-n"} {
+		if format.Validate() == nil {
+			t.Errorf("format %q was accepted", format)
+		}
+	}
+}
+
+func TestOutFormatValidateRsyncEscapes(t *testing.T) {
+	// Every escape supported by rsync should be accepted.
+	for _, escape := range "abBcCfGhilLmMnopPtuU" {
+		format := OutFormat("%" + string(escape))
+		if err := format.Validate(); err != nil {
+			t.Errorf("format %q was rejected: %v", format, err)
+		}
+	}
+}
+
+func TestOutFormatUses(t *testing.T) {
+	if !OutFormat("%o %-11i %n").Uses('i') {
+		t.Error("did not find escape")
+	}
+	if OutFormat("%%i %n").Uses('i') {
+		t.Error("found escaped escape")
+	}
+}
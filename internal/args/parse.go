@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/alecthomas/kong"
@@ -15,34 +16,48 @@ const docsURL = "https://github.com/release-engineering/exodus-rsync"
 type filterArguments []string
 
 func (f filterArguments) Validate() error {
-	validRules := []string{"+", "-"}
-	validMods := []string{"", "/"}
-	validSeps := []string{" ", "_"}
-validation:
 	for _, arg := range f {
-		for _, rule := range validRules {
-			for _, mod := range validMods {
-				for _, sep := range validSeps {
-					prefix := rule + mod + sep
-					if strings.HasPrefix(arg, prefix) && strings.TrimLeft(arg, prefix) != "" {
-						continue validation
-					}
-				}
-			}
+		if _, err := ParseFilterRule(arg); err != nil {
+			return err
 		}
-		// Anything else is not supported
-		return fmt.Errorf("unsupported filter '%s'", arg)
 	}
 	return nil
 }
 
+// limitArgument is an integer argument for which 0 is meaningful, and
+// therefore must be distinguished from the argument not being provided.
+type limitArgument struct {
+	value int
+	set   bool
+}
+
+func (l *limitArgument) Decode(ctx *kong.DecodeContext) error {
+	// As with argStringMapper, the value is popped regardless of its type,
+	// since negative numbers would otherwise be mistaken for flags.
+	token := ctx.Scan.Pop()
+	if token.IsEOL() {
+		return fmt.Errorf("flag %s: missing value", ctx.Value.Name)
+	}
+
+	value, err := strconv.Atoi(token.String())
+	if err != nil {
+		return fmt.Errorf("flag %s: expected a number but got '%v'", ctx.Value.Name, token)
+	}
+
+	// As in rsync, negative values mean there is no limit.
+	*l = limitArgument{value, value >= 0}
+	return nil
+}
+
+// Get returns the value of the argument, and true if it was set.
+func (l limitArgument) Get() (int, bool) {
+	return l.value, l.set
+}
+
 // IgnoredConfig defines arguments which can be accepted for compatibility with rsync,
 // but are ignored by exodus-rsync.
 type IgnoredConfig struct {
 	Archive         bool `short:"a"`
-	Recursive       bool `short:"r"`
-	Links           bool `short:"l"`
-	CopyLinks       bool `short:"L"`
 	KeepDirlinks    bool `short:"K"`
 	HardLinks       bool `short:"H"`
 	Perms           bool `short:"p"`
@@ -59,12 +74,23 @@ type IgnoredConfig struct {
 	Crtimes         bool   `short:"N"`
 	OmitDirTimes    bool   `short:"O"`
 	Rsh             string `short:"e"`
-	Delete          bool
-	PruneEmptyDirs  bool `short:"m"`
-	Timeout         int
-	Compress        bool `short:"z"`
-	Stats           bool
-	ItemizeChanges  bool `short:"i"`
+	PruneEmptyDirs  bool   `short:"m"`
+	Compress        bool   `short:"z"`
+	Partial         bool
+	Quiet           bool     `short:"q"`
+	HumanReadable   int      `short:"h" type:"counter"`
+	Port            int      `placeholder:"PORT"`
+	Debug           []string `placeholder:"FLAGS"`
+
+	// Any other options accepted by rsync, including negations such as
+	// --no-perms, in the form passed to rsync; see Parse.
+	other []string
+}
+
+// Other returns arguments accepted for compatibility with rsync which have no
+// corresponding field, in the order given.
+func (c *IgnoredConfig) Other() []string {
+	return c.other
 }
 
 // ExodusConfig defines arguments which are specific to exodus-rsync and not supported
@@ -76,6 +102,22 @@ type ExodusConfig struct {
 	Publish string `help:"ID of existing exodus-gw publish to join."`
 
 	Diag bool `help:"Diagnostic mode, dumps various information about the environment."`
+
+	NoChecksumCache bool `help:"Don't use the checksum cache, even if enabled in configuration."`
+
+	Deadline int `placeholder:"SECONDS" help:"Abort the publish if not completed within SECONDS."`
+
+	ListJSON bool `help:"Like --list-only, but output a JSON object for each item."`
+
+	// These flags are commands; when used, SRC and DEST aren't needed.
+	PruneChecksumCache bool `help:"Remove stale entries from the checksum cache, then exit."`
+	ClearChecksumCache bool `help:"Remove all entries from the checksum cache, then exit."`
+}
+
+// ChecksumCacheCommand returns true if the arguments request maintenance of
+// the checksum cache rather than a sync.
+func (c *ExodusConfig) ChecksumCacheCommand() bool {
+	return c.PruneChecksumCache || c.ClearChecksumCache
 }
 
 // Config contains the subset of arguments which are returned by the parser and
@@ -86,59 +128,203 @@ type Config struct {
 
 	// Appends the source path to the destination path,
 	// e.g., /foo/bar/baz.c remote:/tmp => /tmp/foo/bar/baz.c.
-	Relative bool `short:"R" help:"use relative path names"`
+	Relative   bool `short:"R" help:"use relative path names"`
+	NoRelative bool `help:"Turn off --relative, including when implied by --files-from"`
+
+	// Only affects directories listed by --files-from, as SRC directories
+	// are always published recursively.
+	Recursive bool `short:"r" help:"Recurse into directories listed by --files-from"`
+
+	OneFileSystem bool `short:"x" help:"Don't cross filesystem boundaries"`
+
+	DryRun   bool `short:"n" help:"Perform a trial run with no changes made"`
+	ListOnly bool `help:"List the files instead of publishing them"`
+
+	Links           bool `short:"l" help:"Publish symlinks as links"`
+	CopyLinks       bool `short:"L" help:"Transform symlink into referent file/dir"`
+	CopyUnsafeLinks bool `help:"Only \"unsafe\" symlinks are transformed"`
+	SafeLinks       bool `help:"Ignore symlinks that point outside the source tree"`
 
-	DryRun bool `short:"n" help:"Perform a trial run with no changes made"`
+	IgnoreExisting bool `help:"Skip publishing files already published on exodus CDN"`
 
-	// Mostly ignored, but causes a failure if publish contains any files.
-	// See comments where the argument is checked for the explanation why.
-	IgnoreExisting bool `hidden:"1"`
+	RemoveSourceFiles bool `help:"Remove published files (non-directories) from SRC once the publish is committed"`
 
-	Filter    filterArguments `short:"f" placeholder:"RULE" help:"Add a file-filtering RULE"`
-	Exclude   []string        `placeholder:"PATTERN" help:"Exclude files matching this pattern"`
-	Include   []string        `placeholder:"PATTERN" help:"Don't exclude files matching this pattern"`
-	FilesFrom string          `placeholder:"FILE" help:"Read list of source-file names from FILE"`
+	Delete         bool          `help:"Delete extraneous files from dest dirs"`
+	DeleteExcluded bool          `help:"Also delete excluded files from dest dirs"`
+	MaxDelete      limitArgument `placeholder:"NUM" help:"Don't delete more than NUM files"`
 
-	Src  string `arg:"1" placeholder:"SRC" help:"Local path to a file or directory for sync"`
-	Dest string `arg:"1" placeholder:"[USER@]HOST:DEST" help:"Remote destination for sync"`
+	Filter          filterArguments `short:"f" sep:"none" placeholder:"RULE" help:"Add a file-filtering RULE"`
+	FilterShorthand int             `short:"F" type:"counter" help:"Same as --filter='dir-merge /.rsync-filter'; repeated: --filter='- .rsync-filter'"`
+	CvsExclude      bool            `short:"C" help:"Auto-ignore files in the same way CVS does"`
+	Exclude         []string        `sep:"none" placeholder:"PATTERN" help:"Exclude files matching PATTERN"`
+	Include         []string        `sep:"none" placeholder:"PATTERN" help:"Don't exclude files matching PATTERN"`
+	FilesFrom       string          `placeholder:"FILE" help:"Read list of source-file names from FILE ('-' for stdin)"`
+	From0           bool            `name:"from0" short:"0" help:"Names read by --files-from are separated by NUL rather than newline"`
+
+	MaxSize Size `placeholder:"SIZE" help:"Don't transfer any file larger than SIZE"`
+	MinSize Size `placeholder:"SIZE" help:"Don't transfer any file smaller than SIZE"`
+
+	Stats          bool      `help:"Give some file-transfer stats"`
+	ItemizeChanges bool      `short:"i" help:"Output a change-summary for all updates"`
+	OutFormat      OutFormat `placeholder:"FORMAT" help:"Output updates using the specified FORMAT"`
+	LogFileFormat  OutFormat `placeholder:"FMT" help:"Log updates to the platform logger using the specified FMT"`
+
+	Progress        bool     `help:"Show progress during transfer"`
+	PartialProgress bool     `short:"P" help:"Same as --partial --progress"`
+	Info            []string `placeholder:"FLAGS" help:"Fine-grained informational verbosity; only 'progress' has any effect"`
+
+	BwLimit BwLimit `name:"bwlimit" placeholder:"RATE" help:"Limit upload bandwidth; KBytes per second"`
+	Timeout int     `placeholder:"SECONDS" help:"Set I/O timeout in seconds"`
+
+	// All of the above filter arguments as filter rules, in the order
+	// provided on the command-line.
+	rules []string
+
+	// Path under which the module of a daemon-style DEST is published;
+	// see SetModuleRoot.
+	moduleRoot string
+
+	// All arguments other than those specific to exodus-rsync, if peeked at
+	// rather than parsed; see Peek.
+	rsyncArgs []string
+
+	// Optional only because Src is greedy and not needed for some commands;
+	// see Parse.
+	Src  []string `arg:"1" optional:"1" placeholder:"SRC" help:"Local paths to files or directories for sync"`
+	Dest string   `arg:"1" optional:"1" placeholder:"[USER@]HOST:DEST" help:"Remote destination for sync; HOST::MODULE/DEST and rsync://HOST/MODULE/DEST are also accepted"`
 
 	IgnoredConfig `embed:"1" group:"ignored"`
 	ExodusConfig  `embed:"1" prefix:"exodus-"`
 }
 
-// processFilterArgs is a helper function that appends the appropriate patterns
-// (based on the given rule) from Filter arguments onto the given slice.
-func (c *Config) processFilterArgs(rule string, slice []string) []string {
-	for _, arg := range c.Filter {
-		if strings.HasPrefix(string(arg), rule) {
-			slice = append(slice, strings.TrimLeft(arg, "+-/ _"))
+// Rules generated by each usage of -F.
+var filterShorthandRules = []string{"dir-merge /.rsync-filter", "- .rsync-filter"}
+
+// filterShorthandRule returns the rule generated by the n'th usage of -F.
+func filterShorthandRule(n int) string {
+	if n >= len(filterShorthandRules) {
+		n = len(filterShorthandRules) - 1
+	}
+	return filterShorthandRules[n]
+}
+
+// FilterRules returns all filter rules from --filter, --exclude, --include
+// and -F arguments, in the order they should be evaluated.
+func (c *Config) FilterRules() []string {
+	if c.rules != nil {
+		return c.rules
+	}
+
+	// Not produced by Parse, so the original order is unknown.
+	out := []string{}
+	out = append(out, c.Filter...)
+	for _, pattern := range c.Exclude {
+		out = append(out, "- "+pattern)
+	}
+	for _, pattern := range c.Include {
+		out = append(out, "+ "+pattern)
+	}
+	for i := 0; i < c.FilterShorthand; i++ {
+		out = append(out, filterShorthandRule(i))
+	}
+	return out
+}
+
+// Records the order of all filter arguments as they were provided.
+func (c *Config) orderFilterRules(ctx *kong.Context) {
+	var filterIdx, excludeIdx, includeIdx, shorthandIdx int
+
+	for _, p := range ctx.Path {
+		if p.Flag == nil {
+			continue
+		}
+		switch p.Flag.Name {
+		case "filter":
+			c.rules = append(c.rules, c.Filter[filterIdx])
+			filterIdx++
+		case "exclude":
+			c.rules = append(c.rules, "- "+c.Exclude[excludeIdx])
+			excludeIdx++
+		case "include":
+			c.rules = append(c.rules, "+ "+c.Include[includeIdx])
+			includeIdx++
+		case "filter-shorthand":
+			c.rules = append(c.rules, filterShorthandRule(shorthandIdx))
+			shorthandIdx++
 		}
 	}
-	return slice
 }
 
-// Excluded exctracts the pattern from Filter arguments and appends it onto Exclude.
-func (c *Config) Excluded() []string {
-	return c.processFilterArgs("-", c.Exclude)
+// ItemFormat returns the format in which each item should be output, or an
+// empty string if items should not be output. As in rsync, --out-format takes
+// precedence; otherwise, -i and -v imply a default format.
+func (c *Config) ItemFormat() OutFormat {
+	switch {
+	case c.OutFormat != "":
+		return c.OutFormat
+	case c.ItemizeChanges:
+		return "%i %n%L"
+	case c.Verbose > 0:
+		return "%n%L"
+	}
+	return ""
 }
 
-// Included exctracts the pattern from Filter arguments and appends it onto Include.
-func (c *Config) Included() []string {
-	return c.processFilterArgs("+", c.Include)
+// ProgressLevel returns the level of progress output requested via --progress
+// or --info, as in rsync: 0 for none, 1 for per-file progress, or 2 for
+// progress of the whole transfer.
+func (c *Config) ProgressLevel() int {
+	level := 0
+	if c.Progress {
+		level = 1
+	}
 
+	for _, flag := range c.Info {
+		name := strings.ToLower(strings.TrimRight(flag, "0123456789"))
+		value := 1
+		if digits := flag[len(name):]; digits != "" {
+			value, _ = strconv.Atoi(digits)
+		}
+
+		switch name {
+		case "progress", "all":
+			level = value
+		case "none":
+			level = 0
+		}
+	}
+
+	return level
+}
+
+// SetModuleRoot sets the path under which the module of a daemon-style
+// destination (host::module/path) is published. If never called, modules
+// are published under "/<module>".
+func (c *Config) SetModuleRoot(root string) {
+	c.moduleRoot = root
 }
 
 // DestPath returns only the path portion of the destination argument passed
-// on the command-line.
+// on the command-line, for the given source.
 // For example, if invoked with user@host.example.com:/some/dir,
 // this will return "/some/dir".
+// For daemon-style destinations, the path within the module is joined to the
+// module's root, e.g. host::pub/some/dir => /pub/some/dir.
 // If relative paths are requested (-R), appends the source path to the
 // destination path, e.g., /foo/bar/baz.c remote:/tmp => /tmp/foo/bar/baz.c.
-func (c *Config) DestPath() string {
+func (c *Config) DestPath(src string) string {
 	if strings.Contains(c.Dest, ":") {
-		dest := strings.SplitN(c.Dest, ":", 2)[1]
+		parsed := ParseDest(c.Dest)
+		dest := parsed.Path
+		if parsed.Daemon {
+			root := c.moduleRoot
+			if root == "" {
+				root = path.Join("/", parsed.Module)
+			}
+			dest = path.Join(root, parsed.Path)
+		}
 		if c.Relative {
-			dest = path.Join(dest, c.Src)
+			dest = path.Join(dest, src)
 		}
 		return dest
 	}
@@ -163,6 +349,34 @@ func (argStringMapper) Decode(ctx *kong.DecodeContext, target reflect.Value) err
 	return nil
 }
 
+// newParser returns a kong parser which will parse arguments into out.
+func newParser(out *Config, version string, exit func(int)) (*kong.Kong, error) {
+	return kong.New(out,
+		kong.Exit(exit),
+		kong.KindMapper(reflect.String, argStringMapper{}),
+		kong.Description(
+			fmt.Sprintf(
+				"exodus-rsync %s, an exodus-aware rsync replacement.\n\nSee also: %s",
+				version, docsURL)),
+
+		kong.ExplicitGroups([]kong.Group{
+
+			{Key: "ignored",
+				Title: "Ignored flags:",
+				Description: "The following arguments are accepted for compatibility with rsync, " +
+					"but do not affect the behavior of exodus-rsync. " +
+					"All other rsync options are also accepted and ignored.",
+			},
+		}),
+
+		// -h means --human-readable, as in rsync.
+		kong.PostBuild(func(k *kong.Kong) error {
+			k.Model.HelpFlag.Short = 0
+			return nil
+		}),
+	)
+}
+
 // Parse will parse provided command-line arguments and either return
 // a valid Config object, or call the exit function with a non-zero
 // exit code.
@@ -178,23 +392,47 @@ func Parse(args []string, version string, exit func(int)) Config {
 
 	os.Args = args
 	out := Config{}
-	kong.Parse(&out,
-		kong.Exit(exit),
-		kong.KindMapper(reflect.String, argStringMapper{}),
-		kong.Description(
-			fmt.Sprintf(
-				"exodus-rsync %s, an exodus-aware rsync replacement.\n\nSee also: %s",
-				version, docsURL)),
+	parser, err := newParser(&out, version, exit)
+	if err != nil {
+		panic(err)
+	}
 
-		kong.ExplicitGroups([]kong.Group{
+	// kong doesn't understand everything rsync does, so arguments are first
+	// translated into a form it can handle.
+	kongArgs, other := compatArgs(args[1:], parser.Model.Flags)
+	out.other = other
 
-			{Key: "ignored",
-				Title: "Ignored flags:",
-				Description: "The following arguments are accepted for compatibility with rsync, " +
-					"but do not affect the behavior of exodus-rsync.",
-			},
-		}),
-	)
+	ctx, err := parser.Parse(kongArgs)
+	parser.FatalIfErrorf(err)
+
+	if ctx != nil {
+		out.orderFilterRules(ctx)
+
+		// Src consumes all positional arguments up to the next flag, so DEST
+		// ends up as the last of them unless a flag came in between.
+		switch {
+		case out.ChecksumCacheCommand():
+			// SRC and DEST aren't needed.
+
+		case len(out.Src) == 0:
+			ctx.Fatalf("expected \"<src> ... <dest>\"")
+			return out
+
+		case out.Dest == "":
+			if len(out.Src) < 2 {
+				ctx.Fatalf("expected \"<dest>\"")
+				return out
+			}
+			out.Dest = out.Src[len(out.Src)-1]
+			out.Src = out.Src[:len(out.Src)-1]
+		}
+	}
+
+	// -P enables both --partial and --progress.
+	if out.PartialProgress {
+		out.Partial = true
+		out.Progress = true
+	}
 
 	// DevicesSpecials (-D) enables both --devices and --specials.
 	if out.DevicesSpecials {
@@ -202,5 +440,23 @@ func Parse(args []string, version string, exit func(int)) Config {
 		out.Specials = true
 	}
 
+	// --files-from implies -R, unless turned off by --no-relative.
+	if out.FilesFrom != "" {
+		out.Relative = true
+	}
+	if out.NoRelative {
+		out.Relative = false
+	}
+
+	// --exodus-list-json is a variant of --list-only.
+	if out.ListJSON {
+		out.ListOnly = true
+	}
+
+	// --delete-excluded implies --delete.
+	if out.DeleteExcluded {
+		out.Delete = true
+	}
+
 	return out
 }
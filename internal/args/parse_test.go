@@ -13,7 +13,16 @@ func TestParseOk(t *testing.T) {
 		want  Config
 	}{
 		"trivial": {input: []string{"exodus-rsync", "some-src", "some-dest"},
-			want: Config{Src: "some-src", Dest: "some-dest"}},
+			want: Config{Src: []string{"some-src"}, Dest: "some-dest"}},
+
+		"multiple sources": {input: []string{"exodus-rsync", "src1", "src2/", "src3", "some-dest"},
+			want: Config{Src: []string{"src1", "src2/", "src3"}, Dest: "some-dest"}},
+
+		"flag before dest": {input: []string{"exodus-rsync", "src1", "src2", "-v", "some-dest"},
+			want: Config{Src: []string{"src1", "src2"}, Dest: "some-dest", Verbose: 1}},
+
+		"flag after dest": {input: []string{"exodus-rsync", "src1", "src2", "some-dest", "-v"},
+			want: Config{Src: []string{"src1", "src2"}, Dest: "some-dest", Verbose: 1}},
 
 		"ignored args": {
 			// At least all compound names should be in long-form to ensure rsync compatibility.
@@ -29,19 +38,23 @@ func TestParseOk(t *testing.T) {
 				"--crtimes",
 				"--omit-dir-times",
 				"--rsh", "abc",
-				"--delete",
 				"--prune-empty-dirs",
+				"--one-file-system",
 				"--timeout", "123",
 				"--stats",
 				"--itemize-changes",
 				"x",
 				"y"},
-			want: Config{Src: "x", Dest: "y",
+			want: Config{Src: []string{"x"}, Dest: "y",
+				Recursive:      true,
+				OneFileSystem:  true,
+				Links:          true,
+				CopyLinks:      true,
+				Stats:          true,
+				ItemizeChanges: true,
+				Timeout:        123,
 				IgnoredConfig: IgnoredConfig{
 					Archive:         true,
-					Recursive:       true,
-					Links:           true,
-					CopyLinks:       true,
 					KeepDirlinks:    true,
 					HardLinks:       true,
 					Perms:           true,
@@ -58,12 +71,8 @@ func TestParseOk(t *testing.T) {
 					Crtimes:         true,
 					OmitDirTimes:    true,
 					Rsh:             "abc",
-					Delete:          true,
 					PruneEmptyDirs:  true,
-					Timeout:         123,
 					Compress:        true,
-					Stats:           true,
-					ItemizeChanges:  true,
 				}}},
 
 		"verbose": {
@@ -72,7 +81,7 @@ func TestParseOk(t *testing.T) {
 				"-vv", "--verbose",
 				"x",
 				"y"},
-			want: Config{Verbose: 3, Src: "x", Dest: "y"}},
+			want: Config{Verbose: 3, Src: []string{"x"}, Dest: "y"}},
 
 		"relative": {
 			input: []string{
@@ -80,7 +89,7 @@ func TestParseOk(t *testing.T) {
 				"--relative",
 				"x",
 				"y"},
-			want: Config{Relative: true, Src: "x", Dest: "y"}},
+			want: Config{Relative: true, Src: []string{"x"}, Dest: "y"}},
 
 		"exclude": {
 			input: []string{
@@ -91,7 +100,36 @@ func TestParseOk(t *testing.T) {
 				"*.conf",
 				"x",
 				"y"},
-			want: Config{Exclude: []string{".*", "*.conf"}, Src: "x", Dest: "y"}},
+			want: Config{Exclude: []string{".*", "*.conf"}, Src: []string{"x"}, Dest: "y",
+				rules: []string{"- .*", "- *.conf"}}},
+
+		"links": {
+			input: []string{
+				"exodus-rsync",
+				"-l",
+				"--copy-unsafe-links",
+				"--safe-links",
+				"x",
+				"y"},
+			want: Config{Links: true, CopyUnsafeLinks: true, SafeLinks: true, Src: []string{"x"}, Dest: "y"}},
+
+		"delete": {
+			input: []string{
+				"exodus-rsync",
+				"--delete",
+				"--max-delete=0",
+				"x",
+				"y"},
+			want: Config{Delete: true, MaxDelete: limitArgument{0, true}, Src: []string{"x"}, Dest: "y"}},
+
+		"delete excluded": {
+			input: []string{
+				"exodus-rsync",
+				"--delete-excluded",
+				"--max-delete", "-1",
+				"x",
+				"y"},
+			want: Config{Delete: true, DeleteExcluded: true, MaxDelete: limitArgument{-1, false}, Src: []string{"x"}, Dest: "y"}},
 
 		"files-from": {
 			input: []string{
@@ -100,7 +138,33 @@ func TestParseOk(t *testing.T) {
 				"sources.txt",
 				"x",
 				"y"},
-			want: Config{FilesFrom: "sources.txt", Src: "x", Dest: "y"}},
+			want: Config{FilesFrom: "sources.txt", Relative: true, Src: []string{"x"}, Dest: "y"}},
+
+		"list-only": {
+			input: []string{
+				"exodus-rsync",
+				"--list-only",
+				"x",
+				"y"},
+			want: Config{ListOnly: true, Src: []string{"x"}, Dest: "y"}},
+
+		"list json": {
+			input: []string{
+				"exodus-rsync",
+				"--exodus-list-json",
+				"x",
+				"y"},
+			want: Config{ListOnly: true, ExodusConfig: ExodusConfig{ListJSON: true}, Src: []string{"x"}, Dest: "y"}},
+
+		"files-from no-relative": {
+			input: []string{
+				"exodus-rsync",
+				"-r0",
+				"--files-from=-",
+				"--no-relative",
+				"x",
+				"y"},
+			want: Config{FilesFrom: "-", From0: true, NoRelative: true, Recursive: true, Src: []string{"x"}, Dest: "y"}},
 
 		"tolerable filter": {
 			input: []string{
@@ -109,7 +173,85 @@ func TestParseOk(t *testing.T) {
 				"--filter=-/_*",
 				"x",
 				"y"},
-			want: Config{Src: "x", Dest: "y", Filter: []string{"+ **/hi/**", "-/_*"}}},
+			want: Config{Src: []string{"x"}, Dest: "y", Filter: []string{"+ **/hi/**", "-/_*"},
+				rules: []string{"+ **/hi/**", "-/_*"}}},
+
+		"out format": {
+			input: []string{"exodus-rsync", "--out-format=%-10o %n (%l)", "--log-file-format", "%i %f", "x", "y"},
+			want:  Config{Src: []string{"x"}, Dest: "y", OutFormat: "%-10o %n (%l)", LogFileFormat: "%i %f"}},
+
+		"progress": {
+			input: []string{"exodus-rsync", "-P", "--info=progress2,stats", "x", "y"},
+			want: Config{Src: []string{"x"}, Dest: "y", PartialProgress: true, Progress: true,
+				Info: []string{"progress2", "stats"}, IgnoredConfig: IgnoredConfig{Partial: true}}},
+
+		"sizes": {
+			input: []string{"exodus-rsync", "--max-size=4G", "--min-size", "1", "x", "y"},
+			want:  Config{Src: []string{"x"}, Dest: "y", MaxSize: "4G", MinSize: "1"}},
+
+		"bwlimit": {
+			input: []string{"exodus-rsync", "--bwlimit=100K", "x", "y"},
+			want:  Config{Src: []string{"x"}, Dest: "y", BwLimit: "100K"}},
+
+		"checksum cache command": {
+			input: []string{"exodus-rsync", "--exodus-prune-checksum-cache"},
+			want:  Config{ExodusConfig: ExodusConfig{PruneChecksumCache: true}}},
+
+		"short option clusters": {
+			input: []string{"exodus-rsync", "-avzP", "-essh", "x", "y"},
+			want: Config{Src: []string{"x"}, Dest: "y", Verbose: 1, PartialProgress: true, Progress: true,
+				IgnoredConfig: IgnoredConfig{Archive: true, Compress: true, Partial: true, Rsh: "ssh"}}},
+
+		"negations": {
+			input: []string{"exodus-rsync", "-a", "--no-perms", "-l", "--no-l", "-vv", "--no-v", "-v",
+				"--no-R", "-R", "x", "y"},
+			want: Config{Src: []string{"x"}, Dest: "y", Verbose: 1, Relative: true,
+				IgnoredConfig: IgnoredConfig{Archive: true, other: []string{"--no-perms", "--no-l"}}}},
+
+		"other rsync options": {
+			input: []string{"exodus-rsync", "-qhh", "--port", "873", "--debug=FILTER,DEL", "--checksum",
+				"-B1024", "--protect-args", "--chmod=D755", "x", "y"},
+			want: Config{Src: []string{"x"}, Dest: "y",
+				IgnoredConfig: IgnoredConfig{Quiet: true, HumanReadable: 2, Port: 873,
+					Debug: []string{"FILTER", "DEL"},
+					other: []string{"--checksum", "--block-size=1024", "--protect-args", "--chmod=D755"}}}},
+
+		"delete timing": {
+			input: []string{"exodus-rsync", "--del", "--delete-after", "x", "y"},
+			want: Config{Src: []string{"x"}, Dest: "y", Delete: true,
+				IgnoredConfig: IgnoredConfig{other: []string{"--del", "--delete-after"}}}},
+
+		"filter files": {
+			input: []string{"exodus-rsync", "--exclude-from", "ex.txt", "--include=*.rpm",
+				"--include-from=in.txt", "x", "y"},
+			want: Config{Src: []string{"x"}, Dest: "y",
+				Filter:  []string{"merge,- ex.txt", "merge,+ in.txt"},
+				Include: []string{"*.rpm"},
+				rules:   []string{"merge,- ex.txt", "+ *.rpm", "merge,+ in.txt"}}},
+
+		"filter order": {
+			input: []string{
+				"exodus-rsync",
+				"--include", "*/",
+				"-F",
+				"--exclude=*.tmp,*.bak",
+				"-vFC",
+				"--filter", ": .exclude",
+				"--include", "*.c",
+				"x",
+				"y"},
+			want: Config{Src: []string{"x"}, Dest: "y", Verbose: 1, FilterShorthand: 2, CvsExclude: true,
+				Filter:  []string{": .exclude"},
+				Exclude: []string{"*.tmp,*.bak"},
+				Include: []string{"*/", "*.c"},
+				rules: []string{
+					"+ */",
+					"dir-merge /.rsync-filter",
+					"- *.tmp,*.bak",
+					"- .rsync-filter",
+					": .exclude",
+					"+ *.c",
+				}}},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -127,7 +269,33 @@ func TestParseErrors(t *testing.T) {
 	}{
 		"missing src dest": {[]string{"exodus-rsync"}},
 
+		"missing dest": {[]string{"exodus-rsync", "x"}},
+
+		"missing dest after flag": {[]string{"exodus-rsync", "x", "-v"}},
+
 		"bad filter": {[]string{"exodus-rsync", "--filter", "quux", "x", "y"}},
+
+		"bad filter modifier": {[]string{"exodus-rsync", "--filter", "-x foo", "x", "y"}},
+
+		"bad out-format": {[]string{"exodus-rsync", "--out-format", "%n %x", "x", "y"}},
+
+		"bad log-file-format": {[]string{"exodus-rsync", "--log-file-format", "%", "x", "y"}},
+
+		"bad max-delete": {[]string{"exodus-rsync", "--max-delete=many", "x", "y"}},
+
+		"bad max-size": {[]string{"exodus-rsync", "--max-size=big", "x", "y"}},
+
+		"bad bwlimit": {[]string{"exodus-rsync", "--bwlimit=fast", "x", "y"}},
+
+		"unknown option": {[]string{"exodus-rsync", "--quux", "x", "y"}},
+
+		"unknown short option": {[]string{"exodus-rsync", "-avZ", "x", "y"}},
+
+		"not negatable": {[]string{"exodus-rsync", "--no-delete", "x", "y"}},
+
+		"missing value": {[]string{"exodus-rsync", "x", "y", "--suffix"}},
+
+		"unexpected value": {[]string{"exodus-rsync", "--checksum=yes", "x", "y"}},
 	}
 
 	for name, tc := range tests {
@@ -151,16 +319,23 @@ func TestDestPath(t *testing.T) {
 		src  string
 		dest string
 		rel  bool
+		root string
 		want string
 	}{
-		{"no : in dest", ".", "some-dest", true, ""},
-		{": in dest", ".", "user@somehost:/some/rsync/path", false, "/some/rsync/path"},
-		{"relative dest", "/some/path", "user@somehost:/rsync/", true, "/rsync/some/path"},
+		{"no : in dest", ".", "some-dest", true, "", ""},
+		{": in dest", ".", "user@somehost:/some/rsync/path", false, "", "/some/rsync/path"},
+		{"relative dest", "/some/path", "user@somehost:/rsync/", true, "", "/rsync/some/path"},
+		{"daemon dest", ".", "somehost::pub/rsync/path", false, "", "/pub/rsync/path"},
+		{"daemon url", ".", "rsync://somehost:873/pub/rsync/path", false, "", "/pub/rsync/path"},
+		{"daemon module only", ".", "somehost::pub", false, "", "/pub"},
+		{"mapped module", ".", "somehost::pub/rsync/path", false, "/content/public", "/content/public/rsync/path"},
+		{"relative mapped module", "/some/path", "rsync://somehost/pub/", true, "/content", "/content/some/path"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := Config{Src: tt.src, Dest: tt.dest, Relative: tt.rel}
-			if got := c.DestPath(); got != tt.want {
+			c := Config{Dest: tt.dest, Relative: tt.rel}
+			c.SetModuleRoot(tt.root)
+			if got := c.DestPath(tt.src); got != tt.want {
 				t.Errorf("Config.DestPath() = %v, want %v", got, tt.want)
 			}
 		})
@@ -178,3 +353,27 @@ func TestStringMapDecodeError(t *testing.T) {
 		t.Fatalf("didn't get expected error, got %s", err.Error())
 	}
 }
+
+func TestProgressLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected int
+	}{
+		{"none", Config{}, 0},
+		{"progress", Config{Progress: true}, 1},
+		{"info progress", Config{Info: []string{"stats", "progress"}}, 1},
+		{"info progress2", Config{Progress: true, Info: []string{"progress2"}}, 2},
+		{"info progress0", Config{Progress: true, Info: []string{"progress0"}}, 0},
+		{"info none", Config{Info: []string{"progress2", "NONE"}}, 0},
+		{"info all", Config{Info: []string{"ALL"}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.ProgressLevel(); got != tt.expected {
+				t.Errorf("ProgressLevel() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package args
+
+import (
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// splitExodusArgs separates options specific to exodus-rsync, along with
+// their values, from all other arguments.
+func splitExodusArgs(args []string, flags []*kong.Flag) ([]string, []string) {
+	var exodus, other []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			other = append(other, args[i:]...)
+			break
+		}
+
+		name := strings.TrimPrefix(arg, "--")
+		hasValue := false
+		if idx := strings.Index(name, "="); idx != -1 {
+			name, hasValue = name[:idx], true
+		}
+
+		flag := findFlag(flags, func(flag *kong.Flag) bool {
+			return flag.Name == name
+		})
+		if !strings.HasPrefix(arg, "--exodus-") || flag == nil {
+			other = append(other, arg)
+			continue
+		}
+
+		exodus = append(exodus, arg)
+		if !hasValue && !flag.Value.IsBool() && i+1 < len(args) {
+			i++
+			exodus = append(exodus, args[i])
+		}
+	}
+
+	return exodus, other
+}
+
+// Peek leniently parses command-line arguments, so that the destination can
+// be found without rejecting arguments which exodus-rsync doesn't support.
+//
+// Only SRC, DEST, verbosity and options specific to exodus-rsync are
+// returned, along with all other arguments for passing through to rsync as
+// given (see RsyncArgs). Invalid arguments are ignored, leaving Parse to
+// report them.
+func Peek(args []string) Config {
+	out := Config{}
+	parser, err := newParser(&out, "", func(int) {})
+	if err != nil {
+		panic(err)
+	}
+
+	exodusArgs, rest := splitExodusArgs(args[1:], parser.Model.Flags)
+
+	// Any errors here will be reported by Parse.
+	parser.Parse(exodusArgs)
+
+	var positional []string
+	for _, tok := range resolveNegations(tokenizeArgs(rest)) {
+		switch {
+		case tok.opt != nil:
+			if tok.opt.long == "verbose" && !tok.negated {
+				out.Verbose++
+			}
+		case tok.raw[0] == "--":
+			positional = append(positional, tok.raw[1:]...)
+		case strings.HasPrefix(tok.raw[0], "-") && tok.raw[0] != "-":
+			// Not an option known to rsync.
+		default:
+			positional = append(positional, tok.raw...)
+		}
+	}
+
+	if n := len(positional); n > 0 {
+		out.Src, out.Dest = positional[:n-1], positional[n-1]
+	}
+
+	out.rsyncArgs = append([]string{}, rest...)
+
+	return out
+}
+
+// RsyncArgs returns the arguments to be passed through to rsync as given,
+// with any options specific to exodus-rsync removed. This is only set by
+// Peek; arguments from Parse are instead rebuilt for rsync as needed.
+func (c *Config) RsyncArgs() []string {
+	return c.rsyncArgs
+}
@@ -0,0 +1,72 @@
+package args
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPeek(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		want  Config
+		rsync []string
+	}{
+		{"typical",
+			[]string{"-vv", "--timeout", "30", "src", "host:/dest"},
+			Config{Verbose: 2, Src: []string{"src"}, Dest: "host:/dest"},
+			[]string{"-vv", "--timeout", "30", "src", "host:/dest"}},
+
+		{"unknown options",
+			[]string{"--some-future-option", "-vY", "--foo=bar", "src1", "src2", "host:/dest"},
+			Config{Verbose: 1, Src: []string{"src1", "src2"}, Dest: "host:/dest"},
+			[]string{"--some-future-option", "-vY", "--foo=bar", "src1", "src2", "host:/dest"}},
+
+		{"option values",
+			[]string{"-e", "ssh", "--exclude", "*.tmp", "--filter=- *.o", "src", "host::mod/dest"},
+			Config{Src: []string{"src"}, Dest: "host::mod/dest"},
+			[]string{"-e", "ssh", "--exclude", "*.tmp", "--filter=- *.o", "src", "host::mod/dest"}},
+
+		{"negated verbose",
+			[]string{"-v", "--no-v", "src", "host:/dest"},
+			Config{Src: []string{"src"}, Dest: "host:/dest"},
+			[]string{"-v", "--no-v", "src", "host:/dest"}},
+
+		{"exodus options",
+			[]string{"--exodus-conf", "my.conf", "-a", "--exodus-diag", "--exodus-publish=abc",
+				"--exodus-unknown", "src", "host:/dest"},
+			Config{Src: []string{"src"}, Dest: "host:/dest",
+				ExodusConfig: ExodusConfig{Conf: "my.conf", Diag: true, Publish: "abc"}},
+			[]string{"-a", "--exodus-unknown", "src", "host:/dest"}},
+
+		{"after --",
+			[]string{"-v", "--", "-src", "--exodus-conf"},
+			Config{Verbose: 1, Src: []string{"-src"}, Dest: "--exodus-conf"},
+			[]string{"-v", "--", "-src", "--exodus-conf"}},
+
+		{"dest only",
+			[]string{"host:/dest"},
+			Config{Src: []string{}, Dest: "host:/dest"},
+			[]string{"host:/dest"}},
+
+		{"no dest",
+			[]string{"--exodus-prune-checksum-cache"},
+			Config{ExodusConfig: ExodusConfig{PruneChecksumCache: true}},
+			[]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Peek(append([]string{"rsync"}, tt.args...))
+
+			if !reflect.DeepEqual(got.RsyncArgs(), tt.rsync) {
+				t.Errorf("RsyncArgs() = %v, want %v", got.RsyncArgs(), tt.rsync)
+			}
+
+			got.rsyncArgs = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Peek() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
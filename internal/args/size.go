@@ -0,0 +1,75 @@
+package args
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sizeRegexp = regexp.MustCompile(`^([0-9]*\.?[0-9]+)(?:([bBkKmMgGtTpP])(|[bB]|i[bB]))?([+-]1)?$`)
+
+// parseSize parses a number of bytes in the form accepted by rsync for
+// arguments such as --max-size and --bwlimit: a number, optionally
+// fractional, followed by an optional unit and an optional "+1" or "-1".
+//
+// Units are powers of 1024 ("K", "M", "G", ... or "KiB", "MiB", ...) or of
+// 1000 ("KB", "MB", ...); "B" means bytes. defaultUnit is used if there's
+// no unit.
+//
+// Returns false if s is invalid.
+func parseSize(s string, defaultUnit string) (int64, bool) {
+	match := sizeRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	base := 1024.0
+	if strings.EqualFold(match[3], "b") {
+		base = 1000
+	}
+
+	unit := strings.ToLower(match[2])
+	if unit == "" {
+		unit = defaultUnit
+	}
+	for _, u := range "bkmgtp" {
+		if string(u) == unit {
+			break
+		}
+		value *= base
+	}
+
+	out := int64(value)
+	switch match[4] {
+	case "+1":
+		out++
+	case "-1":
+		out--
+	}
+
+	return out, true
+}
+
+// Size is a number of bytes, as used by --max-size and --min-size in rsync.
+// See parseSize for the accepted forms; without a unit, the size is in bytes.
+type Size string
+
+// Validate returns an error if the size is invalid.
+func (s Size) Validate() error {
+	if _, ok := parseSize(string(s), "b"); !ok && s != "" {
+		return fmt.Errorf("invalid size '%s'", s)
+	}
+	return nil
+}
+
+// Bytes returns the size in bytes, or 0 if unset. The size must be valid.
+func (s Size) Bytes() int64 {
+	out, _ := parseSize(string(s), "b")
+	return out
+}
@@ -0,0 +1,47 @@
+package args
+
+import "testing"
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		input    Size
+		expected int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"100", 100},
+		{"1.5k", 1536},
+		{"10K", 10240},
+		{"10KB", 10000},
+		{"4g", 4294967296},
+		{"4GiB", 4294967296},
+		{"2g-1", 2147483647},
+		{"1k+1", 1025},
+		{"1m", 1048576},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.input), func(t *testing.T) {
+			if err := tt.input.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := tt.input.Bytes(); got != tt.expected {
+				t.Errorf("Bytes() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSizeInvalid(t *testing.T) {
+	for _, input := range []Size{"x", "-1", "1+2", "1kk", "1.k"} {
+		t.Run(string(input), func(t *testing.T) {
+			err := input.Validate()
+			if err == nil {
+				t.Fatal("unexpectedly accepted")
+			}
+			if err.Error() != "invalid size '"+string(input)+"'" {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
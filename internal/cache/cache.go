@@ -0,0 +1,316 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/adrg/xdg"
+)
+
+// Version of the on-disk format. Entries with any other version are ignored.
+const formatVersion = 1
+
+// DefaultPath returns the path of the directory in which the checksum cache
+// is stored.
+func DefaultPath() string {
+	return filepath.Join(xdg.CacheHome, "exodus-rsync", "checksums")
+}
+
+// entry records the checksum of a file, along with the file's state at the
+// time the checksum was calculated.
+type entry struct {
+	Version int    `json:"version"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	MTime   int64  `json:"mtime"`
+	CTime   int64  `json:"ctime"`
+	Sum     string `json:"sum"`
+}
+
+// Cache is a persistent cache of file checksums, used to avoid rehashing
+// files which haven't changed since the last run.
+//
+// A file's checksum is reused only if the file's device, inode, size, mtime
+// and ctime are all unchanged. Any write to a file updates its ctime, even if
+// mtime is explicitly preserved.
+//
+// Each entry is stored in a separate file, so that a run only reads entries
+// for the files it looks up and only writes the entries it stores, however
+// large the cache grows.
+//
+// Cache is safe for concurrent use.
+type Cache struct {
+	path string
+
+	mu      sync.Mutex
+	stored  map[string]entry
+	removed map[string]bool
+	cleared bool
+}
+
+// fileKey returns the key identifying a file in the cache, and the file's
+// ctime. ok is false if the info doesn't contain the necessary details.
+//
+// The key is also the path of the file holding the entry, relative to the
+// cache directory. Entries are spread over subdirectories by inode number,
+// to keep each directory reasonably small.
+func fileKey(info fs.FileInfo) (key string, ctime int64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", 0, false
+	}
+	key = filepath.Join(fmt.Sprintf("%02x", st.Ino&0xff), fmt.Sprintf("%d-%d", st.Dev, st.Ino))
+	return key, st.Ctim.Nano(), true
+}
+
+func (e *entry) matches(info fs.FileInfo, ctime int64) bool {
+	return e.Size == info.Size() && e.MTime == info.ModTime().UnixNano() && e.CTime == ctime
+}
+
+// stale returns true if the file for which e was stored, under key, no
+// longer exists or has changed.
+func (e *entry) stale(key string) bool {
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		return true
+	}
+	statKey, ctime, ok := fileKey(info)
+	return !ok || statKey != key || !e.matches(info, ctime)
+}
+
+// Open returns the cache stored in the directory at path.
+//
+// If there is no directory at path, the cache is initially empty. Nothing is
+// read until entries are looked up.
+func Open(path string) (*Cache, error) {
+	info, err := os.Stat(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil && !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	return &Cache{
+		path:    path,
+		stored:  make(map[string]entry),
+		removed: make(map[string]bool),
+	}, nil
+}
+
+// read returns the entry stored on disk under key, and true if there is a
+// usable entry. Entries which can't be decoded are treated as absent, since
+// their content can always be recalculated.
+func (c *Cache) read(key string) (entry, bool) {
+	var e entry
+
+	content, err := os.ReadFile(filepath.Join(c.path, key))
+	if err != nil || json.Unmarshal(content, &e) != nil || e.Version != formatVersion {
+		return entry{}, false
+	}
+	return e, true
+}
+
+// diskKeys returns the keys of all entries stored on disk. Files which
+// can't be read are skipped, as for undecodable entries.
+func (c *Cache) diskKeys() []string {
+	var out []string
+
+	filepath.WalkDir(c.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if key, err := filepath.Rel(c.path, path); err == nil {
+			out = append(out, key)
+		}
+		return nil
+	})
+
+	return out
+}
+
+// keys returns the keys of all current entries, including changes not yet
+// saved. c.mu must be held.
+func (c *Cache) keys() []string {
+	var out []string
+
+	if !c.cleared {
+		for _, key := range c.diskKeys() {
+			if _, ok := c.stored[key]; !ok && !c.removed[key] {
+				out = append(out, key)
+			}
+		}
+	}
+	for key := range c.stored {
+		out = append(out, key)
+	}
+
+	return out
+}
+
+// Lookup returns the cached checksum of the file at path, which has the
+// given info (as returned by os.Stat), and true if there is a usable checksum.
+func (c *Cache) Lookup(path string, info fs.FileInfo) (string, bool) {
+	key, ctime, ok := fileKey(info)
+	if !ok {
+		return "", false
+	}
+
+	c.mu.Lock()
+	e, ok := c.stored[key]
+	absent := c.cleared || c.removed[key]
+	c.mu.Unlock()
+
+	if !ok && !absent {
+		e, ok = c.read(key)
+	}
+	if !ok || !e.matches(info, ctime) {
+		return "", false
+	}
+	return e.Sum, true
+}
+
+// Store records the checksum of the file at path, calculated while the file
+// had the given info (as returned by os.Stat).
+func (c *Cache) Store(path string, info fs.FileInfo, sum string) {
+	key, ctime, ok := fileKey(info)
+	if !ok {
+		return
+	}
+
+	// The path is only needed for Prune, which may run from any directory.
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stored[key] = entry{
+		Version: formatVersion,
+		Path:    path,
+		Size:    info.Size(),
+		MTime:   info.ModTime().UnixNano(),
+		CTime:   ctime,
+		Sum:     sum,
+	}
+	delete(c.removed, key)
+}
+
+// Prune removes entries for files which no longer exist or have changed,
+// along with any entries which can't be decoded, returning the number of
+// entries removed.
+func (c *Cache) Prune() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for _, key := range c.keys() {
+		e, ok := c.stored[key]
+		if !ok {
+			e, ok = c.read(key)
+		}
+		if ok && !e.stale(key) {
+			continue
+		}
+
+		delete(c.stored, key)
+		c.removed[key] = true
+		removed++
+	}
+
+	return removed
+}
+
+// Clear removes all entries, returning the number of entries removed.
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := len(c.keys())
+	c.stored = make(map[string]entry)
+	c.removed = make(map[string]bool)
+	c.cleared = true
+	return removed
+}
+
+// Len returns the number of entries in the cache.
+//
+// Unlike other methods, this reads the whole cache directory.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.keys())
+}
+
+// write atomically replaces the entry stored on disk under key.
+func (c *Cache) write(key string, e entry) error {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(c.path, key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Save writes any changes made to the cache back to disk.
+//
+// Only the entries which were stored or removed are written, each replaced
+// atomically, so concurrent runs can't corrupt the cache or lose each other's
+// entries.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cleared {
+		if err := os.RemoveAll(c.path); err != nil {
+			return err
+		}
+		c.cleared = false
+	}
+
+	for key := range c.removed {
+		err := os.Remove(filepath.Join(c.path, key))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		delete(c.removed, key)
+	}
+
+	for key, e := range c.stored {
+		if err := c.write(key, e); err != nil {
+			return err
+		}
+		delete(c.stored, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, content string) os.FileInfo {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestCacheLookup(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	info := writeFile(t, file, "hello")
+
+	c, err := Open(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Lookup(file, info); ok {
+		t.Error("empty cache returned a checksum")
+	}
+
+	c.Store(file, info, "abc123")
+
+	if sum, ok := c.Lookup(file, info); !ok || sum != "abc123" {
+		t.Errorf("Lookup() = %v, %v after Store", sum, ok)
+	}
+
+	// Rewriting the file, even with the same size and mtime, changes ctime.
+	time.Sleep(10 * time.Millisecond)
+	newInfo := writeFile(t, file, "HELLO")
+	if err := os.Chtimes(file, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if newInfo, err = os.Stat(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Lookup(file, newInfo); ok {
+		t.Error("Lookup() returned a checksum for a modified file")
+	}
+}
+
+func TestCacheSaveOpen(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "sub", "cache")
+	file := filepath.Join(dir, "file")
+	info := writeFile(t, file, "hello")
+
+	c, err := Open(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Store(file, info, "abc123")
+
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = Open(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum, ok := c.Lookup(file, info); !ok || sum != "abc123" {
+		t.Errorf("Lookup() = %v, %v after reopen", sum, ok)
+	}
+
+	// There should be one file for the entry, with no temporary files left
+	// behind.
+	var files []string
+	filepath.WalkDir(cachePath, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if len(files) != 1 {
+		t.Errorf("unexpected files in cache dir: %v", files)
+	}
+}
+
+func TestCacheSaveConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache")
+
+	// Entries saved from separate runs using the cache at the same time
+	// should all be kept.
+	var infos []os.FileInfo
+	var caches []*Cache
+	for i := 0; i < 2; i++ {
+		file := filepath.Join(dir, fmt.Sprint(i))
+		infos = append(infos, writeFile(t, file, fmt.Sprint(i)))
+
+		c, err := Open(cachePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		caches = append(caches, c)
+	}
+
+	for i, c := range caches {
+		c.Store(filepath.Join(dir, fmt.Sprint(i)), infos[i], fmt.Sprint(i))
+	}
+	for _, c := range caches {
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := Open(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, info := range infos {
+		if sum, ok := c.Lookup(filepath.Join(dir, fmt.Sprint(i)), info); !ok || sum != fmt.Sprint(i) {
+			t.Errorf("Lookup() = %v, %v for entry %v", sum, ok, i)
+		}
+	}
+}
+
+func TestCacheOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache")
+	file := filepath.Join(dir, "file")
+	info := writeFile(t, file, "hello")
+
+	// An entry which can't be decoded is ignored.
+	key, _, _ := fileKey(info)
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(cachePath, key)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(cachePath, key), "not json")
+
+	c, err := Open(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup(file, info); ok {
+		t.Error("Lookup() returned a checksum from bad entry")
+	}
+
+	// ...and removed by prune.
+	if removed := c.Prune(); removed != 1 {
+		t.Errorf("Prune() removed %v entries, expected 1", removed)
+	}
+
+	// Other errors are returned.
+	_, err = Open(file)
+	if err == nil {
+		t.Error("opening a regular file did not fail")
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	dir := t.TempDir()
+
+	// Entries should be pruned in the same way whether or not they've been
+	// saved yet.
+	unsaved, err := Open(filepath.Join(dir, "unsaved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Open(filepath.Join(dir, "saved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"kept", "deleted", "modified"} {
+		file := filepath.Join(dir, name)
+		info := writeFile(t, file, name)
+		unsaved.Store(file, info, name+"-sum")
+		saved.Store(file, info, name+"-sum")
+	}
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "deleted")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "modified"), "changed content")
+
+	for _, tt := range []struct {
+		name string
+		c    *Cache
+	}{
+		{"unsaved", unsaved},
+		{"saved", saved},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if removed := tt.c.Prune(); removed != 2 {
+				t.Errorf("Prune() removed %v entries, expected 2", removed)
+			}
+			if tt.c.Len() != 1 {
+				t.Errorf("unexpected entries after prune: %v", tt.c.Len())
+			}
+
+			if err := tt.c.Save(); err != nil {
+				t.Fatal(err)
+			}
+			reopened, err := Open(tt.c.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reopened.Len() != 1 {
+				t.Errorf("unexpected entries after saving prune: %v", reopened.Len())
+			}
+
+			if removed := reopened.Clear(); removed != 1 {
+				t.Errorf("Clear() removed %v entries, expected 1", removed)
+			}
+			if reopened.Len() != 0 {
+				t.Errorf("unexpected entries after clear: %v", reopened.Len())
+			}
+
+			if err := reopened.Save(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(tt.c.path); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("cache dir not removed after clear, stat error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := Open(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		file := filepath.Join(dir, fmt.Sprint(i))
+		info := writeFile(t, file, fmt.Sprint(i))
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Store(file, info, fmt.Sprint(i))
+				if sum, ok := c.Lookup(file, info); !ok || sum != fmt.Sprint(i) {
+					t.Errorf("Lookup() = %v, %v", sum, ok)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if c.Len() != 10 {
+		t.Errorf("unexpected entries: %v", c.Len())
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/cache"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+)
+
+// checksumCacheMain handles --exodus-prune-checksum-cache and
+// --exodus-clear-checksum-cache.
+func checksumCacheMain(ctx context.Context, args args.Config) int {
+	logger := log.FromContext(ctx)
+	path := cache.DefaultPath()
+
+	checksums, err := cache.Open(path)
+	if err != nil {
+		logger.F("path", path, "error", err).Error("can't read checksum cache")
+		return exitFileIO
+	}
+
+	var removed int
+	if args.ClearChecksumCache {
+		removed = checksums.Clear()
+	} else {
+		removed = checksums.Prune()
+	}
+
+	if err := checksums.Save(); err != nil {
+		logger.F("path", path, "error", err).Error("can't write checksum cache")
+		return exitFileIO
+	}
+
+	logger.F("path", path, "removed", removed, "remaining", checksums.Len()).Info("Updated checksum cache")
+	return 0
+}
+
+// openChecksumCache returns the checksum cache for use during sync, or nil
+// if it can't be used. As the cache is only an optimization, problems with
+// it are not fatal.
+func openChecksumCache(ctx context.Context) *cache.Cache {
+	logger := log.FromContext(ctx)
+	path := cache.DefaultPath()
+
+	checksums, err := cache.Open(path)
+	if err != nil {
+		logger.F("path", path, "error", err).Warn("can't read checksum cache, checksums will not be cached")
+		return nil
+	}
+
+	logger.F("path", path).Debug("using checksum cache")
+	return checksums
+}
+
+// saveChecksumCache writes back any checksums added to the cache during sync.
+func saveChecksumCache(ctx context.Context, checksums *cache.Cache) {
+	logger := log.FromContext(ctx)
+
+	if err := checksums.Save(); err != nil {
+		logger.F("path", cache.DefaultPath(), "error", err).Warn("can't write checksum cache")
+	}
+}
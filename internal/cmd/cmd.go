@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"io"
+	"os"
 
 	"github.com/release-engineering/exodus-rsync/internal/args"
 	"github.com/release-engineering/exodus-rsync/internal/conf"
@@ -17,12 +19,20 @@ var ext = struct {
 	gw    gw.Interface
 	log   log.Interface
 	diag  diag.Interface
+
+	// Destination for output requested by arguments, such as --stats.
+	stdout io.Writer
+
+	// Source of the list for --files-from=-.
+	stdin io.Reader
 }{
 	conf.Package,
 	rsync.Package,
 	gw.Package,
 	log.Package,
 	diag.Package,
+	os.Stdout,
+	os.Stdin,
 }
 
 // This version should be written at build time, see Makefile.
@@ -34,7 +44,7 @@ func invalidMain(ctx context.Context, cfg conf.Config, _ args.Config) int {
 	logger := log.FromContext(ctx)
 
 	logger.F("rsyncmode", cfg.RsyncMode()).Error("Invalid 'rsyncmode' in configuration")
-	return 95
+	return exitSyntax
 }
 
 // Main is the top-level entry point to the exodus-rsync command.
@@ -53,32 +63,59 @@ func Main(rawArgs []string) int {
 		}
 	}
 
-	parsedArgs := args.Parse(rawArgs, version, nil)
+	// Arguments are at first only peeked at, since whether they're valid
+	// depends on whether DEST is handled by exodus. If not, rsync is run with
+	// the arguments as given, which may include options exodus-rsync doesn't
+	// support.
+	cmdArgs := args.Peek(rawArgs)
+	if cmdArgs.Dest == "" || cmdArgs.ChecksumCacheCommand() {
+		// Nothing to pass through to rsync, e.g. --help.
+		cmdArgs = args.Parse(rawArgs, version, nil)
+	}
 
-	logger := ext.log.NewLogger(parsedArgs)
+	logger := ext.log.NewLogger(cmdArgs)
 
 	ctx = log.NewContext(ctx, logger)
 
-	cfg, err := ext.conf.Load(ctx, parsedArgs)
+	// Maintenance of the checksum cache doesn't involve any sync, so
+	// doesn't need any config.
+	if cmdArgs.ChecksumCacheCommand() {
+		return checksumCacheMain(ctx, cmdArgs)
+	}
+
+	cfg, err := ext.conf.Load(ctx, cmdArgs)
 	if err != nil {
 		if _, ok := err.(*conf.MissingConfigFile); ok {
 			// Failed to find any config files, fallback to rsync
 			logger.WithField("error", err).Debug("setting rsyncmode to 'rsync'")
-			return rsyncMain(ctx, nil, parsedArgs)
+			return rsyncMain(ctx, nil, cmdArgs)
 		}
 		logger.WithField("error", err).Error("can't load config")
-		return 23
+		return exitSyntax
 	}
 
-	var env conf.Config = cfg.EnvironmentForDest(ctx, parsedArgs.Dest)
+	envCfg := cfg.EnvironmentForDest(ctx, cmdArgs.Dest)
+	var env conf.Config = envCfg
 	var main mainFunc = invalidMain
 
 	if env == nil || env.RsyncMode() == "rsync" {
 		main = rsyncMain
-	} else if env.RsyncMode() == "exodus" {
-		main = exodusMain
-	} else if env.RsyncMode() == "mixed" {
-		main = mixedMain
+	} else {
+		// DEST is handled by exodus, so all arguments must be understood.
+		cmdArgs = args.Parse(rawArgs, version, nil)
+		cfg.SetArgs(cmdArgs)
+
+		if dest := args.ParseDest(cmdArgs.Dest); dest.Daemon {
+			if root := envCfg.ModuleRoot(dest.Module); root != "" {
+				cmdArgs.SetModuleRoot(root)
+			}
+		}
+
+		if env.RsyncMode() == "exodus" {
+			main = exodusMain
+		} else if env.RsyncMode() == "mixed" {
+			main = mixedMain
+		}
 	}
 
 	if env == nil {
@@ -93,8 +130,8 @@ func Main(rawArgs []string) int {
 	// configuration and command, then proceed with publish
 	// afterward.
 	if env.Diag() {
-		ext.diag.Run(ctx, env, parsedArgs)
+		ext.diag.Run(ctx, env, cmdArgs)
 	}
 
-	return main(ctx, env, parsedArgs)
+	return main(ctx, env, cmdArgs)
 }
@@ -35,7 +35,7 @@ environments:
 	})
 
 	// It should exit.
-	if got != 101 {
+	if got != 5 {
 		t.Error("returned incorrect exit code", got)
 	}
 
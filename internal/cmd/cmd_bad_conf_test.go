@@ -22,6 +22,18 @@ environments:
   gwenv: test
 - prefix: exodus
   gwenv: test2
+`},
+
+		{"negative upload concurrency",
+			`
+environments:
+- prefix: dest
+  gwuploadconcurrency: -1
+`},
+
+		{"negative retry backoff",
+			`
+gwretrybackoff: -1000
 `},
 	}
 	for _, tt := range tests {
@@ -30,7 +42,7 @@ environments:
 
 			args := []string{"exodus-rsync", "-v", "src", "dest"}
 
-			if got := Main(args); got != 23 {
+			if got := Main(args); got != 1 {
 				t.Error("unexpected exit code", got)
 			}
 		})
@@ -47,7 +59,7 @@ environments:
 
 	args := []string{"exodus-rsync", "-vvv", "src", "dest:/quux"}
 
-	if got := Main(args); got != 95 {
+	if got := Main(args); got != 1 {
 		t.Error("unexpected exit code", got)
 	}
 }
@@ -74,10 +74,10 @@ func TestMainUploadFailed(t *testing.T) {
 		setupMock mockClientConfigurator
 		exitCode  int
 	}{
-		{"can't upload files", setupFailedUpload, 25},
-		{"can't create publish", setupFailedNewPublish, 62},
-		{"can't add items to publish", setupFailedAddItems, 51},
-		{"can't commit publish", setupFailedCommit, 71},
+		{"can't upload files", setupFailedUpload, 23},
+		{"can't create publish", setupFailedNewPublish, 23},
+		{"can't add items to publish", setupFailedAddItems, 23},
+		{"can't commit publish", setupFailedCommit, 23},
 	}
 	for _, tt := range tests {
 		t.Run(tt.message, func(t *testing.T) {
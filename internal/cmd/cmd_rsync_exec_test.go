@@ -2,14 +2,33 @@ package cmd
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/release-engineering/exodus-rsync/internal/args"
 	"github.com/release-engineering/exodus-rsync/internal/conf"
+	"github.com/release-engineering/exodus-rsync/internal/diag"
 	"github.com/release-engineering/exodus-rsync/internal/rsync"
 )
 
+// ArgvMatcher matches arguments with which rsync would be run with argv.
+type ArgvMatcher struct {
+	argv []string
+}
+
+func (m ArgvMatcher) Matches(x interface{}) bool {
+	args, ok := x.(args.Config)
+	if !ok {
+		return false
+	}
+	return reflect.DeepEqual(rsync.Arguments(testContext(), args), m.argv)
+}
+
+func (m ArgvMatcher) String() string {
+	return fmt.Sprintf("Arguments %v", m.argv)
+}
+
 func TestMainRawExecRsync(t *testing.T) {
 	ctrl := MockController(t)
 
@@ -31,7 +50,7 @@ func TestMainRawExecRsync(t *testing.T) {
 
 	got := Main(rawArgs)
 
-	if got != 94 {
+	if got != 14 {
 		t.Error("returned incorrect exit code", got)
 	}
 }
@@ -54,26 +73,87 @@ func TestMainExecRsync(t *testing.T) {
 	emptyConfig.EXPECT().Diag().AnyTimes().Return(false)
 
 	// Since no environment matches, we expect it to run rsync and it should pass
-	// through whatever arguments we're giving it.
-	args := args.Config{}
-	args.Recursive = true
-	args.Timeout = 1234
-	args.Src = "."
-	args.Dest = "some-dest:/foo/bar"
+	// through whatever arguments we're giving it, even those which exodus-rsync
+	// doesn't know about.
+	expectedArgv := []string{
+		"--recursive", "--timeout", "1234", "--some-future-option", "-Y",
+		".", "some-dest:/foo/bar",
+	}
 
 	// We can't actually simulate the 'rsync successful' case because exec would not
 	// normally return if the process could be executed, so just force it to return
 	// an error.
 	rsyncError := fmt.Errorf("simulated error")
 
-	mockRsync.EXPECT().Exec(gomock.Any(), args).Return(rsyncError)
+	mockRsync.EXPECT().Exec(gomock.Any(), ArgvMatcher{expectedArgv}).Return(rsyncError)
+
+	got := Main(append([]string{"exodus-rsync"}, expectedArgv...))
+
+	if got != 14 {
+		t.Error("returned incorrect exit code", got)
+	}
+}
+
+func TestMainExecRsyncMode(t *testing.T) {
+	SetConfig(t, `
+gwcert: $HOME/certs/$USER.crt
+gwkey: $HOME/certs/$USER.key
+gwurl: https://exodus-gw.example.com/
+
+environments:
+- prefix: exodus
+  gwenv: best-env
+  rsyncmode: exodus
+
+- prefix: legacy
+  gwenv: legacy-env
+  rsyncmode: rsync
+`)
+
+	ctrl := MockController(t)
+
+	mockRsync := rsync.NewMockInterface(ctrl)
+	ext.rsync = mockRsync
+
+	// An environment in 'rsync' mode should behave as if no environment matched,
+	// passing through all arguments other than those specific to exodus-rsync.
+	rawArgs := []string{
+		"exodus-rsync", "-avY", "--exodus-publish", "abc", "--no-motd",
+		"--some-future-option=1", ".", "legacy:/foo/bar",
+	}
+	expectedArgv := []string{
+		"-avY", "--no-motd", "--some-future-option=1", ".", "legacy:/foo/bar",
+	}
+
+	mockRsync.EXPECT().Exec(gomock.Any(), ArgvMatcher{expectedArgv}).Return(fmt.Errorf("simulated error"))
+
+	if got := Main(rawArgs); got != 14 {
+		t.Error("returned incorrect exit code", got)
+	}
+}
+
+func TestMainExecRsyncDiag(t *testing.T) {
+	SetConfig(t, CONFIG)
+
+	ctrl := MockController(t)
+
+	mockDiag := diag.NewMockInterface(ctrl)
+	mockRsync := rsync.NewMockInterface(ctrl)
+	ext.diag = mockDiag
+	ext.rsync = mockRsync
+
+	// Diagnostic mode should be invoked even though DEST isn't handled by
+	// exodus, and then rsync should be run as usual.
+	expectedArgv := []string{"--some-future-option", ".", "some-dest:/foo/bar"}
+
+	mockDiag.EXPECT().Run(gomock.Any(), gomock.Any(), ArgvMatcher{expectedArgv})
+	mockRsync.EXPECT().Exec(gomock.Any(), ArgvMatcher{expectedArgv}).Return(fmt.Errorf("simulated error"))
 
 	got := Main([]string{
-		"exodus-rsync", "--recursive", "--timeout", "1234",
-		".", "some-dest:/foo/bar",
+		"exodus-rsync", "--exodus-diag", "--some-future-option", ".", "some-dest:/foo/bar",
 	})
 
-	if got != 94 {
+	if got != 14 {
 		t.Error("returned incorrect exit code", got)
 	}
 }
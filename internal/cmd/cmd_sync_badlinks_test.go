@@ -35,7 +35,7 @@ func TestMainSyncBadLinks(t *testing.T) {
 		got := Main(args)
 
 		// It should fail
-		if got != 73 {
+		if got != 23 {
 			t.Errorf("got unexpected exit code = %v", got)
 		}
 
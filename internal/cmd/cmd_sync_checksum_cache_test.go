@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/cache"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+// Points the XDG cache directory at a temporary directory for the duration
+// of the current test.
+func setCacheHome(t *testing.T) {
+	old, hadOld := os.LookupEnv("XDG_CACHE_HOME")
+	t.Cleanup(func() {
+		if hadOld {
+			os.Setenv("XDG_CACHE_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CACHE_HOME")
+		}
+		xdg.Reload()
+	})
+
+	os.Setenv("XDG_CACHE_HOME", t.TempDir())
+	xdg.Reload()
+}
+
+func cacheLen(t *testing.T) int {
+	c, err := cache.Open(cache.DefaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Len()
+}
+
+func TestMainSyncChecksumCache(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	tests := []struct {
+		name     string
+		config   string
+		args     []string
+		expected int
+	}{
+		{"disabled", CONFIG, nil, 0},
+		{"enabled", CONFIG + "checksumcache: true\n", nil, 3},
+		{"bypassed", CONFIG + "checksumcache: true\n", []string{"--exodus-no-checksum-cache"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheHome(t)
+			SetConfig(t, tt.config)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			args := append([]string{"rsync"}, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/some/target")
+
+			if got := Main(args); got != 0 {
+				t.Fatal("returned incorrect exit code", got)
+			}
+
+			// It should have published as usual.
+			if len(client.blobs) != 2 {
+				t.Errorf("unexpected blobs: %v", client.blobs)
+			}
+
+			if got := cacheLen(t); got != tt.expected {
+				t.Errorf("checksum cache has %v entries, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMainChecksumCacheCommands(t *testing.T) {
+	setCacheHome(t)
+
+	dir := t.TempDir()
+	c, err := cache.Open(cache.DefaultPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kept", "deleted"} {
+		file := path.Join(dir, name)
+		if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Store(file, info, name)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path.Join(dir, "deleted")); err != nil {
+		t.Fatal(err)
+	}
+
+	// No config, SRC or DEST is needed for these commands.
+	if got := Main([]string{"rsync", "--exodus-prune-checksum-cache"}); got != 0 {
+		t.Error("prune returned incorrect exit code", got)
+	}
+	if got := cacheLen(t); got != 1 {
+		t.Errorf("checksum cache has %v entries after prune, expected 1", got)
+	}
+
+	if got := Main([]string{"rsync", "--exodus-clear-checksum-cache"}); got != 0 {
+		t.Error("clear returned incorrect exit code", got)
+	}
+	if got := cacheLen(t); got != 0 {
+		t.Errorf("checksum cache has %v entries after clear, expected 0", got)
+	}
+}
+
+func TestMainChecksumCacheUnreadable(t *testing.T) {
+	setCacheHome(t)
+
+	// Make the cache path a file, so it can't be read or written.
+	if err := os.MkdirAll(path.Dir(cache.DefaultPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cache.DefaultPath(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Main([]string{"rsync", "--exodus-prune-checksum-cache"}); got != exitFileIO {
+		t.Error("returned incorrect exit code", got)
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+const daemonDestConfig = `
+environments:
+- prefix: mirror.example.com
+  gwenv: mirror-env
+  modules:
+    pub: /content/dist
+
+- prefix: mirror.example.com::beta
+  gwenv: beta-env
+`
+
+func TestMainSyncDaemonDest(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, daemonDestConfig)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	tests := []struct {
+		name  string
+		dest  string
+		gwenv string
+		want  []string
+	}{
+		{"daemon dest with mapped module", "mirror.example.com::pub/rhel", "mirror-env", []string{
+			"/content/dist/rhel/just-files/hello-copy-one",
+			"/content/dist/rhel/just-files/hello-copy-two",
+			"/content/dist/rhel/just-files/subdir/some-binary",
+		}},
+		{"daemon url with mapped module", "rsync://user@mirror.example.com:873/pub/rhel/", "mirror-env", []string{
+			"/content/dist/rhel/just-files/hello-copy-one",
+			"/content/dist/rhel/just-files/hello-copy-two",
+			"/content/dist/rhel/just-files/subdir/some-binary",
+		}},
+		{"daemon dest with unmapped module", "mirror.example.com::beta/rhel", "beta-env", []string{
+			"/beta/rhel/just-files/hello-copy-one",
+			"/beta/rhel/just-files/hello-copy-two",
+			"/beta/rhel/just-files/subdir/some-binary",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{tt.gwenv}).Return(&client, nil)
+
+			got := Main([]string{"rsync", srcPath, tt.dest})
+			if got != 0 {
+				t.Fatalf("returned incorrect exit code %v", got)
+			}
+
+			var published []string
+			for _, item := range client.publishes[0].items {
+				published = append(published, item.WebURI)
+			}
+			sort.Strings(published)
+
+			if !reflect.DeepEqual(published, tt.want) {
+				t.Errorf("did not publish expected items, published: %v", published)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncDeadline(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+	}{
+		{"from args", CONFIG, []string{"--exodus-deadline", "1"}},
+		{"from config", CONFIG + "\ndeadline: 1\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcPath := makeOutputTree(t)
+
+			logs := CaptureLogger(t)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			SetConfig(t, tt.config)
+
+			mockClient := gw.NewMockClient(ctrl)
+			mockGw.EXPECT().NewClient(gomock.Any(), gomock.Any()).Return(mockClient, nil)
+
+			mockClient.EXPECT().EnsureUploaded(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil).AnyTimes()
+
+			publish := gw.NewMockPublish(ctrl)
+			mockClient.EXPECT().NewPublish(gomock.Any()).Return(publish, nil)
+			publish.EXPECT().ID().Return("test-publish").AnyTimes()
+			publish.EXPECT().AddItems(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			// Committing hangs until the deadline.
+			publish.EXPECT().Commit(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			args := append([]string{"rsync"}, tt.args...)
+			args = append(args, srcPath, "exodus:/dest")
+
+			if got := Main(args); got != 30 {
+				t.Error("returned incorrect exit code", got)
+			}
+
+			if FindEntry(logs, "can't commit publish") == nil {
+				t.Error("missing expected log message")
+			}
+		})
+	}
+}
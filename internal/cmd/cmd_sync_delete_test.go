@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncDelete(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	// Items published prior to the sync.
+	published := []string{
+		"/dest/hello-copy-one",
+		"/dest/keep.tmp",
+		"/dest/old-file",
+		"/dest/subdir/old",
+		"/dest/subdir/some-binary",
+		"/destination/unrelated",
+		"/other/unrelated",
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		deleted  []string
+	}{
+		{"delete", []string{"--delete"}, 0,
+			[]string{"/dest/keep.tmp", "/dest/old-file", "/dest/subdir/old"}},
+
+		{"excluded are kept", []string{"--delete", "--exclude", "*.tmp"}, 0,
+			[]string{"/dest/old-file", "/dest/subdir/old"}},
+
+		{"excluded dir is kept", []string{"--delete", "--exclude", "subdir/"}, 0,
+			[]string{"/dest/keep.tmp", "/dest/old-file"}},
+
+		{"delete excluded", []string{"--delete-excluded", "--exclude", "*.tmp"}, 0,
+			[]string{"/dest/keep.tmp", "/dest/old-file", "/dest/subdir/old"}},
+
+		{"protect rule", []string{"--delete-excluded", "--filter", "P *.tmp"}, 0,
+			[]string{"/dest/old-file", "/dest/subdir/old"}},
+
+		{"hide rule doesn't protect", []string{"--delete", "--filter", "H *.tmp"}, 0,
+			[]string{"/dest/keep.tmp", "/dest/old-file", "/dest/subdir/old"}},
+
+		{"skipped due to size are kept", []string{"--delete", "--max-size=100", "--min-size=10"}, 0,
+			[]string{"/dest/keep.tmp", "/dest/old-file", "/dest/subdir/old"}},
+
+		{"max delete", []string{"--delete", "--max-delete=2"}, 25,
+			[]string{"/dest/keep.tmp", "/dest/old-file"}},
+
+		{"max delete zero", []string{"--delete", "--max-delete=0"}, 25,
+			nil},
+
+		{"no delete", []string{}, 0,
+			nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			client := FakeClient{blobs: make(map[string]string), published: published}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			args := []string{"rsync"}
+			args = append(args, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/dest")
+
+			got := Main(args)
+
+			if got != tt.exitCode {
+				t.Error("returned incorrect exit code", got)
+			}
+
+			// Deletions should have been added onto the same publish as everything
+			// else, which should then be committed.
+			if len(client.publishes) != 1 {
+				t.Fatal("expected to create 1 publish, instead created", len(client.publishes))
+			}
+
+			p := client.publishes[0]
+
+			var deleted []string
+			for _, item := range p.items {
+				if item.ObjectKey == "absent" {
+					deleted = append(deleted, item.WebURI)
+				}
+			}
+
+			if !reflect.DeepEqual(deleted, tt.deleted) {
+				t.Errorf("unexpected deletions: %v", deleted)
+			}
+
+			if p.committed != 1 {
+				t.Error("expected to commit publish (once), instead p.committed ==", p.committed)
+			}
+		})
+	}
+}
+
+func TestMainSyncDeleteDryRun(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG)
+	logs := CaptureLogger(t)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := FakeClient{
+		blobs:     make(map[string]string),
+		published: []string{"/dest/old-file", "/dest/subdir/old"},
+	}
+	mockGw.EXPECT().NewDryRunClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", "--dry-run", "--delete", srcPath + "/", "exodus:/dest"})
+
+	if got != 0 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	// It should tell us what would have been deleted.
+	var deleted []string
+	for _, entry := range logs.Entries {
+		if entry.Message == "Would delete" {
+			deleted = append(deleted, entry.Fields["uri"].(string))
+		}
+	}
+
+	if !reflect.DeepEqual(deleted, []string{"/dest/old-file", "/dest/subdir/old"}) {
+		t.Errorf("did not log expected deletions, got: %v", deleted)
+	}
+}
+
+func TestMainSyncDeleteFilesFrom(t *testing.T) {
+	SetConfig(t, CONFIG)
+	logs := CaptureLogger(t)
+
+	got := Main([]string{"rsync", "--delete", "--files-from", "sources.txt", ".", "exodus:/dest"})
+
+	// This combination is not supported.
+	if got != 4 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	if FindEntry(logs, "--delete is not supported with --files-from") == nil {
+		t.Error("missing expected log message")
+	}
+}
+
+// noListClient is a FakeClient for an exodus-gw which can't list published
+// items.
+type noListClient struct {
+	FakeClient
+}
+
+func (c *noListClient) ListPublished(ctx context.Context, prefix string) ([]string, error) {
+	return nil, &gw.HTTPError{StatusCode: 404, Status: "404 Not Found"}
+}
+
+func TestMainSyncDeleteNotEnabled(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, `
+environments:
+- prefix: exodus
+  gwenv: best-env
+`)
+	logs := CaptureLogger(t)
+	ctrl := MockController(t)
+
+	// No client should be created, as nothing can be done.
+	ext.gw = gw.NewMockInterface(ctrl)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", "--delete", srcPath + "/", "exodus:/dest"})
+
+	if got != 4 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	entry := FindEntry(logs, "--delete requires an exodus-gw server providing the published-items API (see 'gwpublisheditems')")
+	if entry == nil {
+		t.Error("missing expected log message")
+	}
+}
+
+func TestMainSyncDeleteUnsupported(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG)
+	logs := CaptureLogger(t)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := noListClient{FakeClient{blobs: make(map[string]string)}}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", "--delete", srcPath + "/", "exodus:/dest"})
+
+	if got != 4 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	if FindEntry(logs, "--delete is not supported by this exodus-gw server") == nil {
+		t.Error("missing expected log message")
+	}
+
+	// It should have failed before making any changes.
+	if len(client.blobs) != 0 || len(client.publishes) != 0 {
+		t.Errorf("unexpected changes: blobs %v, publishes %v", client.blobs, client.publishes)
+	}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncFilesFromStdin(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	// With -R implied, the full source path is retained.
+	rel := func(name string) string {
+		return path.Join("/dest", srcPath, name)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		list     string
+		expected []string
+	}{
+		{"lines", nil,
+			"hello-copy-one\n# hello-copy-two\n; hello-copy-two\n\n/subdir/some-binary\r\n",
+			[]string{rel("hello-copy-one"), rel("subdir/some-binary")}},
+
+		{"empty", []string{"-r"},
+			"# nothing listed\n\n",
+			nil},
+
+		{"from0", []string{"-0"},
+			"hello-copy-one\x00subdir/some-binary",
+			[]string{rel("hello-copy-one"), rel("subdir/some-binary")}},
+
+		{"dirs skipped", nil,
+			"subdir\nhello-copy-two\n",
+			[]string{rel("hello-copy-two")}},
+
+		{"dirs recursive", []string{"-r"},
+			"subdir\nhello-copy-two\nsubdir/some-binary\n",
+			[]string{rel("hello-copy-two"), rel("subdir/some-binary")}},
+
+		{"no relative", []string{"-r", "--no-relative"},
+			"subdir\nhello-copy-two\n",
+			[]string{"/dest/hello-copy-two", "/dest/subdir/some-binary"}},
+
+		{"no relative within dir", []string{"--no-relative"},
+			"subdir/some-binary\n",
+			[]string{"/dest/some-binary"}},
+
+		{"no relative root", []string{"-r", "--no-relative"},
+			".\n",
+			[]string{"/dest/hello-copy-one", "/dest/hello-copy-two", "/dest/subdir/some-binary"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+			ext.stdin = strings.NewReader(tt.list)
+
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			args := append([]string{"rsync", "--files-from=-"}, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/dest")
+
+			if got := Main(args); got != 0 {
+				t.Fatal("returned incorrect exit code", got)
+			}
+
+			var published []string
+			for _, item := range client.publishes[0].items {
+				published = append(published, item.WebURI)
+			}
+			sort.Strings(published)
+
+			if !reflect.DeepEqual(published, tt.expected) {
+				t.Errorf("unexpected items published: %v", published)
+			}
+		})
+	}
+}
+
+func TestMainSyncFilesFromErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     string
+		code     int
+		expected string
+	}{
+		{"outside of src", "file\n../file\n", 11, "can't read --files-from file"},
+		{"missing", "missing\n", 23, "can't read files for sync"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfig(t, CONFIG)
+			logs := CaptureLogger(t)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+			ext.stdin = strings.NewReader(tt.list)
+
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			got := Main([]string{"rsync", "--files-from", "-", ".", "exodus:/dest"})
+
+			if got != tt.code {
+				t.Error("returned incorrect exit code", got)
+			}
+			if FindEntry(logs, tt.expected) == nil {
+				t.Error("missing expected log message")
+			}
+		})
+	}
+}
+
+func TestMainSyncMixedFilesFromStdin(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsync := &fakeRsync{delegate: ext.rsync}
+
+	// Instead of real rsync, output the content of the --files-from file.
+	rsync.prefix = []string{"/bin/sh", "-c",
+		`while [ "$1" != --files-from ]; do shift; done; echo "LISTED $(tr '\n' , < "$2")"`, "--"}
+
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+
+	logs := CaptureLogger(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+	ext.rsync = rsync
+	ext.stdin = strings.NewReader("hello-copy-one\nsubdir/some-binary\n")
+
+	client := FakeClient{blobs: make(map[string]string)}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", "--files-from", "-", "--no-relative", srcPath + "/", "exodus-mixed:/dest"})
+	if got != 0 {
+		t.Fatal("returned incorrect exit code", got)
+	}
+
+	// rsync should have received the same list as exodus.
+	if FindEntry(logs, "LISTED hello-copy-one,subdir/some-binary,") == nil {
+		t.Error("rsync did not receive the list")
+	}
+
+	var published []string
+	for _, item := range client.publishes[0].items {
+		published = append(published, item.WebURI)
+	}
+	sort.Strings(published)
+
+	if !reflect.DeepEqual(published, []string{"/dest/hello-copy-one", "/dest/some-binary"}) {
+		t.Errorf("unexpected items published: %v", published)
+	}
+}
@@ -1,9 +1,10 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
-	"strings"
+	"path"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -11,23 +12,21 @@ import (
 )
 
 func TestMainSyncIgnoreExisting(t *testing.T) {
-	SetConfig(t, CONFIG)
-
-	logs := CaptureLogger(t)
-
 	ctrl := MockController(t)
 
 	mockGw := gw.NewMockInterface(ctrl)
 	ext.gw = mockGw
 
-	client := FakeClient{blobs: make(map[string]string)}
-
 	t.Run("ignore-existing OK if no files", func(t *testing.T) {
+		SetConfig(t, CONFIG)
+
 		os.Mkdir("nofiles", 0755)
 		os.Mkdir("nofiles/subdir", 0755)
 
 		args := []string{"rsync", "--ignore-existing", "nofiles", "exodus:/some/target"}
 
+		// Even without 'cdnurl', an empty tree can be synced.
+		client := FakeClient{blobs: make(map[string]string), noCdn: true}
 		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
 		got := Main(args)
 
@@ -37,30 +36,149 @@ func TestMainSyncIgnoreExisting(t *testing.T) {
 		}
 	})
 
-	t.Run("ignore-existing fails if files exist", func(t *testing.T) {
+	t.Run("ignore-existing skips published files", func(t *testing.T) {
+		SetConfig(t, CONFIG)
+
 		os.Mkdir("files", 0755)
 		os.WriteFile("files/file1", []byte("hello"), 0644)
+		os.WriteFile("files/file2", []byte("world"), 0644)
 
 		args := []string{"rsync", "--ignore-existing", "files", "exodus:/some/target"}
 
+		client := FakeClient{
+			blobs:     make(map[string]string),
+			published: []string{"/some/target/files/file1", "/some/target/other/file2"},
+		}
 		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
 		got := Main(args)
 
-		// It should fail
-		if got != 73 {
+		if got != 0 {
+			t.Fatalf("got unexpected exit code = %v", got)
+		}
+
+		// It should have checked each file.
+		sort.Strings(client.checked)
+		if !reflect.DeepEqual(client.checked, []string{
+			"/some/target/files/file1", "/some/target/files/file2",
+		}) {
+			t.Errorf("unexpected URIs checked: %v", client.checked)
+		}
+
+		// It should have published only the file which wasn't already published.
+		var uris []string
+		for _, item := range client.publishes[0].items {
+			uris = append(uris, item.WebURI)
+		}
+		sort.Strings(uris)
+
+		if !reflect.DeepEqual(uris, []string{"/some/target/files/file2"}) {
+			t.Errorf("unexpected items published: %v", uris)
+		}
+
+		// It shouldn't have uploaded the existing file.
+		if len(client.blobs) != 1 {
+			t.Errorf("unexpected blobs uploaded: %v", client.blobs)
+		}
+	})
+
+	t.Run("ignore-existing fails without cdnurl", func(t *testing.T) {
+		logs := CaptureLogger(t)
+
+		SetConfig(t, CONFIG)
+
+		os.Mkdir("files", 0755)
+		os.WriteFile("files/file1", []byte("hello"), 0644)
+
+		args := []string{"rsync", "--ignore-existing", "files", "exodus:/some/target"}
+
+		client := FakeClient{blobs: make(map[string]string), noCdn: true}
+		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+		got := Main(args)
+
+		// It should fail as unsupported.
+		if got != 4 {
 			t.Errorf("got unexpected exit code = %v", got)
 		}
 
 		// It should tell us why
-		entry := FindEntry(logs, "can't read files for sync")
-		if entry == nil {
-			t.Error("missing expected log message")
+		entry := FindEntry(logs, "can't check for existing items")
+		if entry == nil || entry.Fields["error"] != gw.ErrNoCdnURL {
+			t.Errorf("missing expected log message, got: %v", entry)
+		}
+
+		// Nothing should have been uploaded.
+		if len(client.blobs) != 0 {
+			t.Errorf("unexpected blobs uploaded: %v", client.blobs)
+		}
+	})
+
+	t.Run("ignore-existing fails if can't check CDN", func(t *testing.T) {
+		logs := CaptureLogger(t)
+
+		SetConfig(t, CONFIG)
+
+		os.Mkdir("files", 0755)
+		os.WriteFile("files/file1", []byte("hello"), 0644)
+
+		args := []string{"rsync", "--ignore-existing", "files/", "exodus:/broken/"}
+
+		client := FakeClient{blobs: make(map[string]string)}
+		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+		got := Main(args)
+
+		// It should fail
+		if got != 23 {
+			t.Errorf("got unexpected exit code = %v", got)
 		}
 
-		err := fmt.Sprint(entry.Fields["error"])
-		if !strings.Contains(err, "--ignore-existing is not supported") {
-			t.Error("unexpected error message", err)
+		// It should tell us why
+		if FindEntry(logs, "can't check for existing items") == nil {
+			t.Error("missing expected log message")
 		}
 	})
+}
+
+func TestMainSyncIgnoreExistingDelete(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	SetConfig(t, CONFIG)
+
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := FakeClient{
+		blobs:     make(map[string]string),
+		published: []string{"/dest/hello-copy-one", "/dest/old-file"},
+	}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	got := Main([]string{"rsync", "--ignore-existing", "--delete", srcPath + "/", "exodus:/dest"})
+	if got != 0 {
+		t.Fatalf("got unexpected exit code = %v", got)
+	}
+
+	var published, deleted []string
+	for _, item := range client.publishes[0].items {
+		if item.ObjectKey == "absent" {
+			deleted = append(deleted, item.WebURI)
+		} else {
+			published = append(published, item.WebURI)
+		}
+	}
+	sort.Strings(published)
+
+	// The existing file should be neither published again, nor deleted.
+	if !reflect.DeepEqual(published, []string{"/dest/hello-copy-two", "/dest/subdir/some-binary"}) {
+		t.Errorf("unexpected items published: %v", published)
+	}
+	if !reflect.DeepEqual(deleted, []string{"/dest/old-file"}) {
+		t.Errorf("unexpected deletions: %v", deleted)
+	}
 }
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncListOnly(t *testing.T) {
+	srcPath := makeOutputTree(t)
+
+	mtime := time.Date(2021, 11, 2, 10, 11, 12, 0, time.Local)
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(srcPath, name)
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"rsync format", []string{"--list-only", "-v"}, []string{
+			"-rw-r--r--              1 2021/11/02 10:11:12 /dest/a",
+			"-rw-r--r--              1 2021/11/02 10:11:12 /dest/b",
+			"-rw-r--r--              1 2021/11/02 10:11:12 /dest/c",
+		}},
+
+		{"json", []string{"--exodus-list-json", "--links", "--delete"}, []string{
+			`{"web_uri":"/dest/a","object_key":"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",` +
+				`"src_path":"` + filepath.Join(srcPath, "a") + `","size":1}`,
+			`{"web_uri":"/dest/b","object_key":"3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d",` +
+				`"src_path":"` + filepath.Join(srcPath, "b") + `","size":1}`,
+			`{"web_uri":"/dest/c","link_to":"/dest/a",` +
+				`"src_path":"` + filepath.Join(srcPath, "c") + `","size":1}`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+			logs := CaptureLogger(t)
+
+			// No client is expected to be created, as nothing is published.
+			ext.gw = gw.NewMockInterface(ctrl)
+
+			stdout := &bytes.Buffer{}
+			ext.stdout = stdout
+
+			args := append([]string{"rsync"}, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/dest")
+
+			if got := Main(args); got != 0 {
+				t.Fatal("returned incorrect exit code", got)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			sort.Strings(lines)
+
+			if !reflect.DeepEqual(lines, tt.expected) {
+				t.Errorf("unexpected output: %q", lines)
+			}
+
+			// Nothing should claim to have been uploaded or published.
+			for _, entry := range logs.Entries {
+				switch entry.Message {
+				case "Created publish", "Joining publish", "Completed uploads", "Added publish items":
+					t.Errorf("unexpected log message: %v %v", entry.Message, entry.Fields)
+				}
+			}
+			if FindEntry(logs, "Completed successfully (in list-only mode - nothing published)") == nil {
+				t.Error("missing expected log message")
+			}
+		})
+	}
+}
+
+func TestMainSyncListJSONProgress(t *testing.T) {
+	srcPath := makeOutputTree(t)
+
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+
+	ext.gw = gw.NewMockInterface(ctrl)
+
+	stdout := &bytes.Buffer{}
+	ext.stdout = stdout
+
+	got := Main([]string{"rsync", "--exodus-list-json", "-avzP", srcPath + "/", "exodus:/dest"})
+	if got != 0 {
+		t.Fatal("returned incorrect exit code", got)
+	}
+
+	// Progress shouldn't be mixed into the output, which should consist only
+	// of a JSON object for each item.
+	var uris []string
+	dec := json.NewDecoder(stdout)
+	for dec.More() {
+		item := struct {
+			WebURI string `json:"web_uri"`
+		}{}
+		if err := dec.Decode(&item); err != nil {
+			t.Fatalf("can't parse output: %v", err)
+		}
+		uris = append(uris, item.WebURI)
+	}
+	sort.Strings(uris)
+
+	if !reflect.DeepEqual(uris, []string{"/dest/a", "/dest/b", "/dest/c"}) {
+		t.Errorf("unexpected items in output: %v", uris)
+	}
+}
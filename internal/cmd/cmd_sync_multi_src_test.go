@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncMultipleSrc(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := FakeClient{
+		blobs: make(map[string]string),
+		published: []string{
+			"/dest/just-files/old",
+			"/dest/old",
+			"/dest/rand1",
+			"/dest/some.conf",
+		},
+	}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcTrees := path.Clean(wd + "/../../test/data/srctrees")
+
+	got := Main([]string{"rsync", "--delete",
+		// Without trailing slash: published beneath the directory name.
+		srcTrees + "/just-files",
+		// With trailing slash: contents published directly into dest.
+		srcTrees + "/links/subdir/",
+		// A single file.
+		srcTrees + "/some.conf",
+		"exodus:/dest",
+	})
+
+	if got != 0 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	// Everything should have gone into a single publish.
+	if len(client.publishes) != 1 {
+		t.Fatal("expected to create 1 publish, instead created", len(client.publishes))
+	}
+
+	p := client.publishes[0]
+
+	var published, deleted []string
+	for _, item := range p.items {
+		if item.ObjectKey == "absent" {
+			deleted = append(deleted, item.WebURI)
+		} else {
+			published = append(published, item.WebURI)
+		}
+	}
+	sort.Strings(published)
+
+	expectedPublished := []string{
+		"/dest/just-files/hello-copy-one",
+		"/dest/just-files/hello-copy-two",
+		"/dest/just-files/subdir/some-binary",
+		"/dest/rand1",
+		"/dest/rand2",
+		"/dest/regular-file",
+		"/dest/some.conf",
+	}
+	if !reflect.DeepEqual(published, expectedPublished) {
+		t.Errorf("did not publish expected items, published: %v", published)
+	}
+
+	// Only items not found in any source should be deleted.
+	expectedDeleted := []string{"/dest/just-files/old", "/dest/old"}
+	if !reflect.DeepEqual(deleted, expectedDeleted) {
+		t.Errorf("unexpected deletions: %v", deleted)
+	}
+
+	if p.committed != 1 {
+		t.Error("expected to commit publish (once), instead p.committed ==", p.committed)
+	}
+}
+
+func TestMainSyncMultipleSrcFilesFrom(t *testing.T) {
+	SetConfig(t, CONFIG)
+	logs := CaptureLogger(t)
+
+	got := Main([]string{"rsync", "--files-from", "sources.txt", "src1", "src2", "exodus:/dest"})
+
+	// This combination is not supported.
+	if got != 1 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	if FindEntry(logs, "--files-from requires a single SRC") == nil {
+		t.Error("missing expected log message")
+	}
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+// Creates a source tree for output tests, returning its path.
+func makeOutputTree(t *testing.T) string {
+	root := t.TempDir()
+
+	for name, content := range map[string]string{"a": "a", "b": "b"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a", filepath.Join(root, "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestMainSyncOutput(t *testing.T) {
+	pid := fmt.Sprint(os.Getpid())
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"quiet", nil, nil},
+
+		{"verbose", []string{"-v"}, []string{
+			"a",
+			"b",
+			"c -> a",
+			"deleting old",
+		}},
+
+		{"itemize", []string{"-i"}, []string{
+			"*deleting   old",
+			".f          b",
+			"<f+++++++++ a",
+			"cL+++++++++ c -> a",
+		}},
+
+		{"out-format", []string{"-v", "--out-format", "%o %-3n|%3l|%i"}, []string{
+			"del. old|  0|*deleting  ",
+			"send a  |  1|<f+++++++++",
+			"send b  |  1|.f         ",
+			"send c  |  1|cL+++++++++",
+		}},
+
+		{"out-format with other escapes", []string{"--out-format", "%n|%b|%c|%'l|%B|%p|%a%h%m%P%u"}, []string{
+			"a|1|0|1|rw-r--r--|" + pid + "|",
+			"b|0|0|1|rw-r--r--|" + pid + "|",
+			"c|0|0|1|rwxrwxrwx|" + pid + "|",
+			"deleting old",
+		}},
+
+		{"out-format without itemize", []string{"--out-format=[%n]"}, []string{
+			"[a]",
+			"[b]",
+			"[c]",
+			"deleting old",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcPath := makeOutputTree(t)
+
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			stdout := &bytes.Buffer{}
+			ext.stdout = stdout
+
+			// Content of "b" is already present.
+			client := FakeClient{
+				blobs:     map[string]string{fmt.Sprintf("%x", sha256.Sum256([]byte("b"))): "b"},
+				published: []string{"/dest/old"},
+			}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			args := append([]string{"rsync", "--links", "--delete"}, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/dest")
+
+			if got := Main(args); got != 0 {
+				t.Fatal("returned incorrect exit code", got)
+			}
+
+			// Items are processed concurrently, so order is not stable.
+			var lines []string
+			if stdout.Len() > 0 {
+				lines = strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			}
+			sort.Strings(lines)
+
+			if !reflect.DeepEqual(lines, tt.expected) {
+				t.Errorf("unexpected output: %q", lines)
+			}
+		})
+	}
+}
+
+func TestMainSyncLogFileFormat(t *testing.T) {
+	srcPath := makeOutputTree(t)
+
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+	logs := CaptureLogger(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	stdout := &bytes.Buffer{}
+	ext.stdout = stdout
+
+	client := FakeClient{blobs: make(map[string]string)}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	got := Main([]string{"rsync", "--log-file-format", "%o %f", srcPath + "/", "exodus:/dest"})
+	if got != 0 {
+		t.Fatal("returned incorrect exit code", got)
+	}
+
+	// Items should be logged, but not written to stdout.
+	if FindEntry(logs, "send "+filepath.Join(srcPath, "a")) == nil {
+		t.Error("item was not logged")
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output: %s", stdout.String())
+	}
+}
+
+func TestOutputItemTime(t *testing.T) {
+	item := outputItem{name: "a"}
+
+	got := args.OutFormat("%t %n").Expand(item.value)
+	if !regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d a$`).MatchString(got) {
+		t.Errorf("unexpected output: %q", got)
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// A client which counts calls to EnsureUploaded.
+type countingClient struct {
+	FakeClient
+	uploadCalls int
+}
+
+func (c *countingClient) EnsureUploaded(ctx context.Context, items []walk.SyncItem,
+	onUploaded func(walk.SyncItem) error,
+	onExisting func(walk.SyncItem) error,
+) error {
+	c.uploadCalls++
+	return c.FakeClient.EnsureUploaded(ctx, items, onUploaded, onExisting)
+}
+
+// A publish which fails on the Nth call to AddItems.
+type failingPublish struct {
+	FakePublish
+	calls  int
+	failOn int
+}
+
+func (p *failingPublish) AddItems(ctx context.Context, items []gw.ItemInput) error {
+	p.calls++
+	if p.calls == p.failOn {
+		return fmt.Errorf("simulated error")
+	}
+	return p.FakePublish.AddItems(ctx, items)
+}
+
+func TestMainSyncInBatches(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG+"gwbatchsize: 1\n")
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := countingClient{FakeClient: FakeClient{blobs: make(map[string]string)}}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", srcPath + "/", "exodus:/some/target"})
+
+	// It should complete successfully.
+	if got != 0 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	// Each item should have been uploaded in its own batch.
+	if client.uploadCalls != 3 {
+		t.Error("expected 3 calls to EnsureUploaded, got", client.uploadCalls)
+	}
+
+	// All items should still end up on a single publish, committed once.
+	if len(client.publishes) != 1 {
+		t.Fatal("expected to create 1 publish, instead created", len(client.publishes))
+	}
+
+	p := client.publishes[0]
+	if len(p.items) != 3 {
+		t.Error("expected 3 items on publish, got", p.items)
+	}
+	if p.committed != 1 {
+		t.Error("expected to commit publish (once), instead p.committed ==", p.committed)
+	}
+}
+
+func TestMainSyncBatchFailsNoCommit(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG+"gwbatchsize: 1\n")
+	logs := CaptureLogger(t)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := gw.NewMockClient(ctrl)
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(client, nil)
+
+	client.EXPECT().EnsureUploaded(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).AnyTimes()
+
+	// Adding the second batch fails; the publish must then never be committed.
+	publish := &failingPublish{FakePublish: FakePublish{id: "some-publish"}, failOn: 2}
+	client.EXPECT().NewPublish(gomock.Any()).Return(publish, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", srcPath + "/", "exodus:/some/target"})
+
+	if got != 23 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	if FindEntry(logs, "can't add items to publish") == nil {
+		t.Error("missing expected log message")
+	}
+
+	if publish.committed != 0 {
+		t.Error("publish was unexpectedly committed")
+	}
+}
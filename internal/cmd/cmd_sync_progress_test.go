@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected func(srcPath string) []string
+	}{
+		{"per-file", []string{"-P"}, func(srcPath string) []string {
+			return []string{
+				"hashing " + filepath.Join(srcPath, "a") + "\n",
+				"hashing " + filepath.Join(srcPath, "b") + "\n",
+				"(xfr#0, ir-chk=0/",
+			}
+		}},
+
+		{"total", []string{"--info=progress2"}, func(string) []string {
+			return []string{"100%", ", to-chk=0/3)\n"}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcPath := makeOutputTree(t)
+
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			stdout := &bytes.Buffer{}
+			ext.stdout = stdout
+
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			args := append([]string{"rsync"}, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/dest")
+
+			if got := Main(args); got != 0 {
+				t.Fatal("returned incorrect exit code", got)
+			}
+
+			for _, want := range tt.expected(srcPath) {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("missing %q in output:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
+
+func TestMainSyncNoProgress(t *testing.T) {
+	srcPath := makeOutputTree(t)
+
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	stdout := &bytes.Buffer{}
+	ext.stdout = stdout
+
+	client := FakeClient{blobs: make(map[string]string)}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	got := Main([]string{"rsync", "--progress", "--info=progress0", srcPath + "/", "exodus:/dest"})
+	if got != 0 {
+		t.Fatal("returned incorrect exit code", got)
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output: %s", stdout.String())
+	}
+}
@@ -33,7 +33,7 @@ func TestMainSyncUnreadableFile(t *testing.T) {
 	got := Main(args)
 
 	// It should fail.
-	if got != 73 {
+	if got != 23 {
 		t.Error("returned incorrect exit code", got)
 	}
 
@@ -67,7 +67,7 @@ func TestMainSyncUnreadableFilesFrom(t *testing.T) {
 	got := Main(args)
 
 	// It should fail.
-	if got != 73 {
+	if got != 11 {
 		t.Error("returned incorrect exit code", got)
 	}
 
@@ -105,7 +105,7 @@ func TestMainSyncUnreadableDir(t *testing.T) {
 	got := Main(args)
 
 	// It should fail.
-	if got != 73 {
+	if got != 23 {
 		t.Error("returned incorrect exit code", got)
 	}
 
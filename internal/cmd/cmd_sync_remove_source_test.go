@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// failCommitClient is a FakeClient whose publishes can't be committed.
+type failCommitClient struct {
+	FakeClient
+}
+
+type failCommitPublish struct {
+	FakePublish
+}
+
+func (c *failCommitClient) NewPublish(ctx context.Context) (gw.Publish, error) {
+	return &failCommitPublish{FakePublish{id: "some-publish"}}, nil
+}
+
+func (p *failCommitPublish) Commit(ctx context.Context) error {
+	return fmt.Errorf("simulated error")
+}
+
+// touchingClient is a FakeClient which modifies a source file while it's
+// being uploaded.
+type touchingClient struct {
+	FakeClient
+	touch string
+}
+
+func (c *touchingClient) EnsureUploaded(ctx context.Context, items []walk.SyncItem,
+	onUploaded func(walk.SyncItem) error,
+	onExisting func(walk.SyncItem) error,
+) error {
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(c.touch, later, later); err != nil {
+		return err
+	}
+	return c.FakeClient.EnsureUploaded(ctx, items, onUploaded, onExisting)
+}
+
+// makeSpool creates a source tree and returns the files within it.
+func makeSpool(t *testing.T) []string {
+	files := []string{"spool/file1", "spool/sub/file2", "spool/sub/deep/file3"}
+
+	if err := os.MkdirAll("spool/sub/deep", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if err := os.WriteFile(file, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return files
+}
+
+// remaining returns all files and directories within the spool.
+func remaining(t *testing.T) []string {
+	var out []string
+
+	for _, dir := range []string{"spool", "spool/sub", "spool/sub/deep"} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			out = append(out, dir+"/"+entry.Name())
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+func TestMainSyncRemoveSourceFiles(t *testing.T) {
+	SetConfig(t, CONFIG)
+
+	logs := CaptureLogger(t)
+
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	dirsOnly := []string{"spool/sub", "spool/sub/deep"}
+
+	t.Run("removes files after commit", func(t *testing.T) {
+		makeSpool(t)
+
+		client := FakeClient{blobs: make(map[string]string)}
+		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+		got := Main([]string{"rsync", "--remove-source-files", "spool", "exodus:/dest"})
+		if got != 0 {
+			t.Fatalf("got unexpected exit code = %v", got)
+		}
+
+		if client.publishes[0].committed != 1 {
+			t.Error("publish was not committed")
+		}
+
+		// Only the directories should remain.
+		if got := remaining(t); !reflect.DeepEqual(got, dirsOnly) {
+			t.Errorf("unexpected files remaining: %v", got)
+		}
+	})
+
+	t.Run("keeps files if commit fails", func(t *testing.T) {
+		files := makeSpool(t)
+
+		client := failCommitClient{FakeClient{blobs: make(map[string]string)}}
+		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+		got := Main([]string{"rsync", "--remove-source-files", "spool", "exodus:/dest"})
+		if got != 23 {
+			t.Fatalf("got unexpected exit code = %v", got)
+		}
+
+		want := append(files, dirsOnly...)
+		sort.Strings(want)
+		if got := remaining(t); !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected files remaining: %v", got)
+		}
+	})
+
+	t.Run("keeps files changed since checksum", func(t *testing.T) {
+		makeSpool(t)
+
+		client := touchingClient{FakeClient{blobs: make(map[string]string)}, "spool/sub/file2"}
+		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+		got := Main([]string{"rsync", "--remove-source-files", "spool", "exodus:/dest"})
+
+		// It should fail, as not every file could be removed.
+		if got != 23 {
+			t.Fatalf("got unexpected exit code = %v", got)
+		}
+
+		// The publish should still have been committed.
+		if client.publishes[0].committed != 1 {
+			t.Error("publish was not committed")
+		}
+
+		// Only the changed file should be kept.
+		want := []string{"spool/sub", "spool/sub/deep", "spool/sub/file2"}
+		if got := remaining(t); !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected files remaining: %v", got)
+		}
+
+		entry := FindEntry(logs, "Not removing source file changed since publish")
+		if entry == nil || entry.Fields["src"] != "spool/sub/file2" {
+			t.Errorf("missing expected log message, got: %v", entry)
+		}
+
+		os.Remove("spool/sub/file2")
+	})
+
+	t.Run("removes files after adding to joined publish", func(t *testing.T) {
+		makeSpool(t)
+
+		client := FakeClient{blobs: make(map[string]string)}
+		client.publishes = []FakePublish{{id: "joined-publish"}}
+		mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+		got := Main([]string{"rsync", "--remove-source-files", "--exodus-publish", "joined-publish",
+			"spool", "exodus:/dest"})
+		if got != 0 {
+			t.Fatalf("got unexpected exit code = %v", got)
+		}
+
+		// Items should have been added, without committing.
+		if len(client.publishes[0].items) != 3 || client.publishes[0].committed != 0 {
+			t.Errorf("unexpected publish state: %v", client.publishes[0])
+		}
+
+		if got := remaining(t); !reflect.DeepEqual(got, dirsOnly) {
+			t.Errorf("unexpected files remaining: %v", got)
+		}
+	})
+
+	t.Run("keeps files in dry-run mode", func(t *testing.T) {
+		files := makeSpool(t)
+
+		client := FakeClient{blobs: make(map[string]string)}
+		mockGw.EXPECT().NewDryRunClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+		got := Main([]string{"rsync", "--remove-source-files", "--dry-run", "spool", "exodus:/dest"})
+		if got != 0 {
+			t.Fatalf("got unexpected exit code = %v", got)
+		}
+
+		want := append(files, dirsOnly...)
+		sort.Strings(want)
+		if got := remaining(t); !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected files remaining: %v", got)
+		}
+	})
+}
+
+func TestMainSyncMixedRemoveSourceFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		code   int
+	}{
+		{"removes files after both succeed", `echo "$@"`, 0},
+		{"keeps files if rsync fails", `echo "$@"; exit 3`, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+
+			logs := CaptureLogger(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			oldRsync := ext.rsync
+			t.Cleanup(func() { ext.rsync = oldRsync })
+			ext.rsync = &fakeRsync{delegate: oldRsync, prefix: []string{"/bin/sh", "-c", tt.script, "--"}}
+
+			files := makeSpool(t)
+
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			got := Main([]string{"rsync", "--remove-source-files", "spool", "exodus-mixed:/dest"})
+			if got != tt.code {
+				t.Fatalf("got unexpected exit code = %v", got)
+			}
+
+			// rsync should have run, but not been asked to remove anything itself.
+			ranRsync := false
+			for _, entry := range logs.Entries {
+				if _, ok := entry.Fields["rsync"]; ok && strings.HasSuffix(entry.Message, "exodus-mixed:/dest") {
+					ranRsync = true
+					if strings.Contains(entry.Message, "--remove-source-files") {
+						t.Errorf("rsync was run with --remove-source-files: %v", entry.Message)
+					}
+				}
+			}
+			if !ranRsync {
+				t.Error("rsync did not run")
+			}
+
+			want := []string{"spool/sub", "spool/sub/deep"}
+			if tt.code != 0 {
+				want = append(files, want...)
+				sort.Strings(want)
+			}
+			if got := remaining(t); !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected files remaining: %v", got)
+			}
+		})
+	}
+}
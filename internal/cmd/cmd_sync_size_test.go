@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncSizeLimits(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"no limits", nil, []string{
+			"/dest/hello-copy-one", "/dest/hello-copy-two", "/dest/subdir/some-binary"}},
+
+		{"max size", []string{"--max-size=0.1K"}, []string{
+			"/dest/hello-copy-one", "/dest/hello-copy-two"}},
+
+		{"min size", []string{"--min-size", "7"}, []string{
+			"/dest/subdir/some-binary"}},
+
+		{"nothing in range", []string{"--min-size=7", "--max-size=199"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			args := append([]string{"rsync"}, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/dest")
+
+			if got := Main(args); got != 0 {
+				t.Fatal("returned incorrect exit code", got)
+			}
+
+			var published []string
+			for _, item := range client.publishes[0].items {
+				published = append(published, item.WebURI)
+			}
+			sort.Strings(published)
+
+			if !reflect.DeepEqual(published, tt.expected) {
+				t.Errorf("unexpected items published: %v", published)
+			}
+		})
+	}
+}
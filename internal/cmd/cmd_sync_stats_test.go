@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+func TestMainSyncStats(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	stdout := &bytes.Buffer{}
+	ext.stdout = stdout
+
+	client := FakeClient{blobs: make(map[string]string)}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", "--stats", srcPath + "/", "exodus:/some/target"})
+
+	if got != 0 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	output := stdout.String()
+
+	// The two "hello" files have the same content, so only one of them is
+	// uploaded.
+	for _, expected := range []string{
+		"Number of files: 3 (reg: 3, link: 0)\n",
+		"Number of regular files transferred: 2\n",
+		"Number of regular files already present: 1\n",
+		"Total file size: 212 bytes\n",
+		"Total transferred file size: 206 bytes\n",
+		"Number of publish items added: 3\n",
+		"Publish ID: some-publish\n",
+		"Walk and checksum time: ",
+		"Upload time: ",
+		"Add items time: ",
+		"Commit time: ",
+		"Total time: ",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("missing %q from output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestMainSyncNoStats(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	stdout := &bytes.Buffer{}
+	ext.stdout = stdout
+
+	client := FakeClient{blobs: make(map[string]string)}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/just-files")
+
+	got := Main([]string{"rsync", srcPath + "/", "exodus:/some/target"})
+
+	if got != 0 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	// Nothing should be written without --stats.
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output: %s", stdout.String())
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := map[int64]string{
+		0:        "0",
+		999:      "999",
+		1000:     "1,000",
+		123456:   "123,456",
+		1234567:  "1,234,567",
+		-1234567: "-1,234,567",
+	}
+
+	for n, expected := range tests {
+		if got := formatCount(n); got != expected {
+			t.Errorf("formatCount(%v) = %v, expected %v", n, got, expected)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -15,6 +16,8 @@ import (
 )
 
 const CONFIG string = `
+gwpublisheditems: true
+
 environments:
 - prefix: exodus
   gwenv: best-env
@@ -43,6 +46,12 @@ func (m EnvMatcher) String() string {
 type FakeClient struct {
 	blobs     map[string]string
 	publishes []FakePublish
+	published []string
+
+	// URIs checked by ArePublished, which fails as if 'cdnurl' isn't
+	// configured if noCdn is true.
+	checked []string
+	noCdn   bool
 }
 
 type FakePublish struct {
@@ -91,6 +100,34 @@ func (c *FakeClient) GetPublish(id string) gw.Publish {
 	return &BrokenPublish{id: id}
 }
 
+func (c *FakeClient) ListPublished(ctx context.Context, prefix string) ([]string, error) {
+	var out []string
+	for _, uri := range c.published {
+		if strings.HasPrefix(uri, prefix) {
+			out = append(out, uri)
+		}
+	}
+	return out, nil
+}
+
+func (c *FakeClient) ArePublished(ctx context.Context, uris []string) ([]bool, error) {
+	if c.noCdn && len(uris) > 0 {
+		return nil, gw.ErrNoCdnURL
+	}
+
+	out := make([]bool, len(uris))
+	for i, uri := range uris {
+		if strings.HasPrefix(uri, "/broken/") {
+			return nil, fmt.Errorf("HEAD %s: 500 Internal Server Error", uri)
+		}
+		c.checked = append(c.checked, uri)
+		for _, published := range c.published {
+			out[i] = out[i] || uri == published
+		}
+	}
+	return out, nil
+}
+
 func (c *FakeClient) WhoAmI(context.Context) (map[string]interface{}, error) {
 	out := make(map[string]interface{})
 	out["whoami"] = "fake-info"
@@ -346,6 +383,136 @@ func TestMainSyncFollowsLinks(t *testing.T) {
 	}
 }
 
+func TestMainSyncPreservesLinks(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/links")
+
+	regularFile := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	rand1 := "57921e8a0929eaff5003cc9dd528c3421296055a4de2ba72429dc7f41bfa8411"
+	rand2 := "f3a5340ae2a400803b8150f455ad285d173cbdcf62c8e9a214b30f467f45b310"
+	regularLink := "-> /dest/subdir/regular-file"
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectedItems map[string]string
+	}{
+		{"copy unsafe links", []string{"--links", "--copy-unsafe-links"},
+			map[string]string{
+				"/dest/link-to-regular-file":          regularLink,
+				"/dest/subdir/regular-file":           regularFile,
+				"/dest/subdir/rand1":                  rand1,
+				"/dest/subdir/rand2":                  rand2,
+				"/dest/subdir2/dir-link/regular-file": regularLink,
+				"/dest/subdir2/dir-link/rand1":        rand1,
+				"/dest/subdir2/dir-link/rand2":        rand2,
+			}},
+
+		{"safe links", []string{"-l", "--safe-links"},
+			map[string]string{
+				"/dest/link-to-regular-file":          regularLink,
+				"/dest/subdir/regular-file":           regularFile,
+				"/dest/subdir2/dir-link/regular-file": regularLink,
+			}},
+
+		{"copy links takes precedence", []string{"-lL"},
+			map[string]string{
+				"/dest/link-to-regular-file":          regularFile,
+				"/dest/subdir/regular-file":           regularFile,
+				"/dest/subdir/rand1":                  rand1,
+				"/dest/subdir/rand2":                  rand2,
+				"/dest/subdir2/dir-link/regular-file": regularFile,
+				"/dest/subdir2/dir-link/rand1":        rand1,
+				"/dest/subdir2/dir-link/rand2":        rand2,
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfig(t, CONFIG)
+			ctrl := MockController(t)
+
+			mockGw := gw.NewMockInterface(ctrl)
+			ext.gw = mockGw
+
+			client := FakeClient{blobs: make(map[string]string)}
+			mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+			args := []string{"rsync"}
+			args = append(args, tt.args...)
+			args = append(args, srcPath+"/", "exodus:/dest")
+
+			got := Main(args)
+
+			// It should complete successfully.
+			if got != 0 {
+				t.Fatal("returned incorrect exit code", got)
+			}
+
+			p := client.publishes[0]
+
+			// Build up a URI => Key (or link target) mapping of what was published
+			itemMap := make(map[string]string)
+			for _, item := range p.items {
+				if item.LinkTo != "" {
+					itemMap[item.WebURI] = "-> " + item.LinkTo
+				} else {
+					itemMap[item.WebURI] = item.ObjectKey
+				}
+			}
+
+			if !reflect.DeepEqual(itemMap, tt.expectedItems) {
+				t.Error("did not publish expected items, published:", itemMap)
+			}
+
+			// Links should not have been uploaded
+			if _, ok := client.blobs[""]; ok {
+				t.Error("uploaded a link as a blob")
+			}
+		})
+	}
+}
+
+func TestMainSyncUnsafeLinks(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetConfig(t, CONFIG)
+	logs := CaptureLogger(t)
+	ctrl := MockController(t)
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := FakeClient{blobs: make(map[string]string)}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	srcPath := path.Clean(wd + "/../../test/data/srctrees/links")
+
+	got := Main([]string{"rsync", "--links", srcPath + "/", "exodus:/dest"})
+
+	// It should fail, as there's no way to publish a link to outside of the tree.
+	if got != 23 {
+		t.Error("returned incorrect exit code", got)
+	}
+
+	entry := FindEntry(logs, "can't read files for sync")
+	if entry == nil {
+		t.Fatal("missing expected log message")
+	}
+
+	errMessage := fmt.Sprint(entry.Fields["error"])
+	if !strings.Contains(errMessage, "subdir/rand1 points outside of source tree") {
+		t.Error("unexpected error message", errMessage)
+	}
+}
+
 // When src tree has no trailing slash, the basename is repeated as a directory
 // name on the destination.
 func TestMainSyncNoSlash(t *testing.T) {
@@ -412,6 +579,42 @@ func TestMainSyncNoSlash(t *testing.T) {
 	}
 }
 
+func TestMainSyncCvsExclude(t *testing.T) {
+	SetConfig(t, CONFIG)
+	ctrl := MockController(t)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CVSIGNORE", "")
+
+	mockGw := gw.NewMockInterface(ctrl)
+	ext.gw = mockGw
+
+	client := FakeClient{blobs: make(map[string]string)}
+	mockGw.EXPECT().NewClient(gomock.Any(), EnvMatcher{"best-env"}).Return(&client, nil)
+
+	for _, name := range []string{"src/.git/config", "src/a", "src/a.orig", "src/sub/b~"} {
+		if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := Main([]string{"rsync", "-C", "src/", "exodus:/dest"})
+
+	// It should complete successfully.
+	if got != 0 {
+		t.Fatal("returned incorrect exit code", got)
+	}
+
+	// It should have published only the file which CVS wouldn't ignore.
+	p := client.publishes[0]
+	if len(p.items) != 1 || p.items[0].WebURI != "/dest/a" {
+		t.Error("did not publish expected items, published:", p.items)
+	}
+}
+
 func TestMainSyncFilesFrom(t *testing.T) {
 	wd, err := os.Getwd()
 	if err != nil {
@@ -7,7 +7,6 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
-	"github.com/release-engineering/exodus-rsync/internal/args"
 	"github.com/release-engineering/exodus-rsync/internal/rsync"
 )
 
@@ -28,7 +27,7 @@ func TestMainUnreadableConf(t *testing.T) {
 	args = append(args, ".", "some-dest:/foo/bar")
 
 	// It should fail with this code
-	if got := Main(args); got != 23 {
+	if got := Main(args); got != 1 {
 		t.Error("unexpected exit code", got)
 	}
 
@@ -56,18 +55,20 @@ func TestMainNonexistentConf(t *testing.T) {
 	rawArgs := []string{"exodus-rsync", "-vvv"}
 	rawArgs = append(rawArgs, ".", "some-dest:/foo/bar")
 	rawArgs = append(rawArgs, "--exodus-conf", "this-file-does-not-exist.conf")
-	parsedArgs := args.Parse(rawArgs, version, nil)
+
+	// Options specific to exodus-rsync are not passed through.
+	expectedArgv := []string{"-vvv", ".", "some-dest:/foo/bar"}
 
 	// We can't actually simulate the 'rsync successful' case because exec would not
 	// normally return if the process could be executed, so just force it to return
 	// an error.
 	rsyncError := fmt.Errorf("simulated error")
 
-	mockRsync.EXPECT().Exec(gomock.Any(), parsedArgs).Return(rsyncError)
+	mockRsync.EXPECT().Exec(gomock.Any(), ArgvMatcher{expectedArgv}).Return(rsyncError)
 
 	got := Main(rawArgs)
 
-	if got != 94 {
+	if got != 14 {
 		t.Error("returned incorrect exit code", got)
 	}
 
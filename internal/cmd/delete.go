@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// deleteRoot returns the prefix of all URIs which may be deleted by --delete
+// for src.
+func deleteRoot(args args.Config, src string) string {
+	return strings.TrimSuffix(destRoot(src, args.DestPath(src)), "/") + "/"
+}
+
+// listPublished returns the URIs of every item published beneath the
+// destination of each of sources, by source, for --delete.
+//
+// This should be called before anything is uploaded, so that a sync fails
+// without making any changes if exodus-gw can't list published items.
+func listPublished(ctx context.Context, gwClient gw.Client, args args.Config,
+	sources []string) (map[string][]string, error) {
+	out := make(map[string][]string, len(sources))
+
+	for _, src := range sources {
+		published, err := gwClient.ListPublished(ctx, deleteRoot(args, src))
+		if err != nil {
+			return nil, err
+		}
+		out[src] = published
+	}
+
+	return out, nil
+}
+
+// deleteExtraneous implements --delete by adding onto publish the removal
+// of every item published beneath the destination of each of sources which
+// was not found in any source. published holds the items published for
+// each source, from listPublished, and found holds the URIs of all items
+// found.
+//
+// Each deletion is written to output.
+//
+// Returns the number of deletions skipped due to --max-delete.
+func deleteExtraneous(ctx context.Context, publish gw.Publish, args args.Config,
+	sources []string, published map[string][]string, opts walk.Options,
+	found map[string]bool, output *itemOutput) (int, error) {
+	logger := log.FromContext(ctx)
+
+	candidates := make(map[string]bool)
+	protected := make(map[string]bool)
+
+	for _, src := range sources {
+		root := deleteRoot(args, src)
+
+		// Items excluded on the receiving side are protected from deletion.
+		filter, err := walk.NewFilter(src, opts)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, uri := range published[src] {
+			rel := strings.TrimPrefix(uri, root)
+			if rel == uri || found[uri] {
+				continue
+			}
+
+			isProtected, err := filter.Protected(filepath.Join(src, rel))
+			if err != nil {
+				return 0, err
+			}
+			if isProtected {
+				logger.F("uri", uri).Debug("not deleting excluded item")
+				protected[uri] = true
+				continue
+			}
+
+			// As in rsync, files skipped due to their size still exist in
+			// the source and aren't extraneous.
+			if info, err := os.Stat(filepath.Join(src, rel)); err == nil &&
+				info.Mode().IsRegular() && opts.SizeSkipped(info.Size()) {
+				logger.F("uri", uri).Debug("not deleting item skipped due to size")
+				protected[uri] = true
+				continue
+			}
+
+			candidates[uri] = true
+		}
+	}
+
+	// Where sources overlap, an item protected by any of them is kept.
+	var deleteURIs []string
+	for uri := range candidates {
+		if !protected[uri] {
+			deleteURIs = append(deleteURIs, uri)
+		}
+	}
+
+	sort.Strings(deleteURIs)
+
+	skipped := 0
+	if limit, ok := args.MaxDelete.Get(); ok && len(deleteURIs) > limit {
+		skipped = len(deleteURIs) - limit
+		deleteURIs = deleteURIs[:limit]
+	}
+
+	msg := "Deleting"
+	if args.DryRun {
+		msg = "Would delete"
+	}
+
+	items := make([]gw.ItemInput, 0, len(deleteURIs))
+	for _, uri := range deleteURIs {
+		logger.F("uri", path.Clean(uri)).Info(msg)
+		output.deleted(uri)
+		items = append(items, gw.ItemInput{WebURI: uri, ObjectKey: gw.AbsentKey})
+	}
+
+	if len(items) > 0 {
+		if err := publish.AddItems(ctx, items); err != nil {
+			return 0, err
+		}
+	}
+
+	logger.F("deleted", len(items), "skipped", skipped).Info("Added deletions to publish")
+
+	return skipped, nil
+}
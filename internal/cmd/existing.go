@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// skipExisting implements --ignore-existing, returning items without any
+// which are already published at the URI given by uri. onSkipped is called
+// with the URI of each item left out.
+//
+// As items are only checked once found, some tools can sync an empty
+// directory with --ignore-existing as a "remote mkdir" without any requests
+// to the CDN, and even without 'cdnurl'.
+func skipExisting(ctx context.Context, gwClient gw.Client, items []walk.SyncItem,
+	uri func(walk.SyncItem) string, onSkipped func(string)) ([]walk.SyncItem, error) {
+	logger := log.FromContext(ctx)
+
+	uris := make([]string, len(items))
+	for i, item := range items {
+		uris[i] = uri(item)
+	}
+
+	published, err := gwClient.ArePublished(ctx, uris)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]walk.SyncItem, 0, len(items))
+	for i, item := range items {
+		if published[i] {
+			logger.F("uri", uris[i]).Debug("skipping; already published (--ignore-existing)")
+			onSkipped(uris[i])
+			continue
+		}
+		out = append(out, item)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+)
+
+// Exit codes used by exodus-rsync. These have the same meaning as in rsync
+// (see EXIT VALUES in "man rsync"), so that tools which react to specific
+// rsync exit codes behave in the same way for either command.
+const (
+	exitSyntax      = 1  // Syntax or usage error
+	exitUnsupported = 4  // Requested action not supported
+	exitStartClient = 5  // Error starting client-server protocol
+	exitSocketIO    = 10 // Error in socket I/O
+	exitFileIO      = 11 // Error in file I/O
+	exitProtocol    = 12 // Error in rsync protocol data stream
+	exitIPC         = 14 // Error in IPC code
+	exitSignal      = 20 // Received SIGUSR1 or SIGINT
+	exitPartial     = 23 // Partial transfer due to error
+	exitMaxDelete   = 25 // The --max-delete limit stopped deletions
+	exitTimeout     = 30 // Timeout in data send/receive
+)
+
+// exitCodeForError returns the exit code which best describes a failure to
+// communicate with exodus-gw, or fallback if no more specific code applies.
+func exitCodeForError(err error, fallback int) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || gw.IsTimeout(err):
+		return exitTimeout
+	case gw.IsProtocolError(err):
+		return exitProtocol
+	case gw.IsConnectionError(err):
+		return exitSocketIO
+	}
+	return fallback
+}
+
+// exitCodeForRsync returns the exit code to be used after rsync exited
+// unsuccessfully with err.
+func exitCodeForRsync(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code > 0 {
+			return code
+		}
+		// Terminated by a signal.
+		return exitSignal
+	}
+	return exitIPC
+}
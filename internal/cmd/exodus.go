@@ -1,160 +1,515 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
-	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/release-engineering/exodus-rsync/internal/args"
 	"github.com/release-engineering/exodus-rsync/internal/conf"
 	"github.com/release-engineering/exodus-rsync/internal/gw"
 	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/progress"
 	"github.com/release-engineering/exodus-rsync/internal/walk"
 )
 
+// destRoot returns the URI onto which the top of srcTree is published.
+func destRoot(srcTree string, destTree string) string {
+	// Presence of trailing slash changes the behavior when assembling
+	// destination paths, see "man rsync" and search for "trailing".
+	if srcTree != "." && !strings.HasSuffix(srcTree, "/") {
+		srcBase := filepath.Base(srcTree)
+		return path.Join(destTree, srcBase)
+	}
+
+	return path.Clean(destTree)
+}
+
 func webURI(srcPath string, srcTree string, destTree string) string {
 	cleanSrcPath := path.Clean(srcPath)
 	cleanSrcTree := path.Clean(srcTree)
 	relPath := strings.TrimPrefix(cleanSrcPath, cleanSrcTree+"/")
+	if cleanSrcPath == cleanSrcTree {
+		// SRC is a single file.
+		relPath = ""
+	}
 
-	// Presence of trailing slash changes the behavior when assembling
-	// destination paths, see "man rsync" and search for "trailing".
-	if srcTree != "." && !strings.HasSuffix(srcTree, "/") {
-		srcBase := filepath.Base(srcTree)
-		return path.Join(destTree, srcBase, relPath)
+	return path.Join(destRoot(srcTree, destTree), relPath)
+}
+
+// sourceBatch is a batch of items found within a single SRC.
+type sourceBatch struct {
+	src   string
+	items []walk.SyncItem
+}
+
+// stageFailure describes the first failure encountered in the publish pipeline.
+type stageFailure struct {
+	code    int
+	message string
+	err     error
+}
+
+// getPublish returns the publish onto which content should be added, either
+// by creating a new publish or joining the publish requested via arguments.
+//
+// On failure, fail is invoked and nil is returned.
+func getPublish(ctx context.Context, gwClient gw.Client, args args.Config,
+	fail func(int, string, error)) gw.Publish {
+	logger := log.FromContext(ctx)
+
+	if args.Publish != "" {
+		publish := gwClient.GetPublish(args.Publish)
+		logger.F("publish", publish.ID()).Info("Joining publish")
+		return publish
 	}
 
-	return path.Join(destTree, relPath)
+	// No publish provided, then create a new one.
+	publish, err := gwClient.NewPublish(ctx)
+	if err != nil {
+		fail(exitCodeForError(err, exitPartial), "can't create publish", err)
+		return nil
+	}
+	logger.F("publish", publish.ID()).Info("Created publish")
+
+	return publish
 }
 
 func exodusMain(ctx context.Context, cfg conf.Config, args args.Config) int {
+	sources := newSourceFiles(args)
+
+	code := exodusPublish(ctx, cfg, args, sources)
+
+	// Source files are removed once committed, even if a non-fatal error
+	// (such as reaching --max-delete) is returned.
+	if removeCode := sources.remove(ctx); code == 0 {
+		code = removeCode
+	}
+
+	return code
+}
+
+// exodusPublish publishes SRC to exodus CDN, recording each published file
+// in sources.
+func exodusPublish(ctx context.Context, cfg conf.Config, args args.Config, sources *sourceFiles) int {
 	logger := log.FromContext(ctx)
 
-	clientCtor := ext.gw.NewClient
-	if args.DryRun {
-		clientCtor = ext.gw.NewDryRunClient
+	if args.Delete && args.FilesFrom != "" {
+		logger.Error("--delete is not supported with --files-from")
+		return exitUnsupported
 	}
-	gwClient, err := clientCtor(ctx, cfg)
-	if err != nil {
-		logger.F("error", err).Error("can't initialize exodus-gw client")
-		return 101
+
+	if args.FilesFrom != "" && len(args.Src) > 1 {
+		logger.Error("--files-from requires a single SRC")
+		return exitSyntax
 	}
 
-	var (
-		onlyThese []string
-		items     []walk.SyncItem
-	)
+	// As in rsync, deletion only applies when syncing a directory, and
+	// nothing is deleted when only listing files.
+	var deleteSrcs []string
+	if args.Delete && !args.ListOnly {
+		for _, src := range args.Src {
+			if info, err := os.Stat(src); err == nil && info.IsDir() {
+				deleteSrcs = append(deleteSrcs, src)
+			} else {
+				logger.F("src", src).Debug("--delete has no effect on non-directory")
+			}
+		}
+	}
+	deleting := len(deleteSrcs) > 0
 
-	if args.FilesFrom != "" {
-		args.Relative = true
+	// Upstream exodus-gw can't list published items, so --delete can only
+	// work with a server known to provide that API.
+	if deleting && !cfg.GwPublishedItems() {
+		logger.Error("--delete requires an exodus-gw server providing the published-items API (see 'gwpublisheditems')")
+		return exitUnsupported
+	}
+
+	// With --list-only, nothing is uploaded or published, so there's no
+	// need for a client (nor a certificate).
+	var gwClient gw.Client
+	if !args.ListOnly {
+		clientCtor := ext.gw.NewClient
+		if args.DryRun {
+			clientCtor = ext.gw.NewDryRunClient
+		}
+		client, err := clientCtor(ctx, cfg)
+		if err != nil {
+			logger.F("error", err).Error("can't initialize exodus-gw client")
+			return exitStartClient
+		}
+		gwClient = client
+	}
 
-		f, err := os.Open(args.FilesFrom)
+	// The deadline covers everything from here until the publish is
+	// committed, including waiting for the commit task.
+	if deadline := cfg.Deadline(); deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(deadline)*time.Second)
+		defer cancel()
+	}
+
+	var filesFrom *filesFromList
+	var onlyThese []string
+	var err error
+
+	if args.FilesFrom != "" {
+		filesFrom, err = readFilesFrom(args, args.Src[0])
 		if err != nil {
-			logger.F("src", args.Src, "error", err).Error("can't read --files-from file")
-			return 73
+			logger.F("src", args.Src[0], "error", err).Error("can't read --files-from file")
+			return exitFileIO
 		}
-		defer f.Close()
+		onlyThese = filesFrom.paths
+	}
 
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			path := filepath.Join(args.Src, strings.TrimSpace(scanner.Text()))
-			onlyThese = append(onlyThese, path)
+	// Returns the source tree to which an item at path belongs. With
+	// --no-relative, each path listed by --files-from is its own tree, so
+	// only its basename is retained under DEST.
+	srcTree := func(src string, path string) string {
+		if filesFrom == nil || args.Relative {
+			return src
+		}
+		top := filesFrom.topOf(path, src)
+		if top == filepath.Clean(src) {
+			return src
 		}
+		return top
 	}
 
-	err = walk.Walk(ctx, args.Src, args.Excluded(), args.Included(), onlyThese, func(item walk.SyncItem) error {
-		if args.IgnoreExisting {
-			// This argument is not (properly) supported, so bail out.
-			//
-			// We only check the argument here (after we've found an item) because we want
-			// the argument to be accepted if we're running over a directory tree with no
-			// files.
-			//
-			// The story with this is that some tools use an approach somewhat like this
-			// to implement a "remote mkdir":
-			//
-			//   mkdir empty
-			//   rsync --ignore-existing empty host:/dest/some/dir/which/should/be/created
-			//
-			// Since directories don't actually exist in exodus and there is no need to
-			// create a directory before writing to a particular path, this should be a
-			// no-op which successfully does nothing.  But any *other* attempted usage of
-			// --ignore-existing would be dangerous to ignore, as we can't actually deliver
-			// the requested semantics, so make it an error.
-			return fmt.Errorf("--ignore-existing is not supported")
+	// Published items are listed before anything is uploaded, so that if
+	// they can't be listed, --delete fails without making any changes.
+	var published map[string][]string
+	if deleting {
+		published, err = listPublished(ctx, gwClient, args, deleteSrcs)
+		if gw.IsUnsupported(err) {
+			logger.F("error", err).Error("--delete is not supported by this exodus-gw server")
+			return exitUnsupported
+		}
+		if err != nil {
+			logger.F("error", err).Error("can't list published items for --delete")
+			return exitCodeForError(err, exitPartial)
 		}
-		items = append(items, item)
-		return nil
-	})
-	if err != nil {
-		logger.F("src", args.Src, "error", err).Error("can't read files for sync")
-		return 73
 	}
 
-	uploadCount := 0
-	existingCount := 0
+	// Progress covers both checksums in walk and uploads in gw, which find
+	// the reporter via context.
+	reporter := newProgressReporter(args)
+	ctx = progress.NewContext(ctx, reporter)
 
-	err = gwClient.EnsureUploaded(ctx, items,
-		func(uploadedItem walk.SyncItem) error {
-			uploadCount++
-			return nil
-		},
-		func(existingItem walk.SyncItem) error {
-			existingCount++
-			return nil
-		},
+	// Content is published via a pipeline of three stages, each running in
+	// its own goroutine:
+	//
+	//   walk (& checksum) => upload => add to publish
+	//
+	// Items are passed between stages in batches over channels with a small
+	// buffer, so that all stages can make progress at the same time while
+	// only a bounded number of items are held in memory.
+	//
+	// The publish is only committed once every stage has completed
+	// successfully, so the publish as a whole remains atomic.
+	//
+	// With --list-only, the upload stage only lists each item, without
+	// uploading anything, and there's no publish stage.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		failOnce sync.Once
+		failure  *stageFailure
+		wg       sync.WaitGroup
+		publish  gw.Publish
 	)
 
-	if err != nil {
-		logger.F("error", err).Error("can't upload files")
-		return 25
+	// Records the first failure from any stage and stops all other stages.
+	// Any failures after the first are a consequence of the cancellation
+	// and are not interesting.
+	fail := func(code int, message string, err error) {
+		failOnce.Do(func() {
+			failure = &stageFailure{code, message, err}
+		})
+		cancel()
 	}
 
-	logger.F("uploaded", uploadCount, "existing", existingCount).Info("Completed uploads")
+	batchSize := cfg.GwBatchSize()
+	toUpload := make(chan sourceBatch, 1)
+	toPublish := make(chan []gw.ItemInput, 1)
 
-	var publish gw.Publish
+	stats := newTransferStats()
+	output := newItemOutput(ctx, args)
+	if reporter != nil {
+		output.w = reporter
+	}
 
-	if args.Publish == "" {
-		// No publish provided, then create a new one.
-		publish, err = gwClient.NewPublish(ctx)
-		if err != nil {
-			logger.F("error", err).Error("can't create publish")
-			return 62
+	// URIs of everything found in the source tree, if needed for --delete.
+	found := make(map[string]bool)
+
+	// With --list-only, every item is listed as it would be published,
+	// without checking for existing items.
+	ignoreExisting := args.IgnoreExisting && !args.ListOnly
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer close(toPublish)
+
+		for batch := range toUpload {
+			destTree := args.DestPath(batch.src)
+			itemURI := func(item walk.SyncItem) string {
+				return webURI(item.SrcPath, srcTree(batch.src, item.SrcPath), destTree)
+			}
+
+			items := batch.items
+			if ignoreExisting {
+				var err error
+				items, err = skipExisting(ctx, gwClient, items, itemURI, func(uri string) {
+					// Still present in the source, so must not be deleted.
+					if deleting {
+						found[uri] = true
+					}
+				})
+				if err != nil {
+					code := exitCodeForError(err, exitPartial)
+					if err == gw.ErrNoCdnURL {
+						code = exitUnsupported
+					}
+					fail(code, "can't check for existing items", err)
+					return
+				}
+			}
+
+			// Links have no content of their own to be uploaded.
+			blobs := make([]walk.SyncItem, 0, len(items))
+			for _, item := range items {
+				if item.LinkTo == "" {
+					blobs = append(blobs, item)
+				}
+			}
+
+			uploaded := make(map[string]bool)
+
+			if !args.ListOnly {
+				start := time.Now()
+				err := gwClient.EnsureUploaded(ctx, blobs,
+					func(uploadedItem walk.SyncItem) error {
+						stats.addUploaded(uploadedItem)
+						uploaded[uploadedItem.SrcPath] = true
+						return nil
+					},
+					func(existingItem walk.SyncItem) error {
+						stats.existing++
+						return nil
+					},
+				)
+				stats.uploadTime += time.Since(start)
+				if err != nil {
+					fail(exitCodeForError(err, exitPartial), "can't upload files", err)
+					return
+				}
+			}
+
+			publishItems := make([]gw.ItemInput, 0, len(items))
+			for _, item := range items {
+				publishItem := gw.ItemInput{
+					WebURI:    itemURI(item),
+					ObjectKey: item.Key,
+				}
+				if item.LinkTo != "" {
+					publishItem.LinkTo = webURI(item.LinkTo, srcTree(batch.src, item.LinkTo), destTree)
+				}
+				publishItems = append(publishItems, publishItem)
+				output.published(item, publishItem, uploaded[item.SrcPath])
+				sources.add(item)
+
+				if deleting {
+					found[publishItem.WebURI] = true
+				}
+			}
+
+			if args.ListOnly {
+				continue
+			}
+
+			select {
+			case toPublish <- publishItems:
+			case <-ctx.Done():
+				return
+			}
 		}
-		logger.F("publish", publish.ID()).Info("Created publish")
-	} else {
-		publish = gwClient.GetPublish(args.Publish)
-		logger.F("publish", publish.ID()).Info("Joining publish")
+	}()
+
+	if !args.ListOnly {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for publishItems := range toPublish {
+				if publish == nil {
+					if publish = getPublish(ctx, gwClient, args, fail); publish == nil {
+						return
+					}
+				}
+
+				start := time.Now()
+				err := publish.AddItems(ctx, publishItems)
+				stats.publishTime += time.Since(start)
+				if err != nil {
+					fail(exitCodeForError(err, exitPartial), "can't add items to publish", err)
+					return
+				}
+
+				stats.published += len(publishItems)
+			}
+
+			// Even if there was nothing to add, a publish is needed for commit.
+			if publish == nil && ctx.Err() == nil {
+				publish = getPublish(ctx, gwClient, args, fail)
+			}
+		}()
 	}
 
-	publishItems := []gw.ItemInput{}
+	var batch sourceBatch
+
+	// Time spent waiting for the upload stage, which doesn't count towards
+	// the time spent walking.
+	var sendTime time.Duration
+
+	sendBatch := func() error {
+		start := time.Now()
+		defer func() { sendTime += time.Since(start) }()
+
+		select {
+		case toUpload <- batch:
+			batch.items = nil
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	walkOpts := walk.Options{
+		Rules:          args.FilterRules(),
+		DeleteExcluded: args.DeleteExcluded,
+		CVSExclude:     args.CvsExclude,
+
+		// As in rsync, -r is needed to include the content of directories
+		// listed by --files-from.
+		Listed:    filesFrom != nil,
+		OnlyThese: onlyThese,
+		Recursive: args.Recursive,
 
-	for _, item := range items {
-		publishItems = append(publishItems, gw.ItemInput{
-			WebURI:    webURI(item.SrcPath, args.Src, args.DestPath()),
-			ObjectKey: item.Key,
+		// --copy-links takes precedence over --links, as in rsync.
+		Links:           args.Links && !args.CopyLinks,
+		CopyUnsafeLinks: args.CopyUnsafeLinks,
+		SafeLinks:       args.SafeLinks,
+
+		OneFileSystem: args.OneFileSystem,
+
+		MaxSize: args.MaxSize.Bytes(),
+		MinSize: args.MinSize.Bytes(),
+
+		// Shared by all SRC, so each inode is hashed once per run.
+		Inodes: walk.NewInodeSums(),
+	}
+
+	if cfg.ChecksumCache() {
+		if checksums := openChecksumCache(ctx); checksums != nil {
+			walkOpts.Cache = checksums
+			defer saveChecksumCache(ctx, checksums)
+		}
+	}
+
+	// All sources are walked in turn, with every item from every source
+	// going into the same publish.
+	walkSrc := func(src string) error {
+		batch = sourceBatch{src: src}
+
+		err := walk.Walk(ctx, src, walkOpts, func(item walk.SyncItem) error {
+			stats.addFound(item)
+
+			batch.items = append(batch.items, item)
+			if len(batch.items) >= batchSize {
+				return sendBatch()
+			}
+			return nil
 		})
+		if err == nil && len(batch.items) > 0 {
+			err = sendBatch()
+		}
+		return err
 	}
 
-	err = publish.AddItems(ctx, publishItems)
+	walkStart := time.Now()
+	for _, src := range args.Src {
+		if err = walkSrc(src); err != nil {
+			break
+		}
+	}
+	stats.walkTime = time.Since(walkStart) - sendTime
+	if err == nil {
+		err = ctx.Err()
+	}
 	if err != nil {
-		logger.F("error", err).Error("can't add items to publish")
-		return 51
+		fail(exitCodeForError(err, exitPartial), "can't read files for sync", err)
 	}
 
-	logger.F("publish", publish.ID(), "items", len(publishItems)).Info("Added publish items")
+	close(toUpload)
+	wg.Wait()
+	reporter.Close()
+
+	if failure != nil {
+		logger.F("src", args.Src, "error", failure.err).Error(failure.message)
+		return failure.code
+	}
+
+	if args.ListOnly {
+		logger.Info("Completed successfully (in list-only mode - nothing published)")
+		return 0
+	}
+
+	stats.publishID = publish.ID()
+
+	logger.F("uploaded", stats.uploaded, "existing", stats.existing).Info("Completed uploads")
+	logger.F("publish", stats.publishID, "items", stats.published).Info("Added publish items")
+
+	skippedDeletes := 0
+	if deleting {
+		skippedDeletes, err = deleteExtraneous(ctx, publish, args, deleteSrcs, published, walkOpts, found, output)
+		if err != nil {
+			logger.F("error", err).Error("can't delete extraneous items")
+			return exitCodeForError(err, exitPartial)
+		}
+	}
 
 	if args.Publish == "" {
 		// We created the publish, then we should commit it.
+		start := time.Now()
 		err = publish.Commit(ctx)
 		if err != nil {
 			logger.F("error", err).Error("can't commit publish")
-			return 71
+			return exitCodeForError(err, exitPartial)
 		}
+		stats.commitTime = time.Since(start)
+		stats.committed = true
+	}
+
+	// With a joined publish, the owner of the publish is responsible for
+	// committing it, so all is done once items are added.
+	sources.setDone()
+
+	if args.Stats {
+		stats.log(ctx)
+		stats.print(ext.stdout)
+	}
+
+	if skippedDeletes > 0 {
+		logger.F("skipped", skippedDeletes).Warn("Deletions stopped due to --max-delete limit")
+		return exitMaxDelete
 	}
 
 	msg := "Completed successfully!"
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+)
+
+// filesFromList holds the paths listed by --files-from, within src.
+type filesFromList struct {
+	paths  []string
+	listed map[string]bool
+}
+
+// splitAny returns a bufio.SplitFunc producing tokens terminated by any of
+// the bytes in seps.
+func splitAny(seps string) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexAny(data, seps); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// readFilesFrom returns the paths listed in the file named by --files-from,
+// or stdin if the name is "-".
+//
+// As in rsync, names are separated by NUL with --from0, and otherwise by any
+// of LF, CR or CRLF, in which case lines starting with '#' or ';' are
+// comments. Names are relative to src, even with a leading slash, and must
+// not refer to anything outside of src.
+func readFilesFrom(args args.Config, src string) (*filesFromList, error) {
+	var r io.Reader = ext.stdin
+
+	if args.FilesFrom != "-" {
+		f, err := os.Open(args.FilesFrom)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	if args.From0 {
+		scanner.Split(splitAny("\x00"))
+	} else {
+		scanner.Split(splitAny("\r\n"))
+	}
+
+	out := &filesFromList{listed: make(map[string]bool)}
+
+	for scanner.Scan() {
+		name := scanner.Text()
+		if name == "" || (!args.From0 && strings.ContainsAny(name[:1], "#;")) {
+			continue
+		}
+
+		rel := filepath.Clean(strings.TrimLeft(name, "/"))
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("'%s' is outside of the source tree", name)
+		}
+
+		path := filepath.Join(src, rel)
+		out.paths = append(out.paths, path)
+		out.listed[path] = true
+	}
+
+	return out, scanner.Err()
+}
+
+// topOf returns the listed path which led to path being walked: either path
+// itself, or the nearest of its parents which is listed. If there is none,
+// fallback is returned.
+func (l *filesFromList) topOf(path string, fallback string) string {
+	for dir := filepath.Clean(path); ; {
+		if l.listed[dir] {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fallback
+		}
+		dir = parent
+	}
+}
+
+// saveStdin copies everything from stdin into a new temporary file, and
+// returns the name of the file.
+func saveStdin() (string, error) {
+	f, err := os.CreateTemp("", "exodus-rsync-files-from-")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(f, ext.stdin)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
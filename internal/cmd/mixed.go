@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"io"
+	"os"
 	"os/exec"
 	"sync"
 
@@ -18,6 +19,18 @@ import (
 func mixedMain(ctx context.Context, cfg conf.Config, args args.Config) int {
 	logger := log.FromContext(ctx)
 
+	// rsync and exodus both need the list from --files-from=-, but only one
+	// of them could read it from stdin, so it's passed to both via a file.
+	if args.FilesFrom == "-" {
+		filesFrom, err := saveStdin()
+		if err != nil {
+			logger.F("error", err).Error("can't read --files-from file")
+			return exitFileIO
+		}
+		defer os.Remove(filesFrom)
+		args.FilesFrom = filesFrom
+	}
+
 	ctx, cancelFn := context.WithCancel(ctx)
 
 	wg := sync.WaitGroup{}
@@ -51,12 +64,18 @@ func mixedMain(ctx context.Context, cfg conf.Config, args args.Config) int {
 	var lastCode *chan int
 	rsyncCode := make(chan int, 1)
 	exodusCode := make(chan int, 1)
-	rsyncCmd := ext.rsync.Command(ctx, rsync.Arguments(ctx, args))
+
+	// Source files must only be removed once both publishes have succeeded,
+	// so rsync isn't allowed to remove them itself.
+	sources := newSourceFiles(args)
+	rsyncArgs := args
+	rsyncArgs.RemoveSourceFiles = false
+	rsyncCmd := ext.rsync.Command(ctx, rsync.Arguments(ctx, rsyncArgs))
 
 	// Let rsync & exodus publishes run in their own goroutines.
 	go func() {
 		defer wg.Done()
-		exodusCode <- exodusMain(ctx, cfg, args)
+		exodusCode <- exodusPublish(ctx, cfg, args, sources)
 	}()
 
 	go func() {
@@ -83,7 +102,12 @@ func mixedMain(ctx context.Context, cfg conf.Config, args args.Config) int {
 		lastCode = &exodusCode
 	}
 
-	return <-*lastCode
+	code := <-*lastCode
+	if code != 0 {
+		return code
+	}
+
+	return sources.remove(ctx)
 }
 
 func doRsyncCommand(ctx context.Context, cmd *exec.Cmd) int {
@@ -97,7 +121,7 @@ func doRsyncCommand(ctx context.Context, cmd *exec.Cmd) int {
 	}
 	if err != nil {
 		logger.F("error", err).Error("Can't connect pipes to rsync")
-		return 39
+		return exitIPC
 	}
 
 	outScanner := bufio.NewScanner(outPipe)
@@ -106,7 +130,7 @@ func doRsyncCommand(ctx context.Context, cmd *exec.Cmd) int {
 	err = cmd.Start()
 	if err != nil {
 		logger.F("error", err).Error("Failed to run rsync")
-		return 25
+		return exitIPC
 	}
 
 	pid := cmd.Process.Pid
@@ -134,7 +158,7 @@ func doRsyncCommand(ctx context.Context, cmd *exec.Cmd) int {
 	err = cmd.Wait()
 	if err != nil {
 		logger.F("error", err).Error("rsync failed")
-		return 130
+		return exitCodeForRsync(err)
 	}
 
 	return 0
@@ -30,7 +30,7 @@ func TestExodusFailsFirst(t *testing.T) {
 
 	out := mixedMain(ctx, cfg, args.Config{})
 
-	if out != 101 {
+	if out != 5 {
 		t.Errorf("got unexpected exit code %v", out)
 	}
 
@@ -70,7 +70,7 @@ func TestExodusFailsLater(t *testing.T) {
 
 	out := mixedMain(ctx, cfg, args.Config{})
 
-	if out != 101 {
+	if out != 5 {
 		t.Errorf("got unexpected exit code %v", out)
 	}
 
@@ -84,6 +84,9 @@ func TestExodusFailsLater(t *testing.T) {
 func TestRsyncFailsFirst(t *testing.T) {
 	ctrl := MockController(t)
 	cfg := conf.NewMockConfig(ctrl)
+	cfg.EXPECT().GwBatchSize().Return(10).AnyTimes()
+	cfg.EXPECT().ChecksumCache().Return(false).AnyTimes()
+	cfg.EXPECT().Deadline().Return(0).AnyTimes()
 
 	mockGw := gw.NewMockInterface(ctrl)
 	ext.gw = mockGw
@@ -106,7 +109,7 @@ func TestRsyncFailsFirst(t *testing.T) {
 
 	out := mixedMain(ctx, cfg, args.Config{})
 
-	if out != 130 {
+	if out != 1 {
 		t.Errorf("got unexpected exit code %v", out)
 	}
 
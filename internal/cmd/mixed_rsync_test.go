@@ -17,7 +17,7 @@ func TestRsyncPipeFail(t *testing.T) {
 	cmd.StdoutPipe()
 
 	code := doRsyncCommand(testContext(), cmd)
-	if code != 39 {
+	if code != 14 {
 		t.Errorf("got unexpected exit code %v", code)
 	}
 }
@@ -26,7 +26,7 @@ func TestRsyncStartFail(t *testing.T) {
 	cmd := exec.Command("/non/existent/binary")
 
 	code := doRsyncCommand(testContext(), cmd)
-	if code != 25 {
+	if code != 14 {
 		t.Errorf("got unexpected exit code %v", code)
 	}
 }
@@ -35,7 +35,7 @@ func TestRsyncFails(t *testing.T) {
 	cmd := exec.Command("false")
 
 	code := doRsyncCommand(testContext(), cmd)
-	if code != 130 {
+	if code != 1 {
 		t.Errorf("got unexpected exit code %v", code)
 	}
 }
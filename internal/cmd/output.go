@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/gw"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// Summaries of changes, as output by rsync --itemize-changes.
+const (
+	itemizeUploaded = "<f+++++++++"
+	itemizeExisting = ".f         "
+	itemizeLink     = "cL+++++++++"
+	itemizeDeleted  = "*deleting  "
+)
+
+// itemOutput writes a line for each item added onto a publish, in the same
+// format as rsync with -v, -i or --out-format, so that tools parsing the
+// output of rsync can do the same with exodus-rsync.
+type itemOutput struct {
+	w      io.Writer
+	logger *log.Logger
+
+	// Formats for output to w and to the logger; either may be empty.
+	format    args.OutFormat
+	logFormat args.OutFormat
+
+	// Prefix removed from URIs to produce names relative to the destination.
+	destPrefix string
+
+	// With --list-only, each published item is listed as by rsync (or as
+	// JSON), by URI, instead of any other output.
+	listOnly bool
+	listJSON bool
+}
+
+// listEntry is the output for each item with --exodus-list-json.
+type listEntry struct {
+	gw.ItemInput
+	SrcPath string `json:"src_path"`
+	Size    int64  `json:"size"`
+}
+
+// outputItem holds the values available to an args.OutFormat.
+type outputItem struct {
+	name     string
+	srcPath  string
+	info     fs.FileInfo
+	linkTo   string
+	itemize  string
+	op       string
+	key      string
+	uploaded int64
+}
+
+func newItemOutput(ctx context.Context, args args.Config) *itemOutput {
+	return &itemOutput{
+		w:          ext.stdout,
+		logger:     log.FromContext(ctx),
+		format:     args.ItemFormat(),
+		logFormat:  args.LogFileFormat,
+		destPrefix: strings.TrimSuffix(path.Clean(args.DestPath("")), "/") + "/",
+		listOnly:   args.ListOnly,
+		listJSON:   args.ListJSON,
+	}
+}
+
+// value returns the value of escape for item.
+func (i *outputItem) value(escape byte) string {
+	switch escape {
+	case 'b':
+		return fmt.Sprint(i.uploaded)
+	case 'B':
+		if i.info == nil {
+			return ""
+		}
+		return i.info.Mode().Perm().String()[1:]
+	case 'c':
+		// No block checksums are ever received, as there's no basis file.
+		return "0"
+	case 'C':
+		return i.key
+	case 'f':
+		return i.srcPath
+	case 'G', 'U':
+		st, ok := statOf(i.info)
+		if !ok {
+			return ""
+		}
+		if escape == 'G' {
+			return fmt.Sprint(st.Gid)
+		}
+		return fmt.Sprint(st.Uid)
+	case 'i':
+		return i.itemize
+	case 'l':
+		if i.info == nil {
+			return "0"
+		}
+		return fmt.Sprint(i.info.Size())
+	case 'L':
+		if i.linkTo == "" {
+			return ""
+		}
+		return " -> " + i.linkTo
+	case 'M':
+		if i.info == nil {
+			return ""
+		}
+		return i.info.ModTime().Format("2006/01/02-15:04:05")
+	case 'n':
+		return i.name
+	case 'o':
+		return i.op
+	case 'p':
+		return fmt.Sprint(os.Getpid())
+	case 't':
+		return time.Now().Format("2006/01/02 15:04:05")
+	}
+	return ""
+}
+
+// statOf returns the underlying stat of info, if available.
+func statOf(info fs.FileInfo) (*syscall.Stat_t, bool) {
+	if info == nil {
+		return nil, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return st, ok
+}
+
+func (o *itemOutput) write(item outputItem) {
+	if o.format != "" {
+		line := o.format.Expand(item.value)
+
+		// As in rsync, deletions are only itemized if the format would
+		// show it; otherwise they get a fixed message.
+		if item.op == "del." && !o.format.Uses('i') {
+			line = "deleting " + item.name
+		}
+
+		fmt.Fprintln(o.w, line)
+	}
+
+	if o.logFormat != "" {
+		o.logger.Info(o.logFormat.Expand(item.value))
+	}
+}
+
+// list writes the listing of an item for --list-only, in the same format as
+// rsync but naming the item by its URI.
+func (o *itemOutput) list(item walk.SyncItem, input gw.ItemInput) {
+	size := itemSize(item)
+
+	if o.listJSON {
+		line, _ := json.Marshal(listEntry{input, item.SrcPath, size})
+		fmt.Fprintln(o.w, string(line))
+		return
+	}
+
+	var perms fs.FileMode
+	var mtime time.Time
+	if item.Info != nil {
+		perms = item.Info.Mode().Perm()
+		mtime = item.Info.ModTime()
+	}
+
+	mode := "-" + perms.String()[1:]
+	name := input.WebURI
+	if input.LinkTo != "" {
+		mode = "l" + perms.String()[1:]
+		name += " -> " + input.LinkTo
+	}
+
+	fmt.Fprintf(o.w, "%s %14s %s %s\n", mode, formatCount(size), mtime.Format("2006/01/02 15:04:05"), name)
+}
+
+// published writes the output for an item published as input. uploaded
+// should be true if the item's content was uploaded, rather than already
+// present.
+func (o *itemOutput) published(item walk.SyncItem, input gw.ItemInput, uploaded bool) {
+	if o.listOnly {
+		o.list(item, input)
+		return
+	}
+
+	out := outputItem{
+		name:    strings.TrimPrefix(input.WebURI, o.destPrefix),
+		srcPath: item.SrcPath,
+		info:    item.Info,
+		itemize: itemizeExisting,
+		op:      "send",
+		key:     item.Key,
+	}
+
+	switch {
+	case item.LinkTo != "":
+		out.itemize = itemizeLink
+		out.linkTo = item.LinkTo
+		if rel, err := filepath.Rel(filepath.Dir(item.SrcPath), item.LinkTo); err == nil {
+			out.linkTo = rel
+		}
+	case uploaded:
+		out.itemize = itemizeUploaded
+		out.uploaded = itemSize(item)
+	}
+
+	o.write(out)
+}
+
+// deleted writes the output for an item deleted from uri.
+func (o *itemOutput) deleted(uri string) {
+	name := strings.TrimPrefix(path.Clean(uri), o.destPrefix)
+
+	o.write(outputItem{
+		name:    name,
+		srcPath: name,
+		itemize: itemizeDeleted,
+		op:      "del.",
+	})
+}
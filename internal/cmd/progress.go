@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/progress"
+)
+
+// isTerminal returns true if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newProgressReporter returns a reporter for the progress requested by
+// arguments, or nil if none was requested.
+func newProgressReporter(args args.Config) *progress.Reporter {
+	// As in rsync, nothing is transferred when only listing files, so there's
+	// no progress to report. This also keeps --exodus-list-json parseable.
+	if args.ListOnly {
+		return nil
+	}
+
+	var mode progress.Mode
+
+	switch level := args.ProgressLevel(); {
+	case level == 1:
+		mode = progress.PerFile
+	case level >= 2:
+		mode = progress.Total
+	default:
+		return nil
+	}
+
+	return progress.New(ext.stdout, mode, isTerminal(ext.stdout))
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"io/fs"
+	"os"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// sourceFiles records the files published from SRC, so that they can be
+// removed with --remove-source-files once the publish has succeeded.
+//
+// A nil *sourceFiles records nothing and removes nothing.
+type sourceFiles struct {
+	items []walk.SyncItem
+
+	// Set once items have been committed, or added to a joined publish.
+	done bool
+}
+
+func newSourceFiles(args args.Config) *sourceFiles {
+	// As in rsync, nothing is removed if nothing is transferred.
+	if !args.RemoveSourceFiles || args.DryRun || args.ListOnly {
+		return nil
+	}
+	return &sourceFiles{}
+}
+
+func (s *sourceFiles) add(item walk.SyncItem) {
+	if s != nil {
+		s.items = append(s.items, item)
+	}
+}
+
+func (s *sourceFiles) setDone() {
+	if s != nil {
+		s.done = true
+	}
+}
+
+// changed returns true if the file at item.SrcPath is no longer the file
+// which was published, as determined by its size and modification time.
+func changed(item walk.SyncItem) (bool, error) {
+	stat := os.Stat
+	if item.Info.Mode()&fs.ModeSymlink != 0 {
+		// Published as a link, rather than the file it points to.
+		stat = os.Lstat
+	}
+
+	info, err := stat(item.SrcPath)
+	if err != nil {
+		return false, err
+	}
+
+	return info.Size() != item.Info.Size() || !info.ModTime().Equal(item.Info.ModTime()), nil
+}
+
+// remove removes all recorded files from SRC, other than those which have
+// changed since they were published. Directories are never removed.
+//
+// Returns an exit code, which is non-zero if any file was not removed.
+func (s *sourceFiles) remove(ctx context.Context) int {
+	logger := log.FromContext(ctx)
+
+	if s == nil || !s.done {
+		return 0
+	}
+
+	code := 0
+	removed := 0
+
+	for _, item := range s.items {
+		isChanged, err := changed(item)
+		if err == nil && isChanged {
+			logger.F("src", item.SrcPath).Warn("Not removing source file changed since publish")
+			code = exitPartial
+			continue
+		}
+		if err == nil {
+			err = os.Remove(item.SrcPath)
+		}
+		if err != nil {
+			logger.F("src", item.SrcPath, "error", err).Error("can't remove source file")
+			code = exitPartial
+			continue
+		}
+		removed++
+	}
+
+	logger.F("removed", removed).Info("Removed source files")
+
+	return code
+}
@@ -16,7 +16,7 @@ func rsyncMain(ctx context.Context, cfg conf.Config, args args.Config) int {
 	// call, this will never return.
 	if err := ext.rsync.Exec(ctx, args); err != nil {
 		logger.WithField("error", err).Error("can't exec rsync")
-		exitCode = 94
+		exitCode = exitIPC
 	}
 
 	return exitCode
@@ -33,7 +33,7 @@ func rsyncRaw(ctx context.Context, rawArgs []string) int {
 	// call, this will never return.
 	if err := ext.rsync.RawExec(ctx, args); err != nil {
 		logger.WithField("error", err).Error("can't exec rsync")
-		exitCode = 94
+		exitCode = exitIPC
 	}
 
 	return exitCode
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// transferStats holds statistics on a publish, collected throughout the
+// publish pipeline and reported by --stats.
+//
+// Each field is only updated by a single pipeline stage, so no locking
+// is needed.
+type transferStats struct {
+	// Items found in the source trees.
+	files int
+	links int
+
+	// Files whose content was uploaded or was already present in exodus-gw.
+	uploaded int
+	existing int
+
+	// Sizes of all files, and of only the uploaded files, in bytes.
+	totalSize    int64
+	uploadedSize int64
+
+	// Items added onto the publish, excluding deletions.
+	published int
+
+	publishID string
+	committed bool
+
+	// Time spent in each phase. As the phases run concurrently, these may
+	// add up to more than the total time.
+	walkTime    time.Duration
+	uploadTime  time.Duration
+	publishTime time.Duration
+	commitTime  time.Duration
+
+	start time.Time
+}
+
+func newTransferStats() *transferStats {
+	return &transferStats{start: time.Now()}
+}
+
+// addFound records an item found while walking the source trees.
+func (s *transferStats) addFound(item walk.SyncItem) {
+	if item.LinkTo != "" {
+		s.links++
+		return
+	}
+	s.files++
+	s.totalSize += itemSize(item)
+}
+
+// addUploaded records a file whose content was uploaded.
+func (s *transferStats) addUploaded(item walk.SyncItem) {
+	s.uploaded++
+	s.uploadedSize += itemSize(item)
+}
+
+func itemSize(item walk.SyncItem) int64 {
+	if item.Info == nil {
+		return 0
+	}
+	return item.Info.Size()
+}
+
+// formatCount formats n with thousands separators, as rsync does.
+func formatCount(n int64) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f seconds", d.Seconds())
+}
+
+// log writes the statistics to the log at info level, so they're always
+// available from the platform logger.
+func (s *transferStats) log(ctx context.Context) {
+	logger := log.FromContext(ctx)
+
+	logger.F(
+		"files", s.files,
+		"links", s.links,
+		"uploaded", s.uploaded,
+		"existing", s.existing,
+		"totalsize", s.totalSize,
+		"uploadedsize", s.uploadedSize,
+		"items", s.published,
+		"publish", s.publishID,
+		"walktime", s.walkTime.Seconds(),
+		"uploadtime", s.uploadTime.Seconds(),
+		"additemstime", s.publishTime.Seconds(),
+		"committime", s.commitTime.Seconds(),
+	).Info("Transfer stats")
+}
+
+// print writes the statistics in a format similar to that of rsync --stats.
+func (s *transferStats) print(w io.Writer) {
+	lines := []string{
+		"",
+		fmt.Sprintf("Number of files: %s (reg: %s, link: %s)",
+			formatCount(int64(s.files+s.links)), formatCount(int64(s.files)), formatCount(int64(s.links))),
+		fmt.Sprintf("Number of regular files transferred: %s", formatCount(int64(s.uploaded))),
+		fmt.Sprintf("Number of regular files already present: %s", formatCount(int64(s.existing))),
+		fmt.Sprintf("Total file size: %s bytes", formatCount(s.totalSize)),
+		fmt.Sprintf("Total transferred file size: %s bytes", formatCount(s.uploadedSize)),
+		fmt.Sprintf("Number of publish items added: %s", formatCount(int64(s.published))),
+		fmt.Sprintf("Publish ID: %s", s.publishID),
+		fmt.Sprintf("Walk and checksum time: %s", formatSeconds(s.walkTime)),
+		fmt.Sprintf("Upload time: %s", formatSeconds(s.uploadTime)),
+		fmt.Sprintf("Add items time: %s", formatSeconds(s.publishTime)),
+	}
+	if s.committed {
+		lines = append(lines, fmt.Sprintf("Commit time: %s", formatSeconds(s.commitTime)))
+	}
+	lines = append(lines, fmt.Sprintf("Total time: %s", formatSeconds(time.Since(s.start))), "")
+
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
@@ -37,9 +37,33 @@ type Config interface {
 	// How often to poll for task updates, in milliseconds.
 	GwPollInterval() int
 
+	// Max number of attempts for any retryable request to exodus-gw.
+	GwMaxAttempts() int
+
+	// Initial delay between retries, in milliseconds.
+	GwRetryBackoff() int
+
+	// Max delay between retries, in milliseconds.
+	GwMaxRetryBackoff() int
+
 	// Max number of items to include in a single HTTP request to exodus-gw.
 	GwBatchSize() int
 
+	// Max number of blobs to be checked or uploaded concurrently, and of
+	// URIs checked on the CDN for --ignore-existing.
+	GwUploadConcurrency() int
+
+	// Max number of requests per second to exodus-gw, or 0 for no limit.
+	GwMaxRequestRate() int
+
+	// Whether exodus-gw provides the published-items API needed for --delete,
+	// which is not part of upstream exodus-gw.
+	GwPublishedItems() bool
+
+	// Base URL of the CDN serving content published to exodus-gw, used to
+	// check whether content is already published.
+	CdnURL() string
+
 	// Execution mode for rsync.
 	RsyncMode() string
 
@@ -52,8 +76,22 @@ type Config interface {
 	// Level of verbosity requested via CLI args.
 	Verbosity() int
 
+	// Max upload bandwidth in bytes per second requested via CLI args,
+	// or 0 for no limit.
+	BwLimit() int64
+
+	// I/O inactivity timeout for requests to exodus-gw in seconds, requested
+	// via CLI args, or 0 for no timeout.
+	Timeout() int
+
+	// Max duration of the whole publish in seconds, or 0 for no limit.
+	Deadline() int
+
 	// Diagnostics mode.
 	Diag() bool
+
+	// Whether checksums of published files are cached between runs.
+	ChecksumCache() bool
 }
 
 // EnvironmentConfig provides configuration specific to one environment.
@@ -61,6 +99,10 @@ type EnvironmentConfig interface {
 	Config
 
 	Prefix() string
+
+	// Path under which the given module of a daemon-style destination is
+	// published, or empty if not configured.
+	ModuleRoot(string) string
 }
 
 // GlobalConfig provides configuration applied to all environments.
@@ -68,4 +110,8 @@ type GlobalConfig interface {
 	Config
 
 	EnvironmentForDest(context.Context, string) EnvironmentConfig
+
+	// SetArgs replaces the arguments passed to Load, e.g. once arguments
+	// which were only peeked at have been fully parsed.
+	SetArgs(args.Config)
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/release-engineering/exodus-rsync/internal/args"
@@ -32,13 +33,21 @@ gwurl: https://exodus-gw.example.com
 gwcert: global-cert
 gwkey: global-key
 gwbatchsize: 100
+gwmaxattempts: 7
+cdnurl: https://cdn.example.com
 
 environments:
 - prefix: dest
   gwenv: one-env
   gwkey: override-key
   gwpollinterval: 123
+  gwuploadconcurrency: 8
+  gwmaxrequestrate: 50
+  deadline: 600
+  gwretrybackoff: 20
   rsyncmode: mixed
+  cdnurl: https://other-cdn.example.com
+  gwpublisheditems: true
 
 `), 0755)
 
@@ -83,18 +92,75 @@ environments:
 	assertEqual("global gwkey", cfg.GwKey(), "global-key")
 	assertEqual("global gwenv", cfg.GwEnv(), "global-env")
 	assertEqual("global gwpollinterval", cfg.GwPollInterval(), 5000)
+	assertEqual("global gwuploadconcurrency", cfg.GwUploadConcurrency(), 4)
+	assertEqual("global gwmaxattempts", cfg.GwMaxAttempts(), 7)
+	assertEqual("global gwretrybackoff", cfg.GwRetryBackoff(), 1000)
+	assertEqual("global gwmaxretrybackoff", cfg.GwMaxRetryBackoff(), 60000)
 	assertEqual("global rsyncmode", cfg.RsyncMode(), "exodus")
+	assertEqual("global gwmaxrequestrate", cfg.GwMaxRequestRate(), 0)
+	assertEqual("global deadline", cfg.Deadline(), 0)
+	assertEqual("global cdnurl", cfg.CdnURL(), "https://cdn.example.com")
+	assertEqual("global gwpublisheditems", cfg.GwPublishedItems(), false)
 
 	// Values can be overridden in environment.
 	assertEqual("env gwenv", env.GwEnv(), "one-env")
 	assertEqual("env gwkey", env.GwKey(), "override-key")
 	assertEqual("env gwpollinterval", env.GwPollInterval(), 123)
+	assertEqual("env gwuploadconcurrency", env.GwUploadConcurrency(), 8)
+	assertEqual("env gwretrybackoff", env.GwRetryBackoff(), 20)
 	assertEqual("env rsyncmode", env.RsyncMode(), "mixed")
+	assertEqual("env gwmaxrequestrate", env.GwMaxRequestRate(), 50)
+	assertEqual("env deadline", env.Deadline(), 600)
+	assertEqual("env cdnurl", env.CdnURL(), "https://other-cdn.example.com")
+	assertEqual("env gwpublisheditems", env.GwPublishedItems(), true)
 
 	// For values which are NOT overridden, they should be equal to global.
 	assertEqual("env gwurl", env.GwURL(), cfg.GwURL())
 	assertEqual("env gwcert", env.GwCert(), cfg.GwCert())
 	assertEqual("env gwbatchsize", env.GwBatchSize(), cfg.GwBatchSize())
+	assertEqual("env gwmaxattempts", env.GwMaxAttempts(), cfg.GwMaxAttempts())
+}
+
+func TestInvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     string
+		expected string
+	}{
+		{"global",
+			"gwuploadconcurrency: -1\n",
+			"invalid value for 'gwuploadconcurrency': -1, must be at least 1"},
+
+		{"environment",
+			"environments:\n- prefix: dest\n  gwuploadconcurrency: -4\n",
+			"environment 'dest': invalid value for 'gwuploadconcurrency': -4, must be at least 1"},
+
+		{"max attempts",
+			"gwmaxattempts: -1\n",
+			"invalid value for 'gwmaxattempts': -1, must be at least 1"},
+
+		{"retry backoff",
+			"environments:\n- prefix: dest\n  gwretrybackoff: -100\n",
+			"environment 'dest': invalid value for 'gwretrybackoff': -100, must be at least 1"},
+
+		{"max retry backoff",
+			"gwmaxretrybackoff: -5000\n",
+			"invalid value for 'gwmaxretrybackoff': -5000, must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "test.conf")
+			if err := os.WriteFile(filename, []byte(tt.conf), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := loadFromPath(filename, args.Config{})
+			if err == nil || !strings.HasSuffix(err.Error(), tt.expected) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
 }
 
 func TestDefaultsFromParent(t *testing.T) {
@@ -103,6 +169,8 @@ func TestDefaultsFromParent(t *testing.T) {
 	cfg.GwCertRaw = "cert"
 	cfg.GwPollIntervalRaw = 123
 	cfg.args.Verbose = 1
+	cfg.args.BwLimit = "2"
+	cfg.args.Timeout = 30
 
 	env := environment{parent: &cfg}
 
@@ -115,4 +183,167 @@ func TestDefaultsFromParent(t *testing.T) {
 	if env.Verbosity() != 1 {
 		t.Errorf("did not get args.Verbose from parent")
 	}
+	if env.BwLimit() != 2048 {
+		t.Errorf("did not get args.BwLimit from parent")
+	}
+	if env.Timeout() != 30 {
+		t.Errorf("did not get args.Timeout from parent")
+	}
+}
+
+func TestChecksumCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		global   bool
+		env      bool
+		bypass   bool
+		expected bool
+	}{
+		{"disabled", false, false, false, false},
+		{"enabled globally", true, false, false, true},
+		{"enabled in env", false, true, false, true},
+		{"bypassed", true, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := globalConfig{}
+			cfg.ChecksumCacheRaw = tt.global
+			cfg.args.NoChecksumCache = tt.bypass
+
+			env := environment{parent: &cfg}
+			env.ChecksumCacheRaw = tt.env
+
+			if env.ChecksumCache() != tt.expected {
+				t.Errorf("ChecksumCache() = %v, expected %v", env.ChecksumCache(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		global   int
+		env      int
+		arg      int
+		expected int
+	}{
+		{"unset", 0, 0, 0, 0},
+		{"global", 100, 0, 0, 100},
+		{"env", 100, 50, 0, 50},
+		{"arg", 100, 50, 10, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := globalConfig{}
+			cfg.DeadlineRaw = tt.global
+			cfg.args.Deadline = tt.arg
+
+			env := environment{parent: &cfg}
+			env.DeadlineRaw = tt.env
+
+			if env.Deadline() != tt.expected {
+				t.Errorf("Deadline() = %v, expected %v", env.Deadline(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetArgs(t *testing.T) {
+	cfg := globalConfig{}
+	cfg.args.Verbose = 1
+
+	env := environment{parent: &cfg}
+
+	// Replacing the arguments should affect the config of all environments.
+	cfg.SetArgs(args.Config{Verbose: 3, Timeout: 30})
+
+	if env.Verbosity() != 3 || env.Timeout() != 30 {
+		t.Errorf("unexpected config from args: verbosity %v, timeout %v", env.Verbosity(), env.Timeout())
+	}
+}
+
+func TestEnvironmentForDaemonDest(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "test.conf")
+
+	err := os.WriteFile(filename, []byte(`
+environments:
+- prefix: mirror.example.com
+  gwenv: host-env
+  modules:
+    pub: /content/pub
+
+- prefix: mirror.example.com::beta
+  gwenv: module-env
+
+- prefix: exodus
+  gwenv: shell-env
+
+- prefix: someone@other.example.com
+  gwenv: user-env
+
+- prefix: "[::1]"
+  gwenv: ipv6-env
+`), 0755)
+	if err != nil {
+		t.Fatalf("could not write config file for test: %v", err)
+	}
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	cfg, err := loadFromPath(filename, args.Config{})
+	if err != nil {
+		t.Fatalf("could not load config file: %v", err)
+	}
+
+	tests := []struct {
+		dest  string
+		gwenv string
+	}{
+		{"mirror.example.com::pub/rhel", "host-env"},
+		{"user@mirror.example.com::pub/rhel", "host-env"},
+		{"rsync://mirror.example.com/pub/rhel", "host-env"},
+		{"rsync://user@mirror.example.com:8873/pub", "host-env"},
+		{"mirror.example.com::beta/rhel", "module-env"},
+		{"rsync://mirror.example.com/beta/", "module-env"},
+		{"exodus:/some/path", "shell-env"},
+		{"other.example.com::pub/rhel", ""},
+		{"rsync://exodus/pub", "shell-env"},
+		{"rsync://other.example.com/pub", ""},
+		{"someone@other.example.com::pub/rhel", "user-env"},
+		{"someone@other.example.com:/some/path", "user-env"},
+		{"rsync://[::1]/pub", "ipv6-env"},
+		{"rsync://[::1]:873/pub/rhel", "ipv6-env"},
+		{"[::1]::pub", "ipv6-env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dest, func(t *testing.T) {
+			env := cfg.EnvironmentForDest(ctx, tt.dest)
+			if tt.gwenv == "" {
+				if env != nil {
+					t.Fatalf("unexpectedly matched environment %v", env.Prefix())
+				}
+				return
+			}
+			if env == nil {
+				t.Fatalf("no environment matched")
+			}
+			if env.GwEnv() != tt.gwenv {
+				t.Errorf("matched environment for %v, expected %v", env.GwEnv(), tt.gwenv)
+			}
+		})
+	}
+
+	env := cfg.EnvironmentForDest(ctx, "mirror.example.com::pub")
+	if root := env.ModuleRoot("pub"); root != "/content/pub" {
+		t.Errorf("ModuleRoot(pub) = %v", root)
+	}
+	if root := env.ModuleRoot("other"); root != "" {
+		t.Errorf("ModuleRoot(other) = %v", root)
+	}
 }
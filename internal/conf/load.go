@@ -36,6 +36,10 @@ func loadFromPath(path string, args args.Config) (*globalConfig, error) {
 		return &globalConfig{}, fmt.Errorf("can't parse %s: %w", path, err)
 	}
 
+	if err := out.validate(); err != nil {
+		return nil, fmt.Errorf("can't parse %s: %w", path, err)
+	}
+
 	// A bit of normalization...
 	for {
 		if !strings.HasSuffix(out.GwURLRaw, "/") {
@@ -56,6 +60,9 @@ func loadFromPath(path string, args args.Config) (*globalConfig, error) {
 			return nil, fmt.Errorf("duplicate environment definitions for '%s'", env.Prefix())
 		}
 		prefs[env.Prefix()] = true
+		if err := env.validate(); err != nil {
+			return nil, fmt.Errorf("can't parse %s: environment '%s': %w", path, env.Prefix(), err)
+		}
 		out.EnvironmentsRaw[i].parent = out
 	}
 
@@ -84,9 +91,30 @@ func (impl) Load(ctx context.Context, args args.Config) (GlobalConfig, error) {
 
 // EnvironmentForDest finds and returns an Environment matching the specified rsync
 // destination, or nil if no Environment matches.
+//
+// Daemon-style destinations (host::module/path or rsync://host/module/path)
+// match an Environment with prefix "host::module" or, failing that, "host",
+// where an IPv6 host is in brackets. Failing that, or for any other
+// destination, an Environment matches if dest starts with its prefix
+// followed by ":", so a prefix may also include a user, as in "user@host".
 func (c *globalConfig) EnvironmentForDest(ctx context.Context, dest string) EnvironmentConfig {
 	logger := log.FromContext(ctx)
 
+	if parsed := args.ParseDest(dest); parsed.Daemon {
+		host := parsed.Host
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		for _, prefix := range []string{host + "::" + parsed.Module, host} {
+			for i := range c.EnvironmentsRaw {
+				out := &c.EnvironmentsRaw[i]
+				if out.Prefix() == prefix {
+					return out
+				}
+			}
+		}
+	}
+
 	for i := range c.EnvironmentsRaw {
 		out := &c.EnvironmentsRaw[i]
 		if strings.HasPrefix(dest, out.Prefix()+":") {
@@ -98,3 +126,7 @@ func (c *globalConfig) EnvironmentForDest(ctx context.Context, dest string) Envi
 
 	return nil
 }
+
+func (c *globalConfig) SetArgs(args args.Config) {
+	c.args = args
+}
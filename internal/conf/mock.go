@@ -73,6 +73,62 @@ func (m *MockConfig) EXPECT() *MockConfigMockRecorder {
 	return m.recorder
 }
 
+// BwLimit mocks base method.
+func (m *MockConfig) BwLimit() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BwLimit")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// BwLimit indicates an expected call of BwLimit.
+func (mr *MockConfigMockRecorder) BwLimit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BwLimit", reflect.TypeOf((*MockConfig)(nil).BwLimit))
+}
+
+// CdnURL mocks base method.
+func (m *MockConfig) CdnURL() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CdnURL")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CdnURL indicates an expected call of CdnURL.
+func (mr *MockConfigMockRecorder) CdnURL() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CdnURL", reflect.TypeOf((*MockConfig)(nil).CdnURL))
+}
+
+// ChecksumCache mocks base method.
+func (m *MockConfig) ChecksumCache() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChecksumCache")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ChecksumCache indicates an expected call of ChecksumCache.
+func (mr *MockConfigMockRecorder) ChecksumCache() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChecksumCache", reflect.TypeOf((*MockConfig)(nil).ChecksumCache))
+}
+
+// Deadline mocks base method.
+func (m *MockConfig) Deadline() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deadline")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Deadline indicates an expected call of Deadline.
+func (mr *MockConfigMockRecorder) Deadline() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deadline", reflect.TypeOf((*MockConfig)(nil).Deadline))
+}
+
 // Diag mocks base method.
 func (m *MockConfig) Diag() bool {
 	m.ctrl.T.Helper()
@@ -143,6 +199,48 @@ func (mr *MockConfigMockRecorder) GwKey() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwKey", reflect.TypeOf((*MockConfig)(nil).GwKey))
 }
 
+// GwMaxAttempts mocks base method.
+func (m *MockConfig) GwMaxAttempts() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxAttempts")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxAttempts indicates an expected call of GwMaxAttempts.
+func (mr *MockConfigMockRecorder) GwMaxAttempts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxAttempts", reflect.TypeOf((*MockConfig)(nil).GwMaxAttempts))
+}
+
+// GwMaxRequestRate mocks base method.
+func (m *MockConfig) GwMaxRequestRate() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxRequestRate")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxRequestRate indicates an expected call of GwMaxRequestRate.
+func (mr *MockConfigMockRecorder) GwMaxRequestRate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxRequestRate", reflect.TypeOf((*MockConfig)(nil).GwMaxRequestRate))
+}
+
+// GwMaxRetryBackoff mocks base method.
+func (m *MockConfig) GwMaxRetryBackoff() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxRetryBackoff")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxRetryBackoff indicates an expected call of GwMaxRetryBackoff.
+func (mr *MockConfigMockRecorder) GwMaxRetryBackoff() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxRetryBackoff", reflect.TypeOf((*MockConfig)(nil).GwMaxRetryBackoff))
+}
+
 // GwPollInterval mocks base method.
 func (m *MockConfig) GwPollInterval() int {
 	m.ctrl.T.Helper()
@@ -157,6 +255,34 @@ func (mr *MockConfigMockRecorder) GwPollInterval() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwPollInterval", reflect.TypeOf((*MockConfig)(nil).GwPollInterval))
 }
 
+// GwPublishedItems mocks base method.
+func (m *MockConfig) GwPublishedItems() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwPublishedItems")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GwPublishedItems indicates an expected call of GwPublishedItems.
+func (mr *MockConfigMockRecorder) GwPublishedItems() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwPublishedItems", reflect.TypeOf((*MockConfig)(nil).GwPublishedItems))
+}
+
+// GwRetryBackoff mocks base method.
+func (m *MockConfig) GwRetryBackoff() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwRetryBackoff")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwRetryBackoff indicates an expected call of GwRetryBackoff.
+func (mr *MockConfigMockRecorder) GwRetryBackoff() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwRetryBackoff", reflect.TypeOf((*MockConfig)(nil).GwRetryBackoff))
+}
+
 // GwURL mocks base method.
 func (m *MockConfig) GwURL() string {
 	m.ctrl.T.Helper()
@@ -171,6 +297,20 @@ func (mr *MockConfigMockRecorder) GwURL() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwURL", reflect.TypeOf((*MockConfig)(nil).GwURL))
 }
 
+// GwUploadConcurrency mocks base method.
+func (m *MockConfig) GwUploadConcurrency() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwUploadConcurrency")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwUploadConcurrency indicates an expected call of GwUploadConcurrency.
+func (mr *MockConfigMockRecorder) GwUploadConcurrency() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwUploadConcurrency", reflect.TypeOf((*MockConfig)(nil).GwUploadConcurrency))
+}
+
 // LogLevel mocks base method.
 func (m *MockConfig) LogLevel() string {
 	m.ctrl.T.Helper()
@@ -213,6 +353,20 @@ func (mr *MockConfigMockRecorder) RsyncMode() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RsyncMode", reflect.TypeOf((*MockConfig)(nil).RsyncMode))
 }
 
+// Timeout mocks base method.
+func (m *MockConfig) Timeout() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Timeout")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Timeout indicates an expected call of Timeout.
+func (mr *MockConfigMockRecorder) Timeout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Timeout", reflect.TypeOf((*MockConfig)(nil).Timeout))
+}
+
 // Verbosity mocks base method.
 func (m *MockConfig) Verbosity() int {
 	m.ctrl.T.Helper()
@@ -250,6 +404,62 @@ func (m *MockEnvironmentConfig) EXPECT() *MockEnvironmentConfigMockRecorder {
 	return m.recorder
 }
 
+// BwLimit mocks base method.
+func (m *MockEnvironmentConfig) BwLimit() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BwLimit")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// BwLimit indicates an expected call of BwLimit.
+func (mr *MockEnvironmentConfigMockRecorder) BwLimit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BwLimit", reflect.TypeOf((*MockEnvironmentConfig)(nil).BwLimit))
+}
+
+// CdnURL mocks base method.
+func (m *MockEnvironmentConfig) CdnURL() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CdnURL")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CdnURL indicates an expected call of CdnURL.
+func (mr *MockEnvironmentConfigMockRecorder) CdnURL() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CdnURL", reflect.TypeOf((*MockEnvironmentConfig)(nil).CdnURL))
+}
+
+// ChecksumCache mocks base method.
+func (m *MockEnvironmentConfig) ChecksumCache() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChecksumCache")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ChecksumCache indicates an expected call of ChecksumCache.
+func (mr *MockEnvironmentConfigMockRecorder) ChecksumCache() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChecksumCache", reflect.TypeOf((*MockEnvironmentConfig)(nil).ChecksumCache))
+}
+
+// Deadline mocks base method.
+func (m *MockEnvironmentConfig) Deadline() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deadline")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Deadline indicates an expected call of Deadline.
+func (mr *MockEnvironmentConfigMockRecorder) Deadline() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deadline", reflect.TypeOf((*MockEnvironmentConfig)(nil).Deadline))
+}
+
 // Diag mocks base method.
 func (m *MockEnvironmentConfig) Diag() bool {
 	m.ctrl.T.Helper()
@@ -320,6 +530,48 @@ func (mr *MockEnvironmentConfigMockRecorder) GwKey() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwKey", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwKey))
 }
 
+// GwMaxAttempts mocks base method.
+func (m *MockEnvironmentConfig) GwMaxAttempts() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxAttempts")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxAttempts indicates an expected call of GwMaxAttempts.
+func (mr *MockEnvironmentConfigMockRecorder) GwMaxAttempts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxAttempts", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwMaxAttempts))
+}
+
+// GwMaxRequestRate mocks base method.
+func (m *MockEnvironmentConfig) GwMaxRequestRate() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxRequestRate")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxRequestRate indicates an expected call of GwMaxRequestRate.
+func (mr *MockEnvironmentConfigMockRecorder) GwMaxRequestRate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxRequestRate", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwMaxRequestRate))
+}
+
+// GwMaxRetryBackoff mocks base method.
+func (m *MockEnvironmentConfig) GwMaxRetryBackoff() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxRetryBackoff")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxRetryBackoff indicates an expected call of GwMaxRetryBackoff.
+func (mr *MockEnvironmentConfigMockRecorder) GwMaxRetryBackoff() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxRetryBackoff", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwMaxRetryBackoff))
+}
+
 // GwPollInterval mocks base method.
 func (m *MockEnvironmentConfig) GwPollInterval() int {
 	m.ctrl.T.Helper()
@@ -334,6 +586,34 @@ func (mr *MockEnvironmentConfigMockRecorder) GwPollInterval() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwPollInterval", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwPollInterval))
 }
 
+// GwPublishedItems mocks base method.
+func (m *MockEnvironmentConfig) GwPublishedItems() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwPublishedItems")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GwPublishedItems indicates an expected call of GwPublishedItems.
+func (mr *MockEnvironmentConfigMockRecorder) GwPublishedItems() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwPublishedItems", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwPublishedItems))
+}
+
+// GwRetryBackoff mocks base method.
+func (m *MockEnvironmentConfig) GwRetryBackoff() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwRetryBackoff")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwRetryBackoff indicates an expected call of GwRetryBackoff.
+func (mr *MockEnvironmentConfigMockRecorder) GwRetryBackoff() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwRetryBackoff", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwRetryBackoff))
+}
+
 // GwURL mocks base method.
 func (m *MockEnvironmentConfig) GwURL() string {
 	m.ctrl.T.Helper()
@@ -348,6 +628,20 @@ func (mr *MockEnvironmentConfigMockRecorder) GwURL() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwURL", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwURL))
 }
 
+// GwUploadConcurrency mocks base method.
+func (m *MockEnvironmentConfig) GwUploadConcurrency() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwUploadConcurrency")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwUploadConcurrency indicates an expected call of GwUploadConcurrency.
+func (mr *MockEnvironmentConfigMockRecorder) GwUploadConcurrency() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwUploadConcurrency", reflect.TypeOf((*MockEnvironmentConfig)(nil).GwUploadConcurrency))
+}
+
 // LogLevel mocks base method.
 func (m *MockEnvironmentConfig) LogLevel() string {
 	m.ctrl.T.Helper()
@@ -376,6 +670,20 @@ func (mr *MockEnvironmentConfigMockRecorder) Logger() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logger", reflect.TypeOf((*MockEnvironmentConfig)(nil).Logger))
 }
 
+// ModuleRoot mocks base method.
+func (m *MockEnvironmentConfig) ModuleRoot(arg0 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModuleRoot", arg0)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ModuleRoot indicates an expected call of ModuleRoot.
+func (mr *MockEnvironmentConfigMockRecorder) ModuleRoot(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModuleRoot", reflect.TypeOf((*MockEnvironmentConfig)(nil).ModuleRoot), arg0)
+}
+
 // Prefix mocks base method.
 func (m *MockEnvironmentConfig) Prefix() string {
 	m.ctrl.T.Helper()
@@ -404,6 +712,20 @@ func (mr *MockEnvironmentConfigMockRecorder) RsyncMode() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RsyncMode", reflect.TypeOf((*MockEnvironmentConfig)(nil).RsyncMode))
 }
 
+// Timeout mocks base method.
+func (m *MockEnvironmentConfig) Timeout() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Timeout")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Timeout indicates an expected call of Timeout.
+func (mr *MockEnvironmentConfigMockRecorder) Timeout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Timeout", reflect.TypeOf((*MockEnvironmentConfig)(nil).Timeout))
+}
+
 // Verbosity mocks base method.
 func (m *MockEnvironmentConfig) Verbosity() int {
 	m.ctrl.T.Helper()
@@ -441,6 +763,62 @@ func (m *MockGlobalConfig) EXPECT() *MockGlobalConfigMockRecorder {
 	return m.recorder
 }
 
+// BwLimit mocks base method.
+func (m *MockGlobalConfig) BwLimit() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BwLimit")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// BwLimit indicates an expected call of BwLimit.
+func (mr *MockGlobalConfigMockRecorder) BwLimit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BwLimit", reflect.TypeOf((*MockGlobalConfig)(nil).BwLimit))
+}
+
+// CdnURL mocks base method.
+func (m *MockGlobalConfig) CdnURL() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CdnURL")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CdnURL indicates an expected call of CdnURL.
+func (mr *MockGlobalConfigMockRecorder) CdnURL() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CdnURL", reflect.TypeOf((*MockGlobalConfig)(nil).CdnURL))
+}
+
+// ChecksumCache mocks base method.
+func (m *MockGlobalConfig) ChecksumCache() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChecksumCache")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ChecksumCache indicates an expected call of ChecksumCache.
+func (mr *MockGlobalConfigMockRecorder) ChecksumCache() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChecksumCache", reflect.TypeOf((*MockGlobalConfig)(nil).ChecksumCache))
+}
+
+// Deadline mocks base method.
+func (m *MockGlobalConfig) Deadline() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deadline")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Deadline indicates an expected call of Deadline.
+func (mr *MockGlobalConfigMockRecorder) Deadline() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deadline", reflect.TypeOf((*MockGlobalConfig)(nil).Deadline))
+}
+
 // Diag mocks base method.
 func (m *MockGlobalConfig) Diag() bool {
 	m.ctrl.T.Helper()
@@ -525,6 +903,48 @@ func (mr *MockGlobalConfigMockRecorder) GwKey() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwKey", reflect.TypeOf((*MockGlobalConfig)(nil).GwKey))
 }
 
+// GwMaxAttempts mocks base method.
+func (m *MockGlobalConfig) GwMaxAttempts() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxAttempts")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxAttempts indicates an expected call of GwMaxAttempts.
+func (mr *MockGlobalConfigMockRecorder) GwMaxAttempts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxAttempts", reflect.TypeOf((*MockGlobalConfig)(nil).GwMaxAttempts))
+}
+
+// GwMaxRequestRate mocks base method.
+func (m *MockGlobalConfig) GwMaxRequestRate() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxRequestRate")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxRequestRate indicates an expected call of GwMaxRequestRate.
+func (mr *MockGlobalConfigMockRecorder) GwMaxRequestRate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxRequestRate", reflect.TypeOf((*MockGlobalConfig)(nil).GwMaxRequestRate))
+}
+
+// GwMaxRetryBackoff mocks base method.
+func (m *MockGlobalConfig) GwMaxRetryBackoff() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwMaxRetryBackoff")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwMaxRetryBackoff indicates an expected call of GwMaxRetryBackoff.
+func (mr *MockGlobalConfigMockRecorder) GwMaxRetryBackoff() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwMaxRetryBackoff", reflect.TypeOf((*MockGlobalConfig)(nil).GwMaxRetryBackoff))
+}
+
 // GwPollInterval mocks base method.
 func (m *MockGlobalConfig) GwPollInterval() int {
 	m.ctrl.T.Helper()
@@ -539,6 +959,34 @@ func (mr *MockGlobalConfigMockRecorder) GwPollInterval() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwPollInterval", reflect.TypeOf((*MockGlobalConfig)(nil).GwPollInterval))
 }
 
+// GwPublishedItems mocks base method.
+func (m *MockGlobalConfig) GwPublishedItems() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwPublishedItems")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GwPublishedItems indicates an expected call of GwPublishedItems.
+func (mr *MockGlobalConfigMockRecorder) GwPublishedItems() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwPublishedItems", reflect.TypeOf((*MockGlobalConfig)(nil).GwPublishedItems))
+}
+
+// GwRetryBackoff mocks base method.
+func (m *MockGlobalConfig) GwRetryBackoff() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwRetryBackoff")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwRetryBackoff indicates an expected call of GwRetryBackoff.
+func (mr *MockGlobalConfigMockRecorder) GwRetryBackoff() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwRetryBackoff", reflect.TypeOf((*MockGlobalConfig)(nil).GwRetryBackoff))
+}
+
 // GwURL mocks base method.
 func (m *MockGlobalConfig) GwURL() string {
 	m.ctrl.T.Helper()
@@ -553,6 +1001,20 @@ func (mr *MockGlobalConfigMockRecorder) GwURL() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwURL", reflect.TypeOf((*MockGlobalConfig)(nil).GwURL))
 }
 
+// GwUploadConcurrency mocks base method.
+func (m *MockGlobalConfig) GwUploadConcurrency() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GwUploadConcurrency")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GwUploadConcurrency indicates an expected call of GwUploadConcurrency.
+func (mr *MockGlobalConfigMockRecorder) GwUploadConcurrency() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GwUploadConcurrency", reflect.TypeOf((*MockGlobalConfig)(nil).GwUploadConcurrency))
+}
+
 // LogLevel mocks base method.
 func (m *MockGlobalConfig) LogLevel() string {
 	m.ctrl.T.Helper()
@@ -595,6 +1057,32 @@ func (mr *MockGlobalConfigMockRecorder) RsyncMode() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RsyncMode", reflect.TypeOf((*MockGlobalConfig)(nil).RsyncMode))
 }
 
+// SetArgs mocks base method.
+func (m *MockGlobalConfig) SetArgs(arg0 args.Config) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetArgs", arg0)
+}
+
+// SetArgs indicates an expected call of SetArgs.
+func (mr *MockGlobalConfigMockRecorder) SetArgs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetArgs", reflect.TypeOf((*MockGlobalConfig)(nil).SetArgs), arg0)
+}
+
+// Timeout mocks base method.
+func (m *MockGlobalConfig) Timeout() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Timeout")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Timeout indicates an expected call of Timeout.
+func (mr *MockGlobalConfigMockRecorder) Timeout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Timeout", reflect.TypeOf((*MockGlobalConfig)(nil).Timeout))
+}
+
 // Verbosity mocks base method.
 func (m *MockGlobalConfig) Verbosity() int {
 	m.ctrl.T.Helper()
@@ -8,16 +8,44 @@ import (
 )
 
 type sharedConfig struct {
-	GwEnvRaw          string `yaml:"gwenv"`
-	GwCertRaw         string `yaml:"gwcert"`
-	GwKeyRaw          string `yaml:"gwkey"`
-	GwURLRaw          string `yaml:"gwurl"`
-	GwPollIntervalRaw int    `yaml:"gwpollinterval"`
-	GwBatchSizeRaw    int    `yaml:"gwbatchsize"`
-	RsyncModeRaw      string `yaml:"rsyncmode"`
-	LogLevelRaw       string `yaml:"loglevel"`
-	LoggerRaw         string `yaml:"logger"`
-	DiagRaw           bool   `yaml:"diag"`
+	GwEnvRaw               string `yaml:"gwenv"`
+	GwCertRaw              string `yaml:"gwcert"`
+	GwKeyRaw               string `yaml:"gwkey"`
+	GwURLRaw               string `yaml:"gwurl"`
+	GwPollIntervalRaw      int    `yaml:"gwpollinterval"`
+	GwMaxAttemptsRaw       int    `yaml:"gwmaxattempts"`
+	GwRetryBackoffRaw      int    `yaml:"gwretrybackoff"`
+	GwMaxRetryBackoffRaw   int    `yaml:"gwmaxretrybackoff"`
+	GwBatchSizeRaw         int    `yaml:"gwbatchsize"`
+	GwUploadConcurrencyRaw int    `yaml:"gwuploadconcurrency"`
+	GwMaxRequestRateRaw    int    `yaml:"gwmaxrequestrate"`
+	GwPublishedItemsRaw    bool   `yaml:"gwpublisheditems"`
+	CdnURLRaw              string `yaml:"cdnurl"`
+	RsyncModeRaw           string `yaml:"rsyncmode"`
+	LogLevelRaw            string `yaml:"loglevel"`
+	LoggerRaw              string `yaml:"logger"`
+	DiagRaw                bool   `yaml:"diag"`
+	ChecksumCacheRaw       bool   `yaml:"checksumcache"`
+	DeadlineRaw            int    `yaml:"deadline"`
+}
+
+// validate returns an error if any setting has a value which can't be used.
+// Zero values are accepted, as they mean the default should be used.
+func (s *sharedConfig) validate() error {
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"gwmaxattempts", s.GwMaxAttemptsRaw},
+		{"gwretrybackoff", s.GwRetryBackoffRaw},
+		{"gwmaxretrybackoff", s.GwMaxRetryBackoffRaw},
+		{"gwuploadconcurrency", s.GwUploadConcurrencyRaw},
+	} {
+		if setting.value < 0 {
+			return fmt.Errorf("invalid value for '%s': %d, must be at least 1", setting.name, setting.value)
+		}
+	}
+	return nil
 }
 
 type environment struct {
@@ -26,6 +54,9 @@ type environment struct {
 
 	PrefixRaw string `yaml:"prefix"`
 
+	// Path roots for modules of daemon-style destinations, by module name.
+	ModulesRaw map[string]string `yaml:"modules"`
+
 	parent *globalConfig
 }
 
@@ -67,10 +98,38 @@ func (g *globalConfig) GwPollInterval() int {
 	return nonEmptyInt(g.GwPollIntervalRaw, 5000)
 }
 
+func (g *globalConfig) GwMaxAttempts() int {
+	return nonEmptyInt(g.GwMaxAttemptsRaw, 5)
+}
+
+func (g *globalConfig) GwRetryBackoff() int {
+	return nonEmptyInt(g.GwRetryBackoffRaw, 1000)
+}
+
+func (g *globalConfig) GwMaxRetryBackoff() int {
+	return nonEmptyInt(g.GwMaxRetryBackoffRaw, 60000)
+}
+
 func (g *globalConfig) GwBatchSize() int {
 	return nonEmptyInt(g.GwBatchSizeRaw, 10000)
 }
 
+func (g *globalConfig) GwUploadConcurrency() int {
+	return nonEmptyInt(g.GwUploadConcurrencyRaw, 4)
+}
+
+func (g *globalConfig) GwMaxRequestRate() int {
+	return g.GwMaxRequestRateRaw
+}
+
+func (g *globalConfig) GwPublishedItems() bool {
+	return g.GwPublishedItemsRaw
+}
+
+func (g *globalConfig) CdnURL() string {
+	return g.CdnURLRaw
+}
+
 func nonEmptyString(a, b string) string {
 	if a != "" {
 		return a
@@ -101,10 +160,26 @@ func (g *globalConfig) Verbosity() int {
 	return g.args.Verbose
 }
 
+func (g *globalConfig) BwLimit() int64 {
+	return g.args.BwLimit.Bytes()
+}
+
+func (g *globalConfig) Timeout() int {
+	return g.args.Timeout
+}
+
+func (g *globalConfig) Deadline() int {
+	return nonEmptyInt(g.args.Deadline, g.DeadlineRaw)
+}
+
 func (g *globalConfig) Diag() bool {
 	return g.args.Diag || g.DiagRaw
 }
 
+func (g *globalConfig) ChecksumCache() bool {
+	return g.ChecksumCacheRaw && !g.args.NoChecksumCache
+}
+
 func (e *environment) GwCert() string {
 	return nonEmptyString(e.GwCertRaw, e.parent.GwCert())
 }
@@ -125,10 +200,38 @@ func (e *environment) GwPollInterval() int {
 	return nonEmptyInt(e.GwPollIntervalRaw, e.parent.GwPollInterval())
 }
 
+func (e *environment) GwMaxAttempts() int {
+	return nonEmptyInt(e.GwMaxAttemptsRaw, e.parent.GwMaxAttempts())
+}
+
+func (e *environment) GwRetryBackoff() int {
+	return nonEmptyInt(e.GwRetryBackoffRaw, e.parent.GwRetryBackoff())
+}
+
+func (e *environment) GwMaxRetryBackoff() int {
+	return nonEmptyInt(e.GwMaxRetryBackoffRaw, e.parent.GwMaxRetryBackoff())
+}
+
 func (e *environment) GwBatchSize() int {
 	return nonEmptyInt(e.GwBatchSizeRaw, e.parent.GwBatchSize())
 }
 
+func (e *environment) GwUploadConcurrency() int {
+	return nonEmptyInt(e.GwUploadConcurrencyRaw, e.parent.GwUploadConcurrency())
+}
+
+func (e *environment) GwMaxRequestRate() int {
+	return nonEmptyInt(e.GwMaxRequestRateRaw, e.parent.GwMaxRequestRate())
+}
+
+func (e *environment) GwPublishedItems() bool {
+	return e.GwPublishedItemsRaw || e.parent.GwPublishedItems()
+}
+
+func (e *environment) CdnURL() string {
+	return nonEmptyString(e.CdnURLRaw, e.parent.CdnURL())
+}
+
 func (e *environment) RsyncMode() string {
 	return nonEmptyString(e.RsyncModeRaw, e.parent.RsyncMode())
 }
@@ -145,10 +248,30 @@ func (e *environment) Verbosity() int {
 	return nonEmptyInt(e.args.Verbose, e.parent.Verbosity())
 }
 
+func (e *environment) BwLimit() int64 {
+	return e.parent.BwLimit()
+}
+
+func (e *environment) Timeout() int {
+	return e.parent.Timeout()
+}
+
+func (e *environment) Deadline() int {
+	return nonEmptyInt(e.parent.args.Deadline, nonEmptyInt(e.DeadlineRaw, e.parent.Deadline()))
+}
+
 func (e *environment) Diag() bool {
 	return e.DiagRaw || e.parent.Diag()
 }
 
+func (e *environment) ChecksumCache() bool {
+	return (e.ChecksumCacheRaw || e.parent.ChecksumCacheRaw) && !e.parent.args.NoChecksumCache
+}
+
 func (e *environment) Prefix() string {
 	return e.PrefixRaw
 }
+
+func (e *environment) ModuleRoot(module string) string {
+	return e.ModulesRaw[module]
+}
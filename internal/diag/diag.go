@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/cache"
 	"github.com/release-engineering/exodus-rsync/internal/conf"
 	"github.com/release-engineering/exodus-rsync/internal/gw"
 	"github.com/release-engineering/exodus-rsync/internal/log"
@@ -63,7 +64,17 @@ func logConfig(ctx context.Context, cfg conf.Config) {
 		"gwurl", cfg.GwURL(),
 		"gwenv", cfg.GwEnv(),
 		"gwpollinterval", cfg.GwPollInterval(),
+		"gwmaxattempts", cfg.GwMaxAttempts(),
+		"gwretrybackoff", cfg.GwRetryBackoff(),
+		"gwmaxretrybackoff", cfg.GwMaxRetryBackoff(),
 		"gwbatchsize", cfg.GwBatchSize(),
+		"gwuploadconcurrency", cfg.GwUploadConcurrency(),
+		"gwmaxrequestrate", cfg.GwMaxRequestRate(),
+		"gwpublisheditems", cfg.GwPublishedItems(),
+		"cdnurl", cfg.CdnURL(),
+		"bwlimit", cfg.BwLimit(),
+		"timeout", cfg.Timeout(),
+		"deadline", cfg.Deadline(),
 	).Warn("exodus-gw")
 
 	logger.F(
@@ -71,6 +82,11 @@ func logConfig(ctx context.Context, cfg conf.Config) {
 		"logger", cfg.Logger(),
 		"verbosity", cfg.Verbosity(),
 	).Warn("logging")
+
+	logger.F(
+		"checksumcache", cfg.ChecksumCache(),
+		"path", cache.DefaultPath(),
+	).Warn("checksum cache")
 }
 
 func logGw(ctx context.Context, cfg conf.Config) {
@@ -121,7 +137,7 @@ func logSrctree(ctx context.Context, cfg conf.Config, args args.Config) {
 
 	logger.Warn("=============== diagnostics: srctree ================")
 
-	err := filepath.Walk(args.Src, func(path string, info fs.FileInfo, err error) error {
+	walkFn := func(path string, info fs.FileInfo, err error) error {
 		name := ""
 		time := time.Time{}
 		size := int64(-1)
@@ -145,9 +161,12 @@ func logSrctree(ctx context.Context, cfg conf.Config, args args.Config) {
 			"size", size, "mode", mode, "dest", dest).Warn("item")
 
 		return nil
-	})
+	}
 
-	logger.F("error", err).Warn("completed walk of source tree")
+	for _, src := range args.Src {
+		err := filepath.Walk(src, walkFn)
+		logger.F("src", src, "error", err).Warn("completed walk of source tree")
+	}
 }
 
 func logFilters(ctx context.Context, cfg conf.Config, args args.Config) {
@@ -156,9 +175,11 @@ func logFilters(ctx context.Context, cfg conf.Config, args args.Config) {
 	logger.Warn("=============== diagnostics: filters ================")
 
 	logger.F("exclude", args.Exclude, "include", args.Include,
-		"filter", args.Filter, "filesfrom", args.FilesFrom).Warn("filter arguments")
+		"filter", args.Filter, "rules", args.FilterRules(),
+		"filesfrom", args.FilesFrom).Warn("filter arguments")
 
-	if args.FilesFrom != "" {
+	// stdin can't be read here, as it's needed for the sync.
+	if args.FilesFrom != "" && args.FilesFrom != "-" {
 		content, err := os.ReadFile(args.FilesFrom)
 
 		if err != nil {
@@ -23,11 +23,22 @@ func mockConfig(ctrl *gomock.Controller) conf.Config {
 	e.GwURL().Return("test-url").AnyTimes()
 	e.GwEnv().Return("test-env").AnyTimes()
 	e.GwPollInterval().Return(123).AnyTimes()
+	e.GwMaxAttempts().Return(3).AnyTimes()
+	e.GwRetryBackoff().Return(10).AnyTimes()
+	e.GwMaxRetryBackoff().Return(100).AnyTimes()
 	e.GwBatchSize().Return(234).AnyTimes()
+	e.GwUploadConcurrency().Return(5).AnyTimes()
+	e.GwMaxRequestRate().Return(0).AnyTimes()
+	e.GwPublishedItems().Return(false).AnyTimes()
+	e.CdnURL().Return("test-cdn-url").AnyTimes()
+	e.BwLimit().Return(int64(0)).AnyTimes()
+	e.Timeout().Return(0).AnyTimes()
+	e.Deadline().Return(0).AnyTimes()
 	e.RsyncMode().Return("mixed").AnyTimes()
 	e.LogLevel().Return("debug").AnyTimes()
 	e.Logger().Return("syslog").AnyTimes()
 	e.Verbosity().Return(3).AnyTimes()
+	e.ChecksumCache().Return(true).AnyTimes()
 	e.Prefix().Return("test-prefix").AnyTimes()
 
 	return out
@@ -53,7 +64,7 @@ func TestDiagRun(t *testing.T) {
 
 	args := args.Config{}
 
-	args.Src = srcPath
+	args.Src = []string{srcPath}
 	args.Dest = "whatever-dest"
 
 	ctx := context.Background()
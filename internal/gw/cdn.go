@@ -0,0 +1,114 @@
+package gw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/release-engineering/exodus-rsync/internal/syncutil"
+)
+
+// ErrNoCdnURL is returned by ArePublished if 'cdnurl' is not configured.
+var ErrNoCdnURL = errors.New("checking for published content requires 'cdnurl' in configuration")
+
+// isPublished returns true if uri can be found on the CDN at 'cdnurl'.
+func (c *client) isPublished(ctx context.Context, uri string) (bool, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.cfg.CdnURL(), "/"))
+	if err != nil {
+		return false, err
+	}
+	u.Path += uri
+
+	var out bool
+	err = c.retry(ctx, "HEAD "+u.String(), func() error {
+		published, err := c.isPublishedOnce(ctx, u.String())
+		out = published
+		return err
+	})
+	return out, err
+}
+
+func (c *client) isPublishedOnce(ctx context.Context, url string) (bool, error) {
+	if err := c.requestLimiter.Wait(ctx, 1); err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, asTransient(err, nil)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusGone:
+		return false, nil
+	}
+
+	httpErr := newHTTPError(req, resp)
+	if isTransientStatus(resp.StatusCode) {
+		return false, &transientError{err: httpErr, reason: resp.Status, retryAfter: retryAfter(resp)}
+	}
+	return false, httpErr
+}
+
+func (c *client) ArePublished(ctx context.Context, uris []string) ([]bool, error) {
+	out := make([]bool, len(uris))
+	if len(uris) == 0 {
+		return out, nil
+	}
+
+	if c.cfg.CdnURL() == "" {
+		return nil, ErrNoCdnURL
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The first error stops all other checks, which then fail only as a
+	// consequence of the cancellation.
+	var failOnce sync.Once
+	var failure error
+
+	indexes := make(chan int)
+
+	go func() {
+		defer close(indexes)
+		for i := range uris {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	syncutil.RunWithGroup(c.cfg.GwUploadConcurrency(),
+		func() {
+			for i := range indexes {
+				published, err := c.isPublished(ctx, uris[i])
+				if err != nil {
+					failOnce.Do(func() { failure = err })
+					cancel()
+					continue
+				}
+				out[i] = published
+			}
+		},
+		func() {},
+	)
+
+	if failure != nil {
+		return nil, failure
+	}
+	return out, nil
+}
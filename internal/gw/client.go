@@ -9,6 +9,8 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -18,6 +20,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/release-engineering/exodus-rsync/internal/conf"
 	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/progress"
+	"github.com/release-engineering/exodus-rsync/internal/ratelimit"
+	"github.com/release-engineering/exodus-rsync/internal/syncutil"
 	"github.com/release-engineering/exodus-rsync/internal/walk"
 )
 
@@ -27,19 +32,55 @@ type client struct {
 	s3         *s3.S3
 	uploader   *s3manager.Uploader
 	dryRun     bool
+
+	// Limits shared by all requests and uploads; nil if unlimited.
+	requestLimiter *ratelimit.Limiter
+	uploadLimiter  *ratelimit.Limiter
+
+	// Keys of blobs known to be present in exodus-gw, as found or
+	// uploaded earlier in this run.
+	ensured sync.Map
+}
+
+// Returns true for HTTP methods which are safe to retry.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	}
+	return false
 }
 
 func (c *client) doJSONRequest(ctx context.Context, method string, url string, body interface{}, target interface{}) error {
-	var bodyReader io.Reader
-	if body == nil {
-		bodyReader = nil
-	} else {
+	var bodyBytes []byte
+	if body != nil {
 		buf := bytes.Buffer{}
 		enc := json.NewEncoder(&buf)
 		if err := enc.Encode(body); err != nil {
 			return fmt.Errorf("encoding request body: %w", err)
 		}
-		bodyReader = &buf
+		bodyBytes = buf.Bytes()
+	}
+
+	attempt := func() error {
+		return c.doJSONRequestOnce(ctx, method, url, bodyBytes, target)
+	}
+
+	if !isIdempotent(method) {
+		return attempt()
+	}
+
+	return c.retry(ctx, method+" "+url, attempt)
+}
+
+func (c *client) doJSONRequestOnce(ctx context.Context, method string, url string, body []byte, target interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	if err := c.requestLimiter.Wait(ctx, 1); err != nil {
+		return err
 	}
 
 	fullURL := c.cfg.GwURL() + url
@@ -54,28 +95,23 @@ func (c *client) doJSONRequest(ctx context.Context, method string, url string, b
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return asTransient(err, nil)
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		byteSlice := make([]byte, 2000)
-		bytesRead, err := resp.Body.Read(byteSlice)
-		if err != nil {
-			log.FromContext(ctx).F("error", err).Debugf(
-				"No body in response for '%s %s'", req.Method, req.URL,
-			)
-		} else if bytesRead > 0 {
-			return fmt.Errorf("%s %s: %s, %s", req.Method, req.URL, resp.Status, byteSlice)
+		httpErr := newHTTPError(req, resp)
+		if isTransientStatus(resp.StatusCode) {
+			return &transientError{err: httpErr, reason: resp.Status, retryAfter: retryAfter(resp)}
 		}
-		return fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+		return httpErr
 	}
 
 	dec := json.NewDecoder(resp.Body)
 	err = dec.Decode(target)
 	if err != nil {
-		return fmt.Errorf("%s %s: %w", req.Method, req.URL, err)
+		return asTransient(fmt.Errorf("%s %s: %w", req.Method, req.URL, err), nil)
 	}
 
 	return nil
@@ -90,9 +126,16 @@ func (c *client) WhoAmI(ctx context.Context) (map[string]interface{}, error) {
 func (c *client) haveBlob(ctx context.Context, item walk.SyncItem) (bool, error) {
 	logger := log.FromContext(ctx)
 
-	_, err := c.s3.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(c.cfg.GwEnv()),
-		Key:    aws.String(item.Key),
+	err := c.retry(ctx, "HEAD "+item.Key, func() error {
+		req, _ := c.s3.HeadObjectRequest(&s3.HeadObjectInput{
+			Bucket: aws.String(c.cfg.GwEnv()),
+			Key:    aws.String(item.Key),
+		})
+		req.SetContext(ctx)
+		req.ApplyOptions(c.limitRequest)
+
+		err := req.Send()
+		return asTransient(err, req.HTTPResponse)
 	})
 
 	if err == nil {
@@ -125,52 +168,162 @@ func (c *client) uploadBlob(ctx context.Context, item walk.SyncItem) error {
 		return nil
 	}
 
-	file, err := os.Open(item.SrcPath)
-	if err != nil {
-		return err
+	var res *s3manager.UploadOutput
+
+	var fileProgress *progress.File
+	if reporter := progress.FromContext(ctx); reporter != nil && item.Info != nil {
+		fileProgress = reporter.Start(progress.Uploading, item.SrcPath, item.Info.Size())
 	}
-	defer file.Close()
 
-	res, err := c.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
-		Bucket: aws.String(c.cfg.GwEnv()),
-		Key:    &item.Key,
-		Body:   file,
+	err = c.retry(ctx, "upload "+item.Key, func() error {
+		// The file is reopened on each attempt, so that every attempt
+		// sends the content from the beginning.
+		file, err := os.Open(item.SrcPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fileProgress.Restart()
+
+		res, err = c.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+			Bucket: aws.String(c.cfg.GwEnv()),
+			Key:    &item.Key,
+			Body:   c.limitReader(ctx, fileProgress.Reader(file)),
+		})
+		if err != nil {
+			return asTransient(fmt.Errorf("upload %s: %w", item.SrcPath, err), nil)
+		}
+		return nil
 	})
 
 	if err != nil {
-		return fmt.Errorf("upload %s: %w", item.SrcPath, err)
+		return err
 	}
 
+	fileProgress.Finish()
+
 	logger.F("location", res.Location).Debug("uploaded blob")
 
 	return nil
 }
 
+// uploadJob tracks the check & upload of a single blob during EnsureUploaded.
+//
+// Items sharing the same key also share a single job, so each blob is only
+// checked and uploaded once.
+type uploadJob struct {
+	item     walk.SyncItem
+	uploaded bool
+	err      error
+	done     chan struct{}
+}
+
+func (c *client) ensureBlob(ctx context.Context, job *uploadJob) {
+	defer close(job.done)
+
+	have, err := c.haveBlob(ctx, job.item)
+	if err != nil {
+		job.err = fmt.Errorf("checking for presence of %s: %w", job.item.Key, err)
+		return
+	}
+
+	if !have {
+		job.err = c.uploadBlob(ctx, job.item)
+		job.uploaded = job.err == nil
+	}
+
+	if job.err == nil {
+		c.ensured.Store(job.item.Key, true)
+	}
+}
+
 func (c *client) EnsureUploaded(
 	ctx context.Context,
 	items []walk.SyncItem,
 	onUploaded func(walk.SyncItem) error,
 	onPresent func(walk.SyncItem) error,
 ) error {
-	// TODO: concurrency
-	for _, item := range items {
-		// Check if it's present
-		have, err := c.haveBlob(ctx, item)
-		if err != nil {
-			return fmt.Errorf("checking for presence of %s: %w", item.Key, err)
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Work out which job is responsible for each item. An item has no job
+	// if its blob was already ensured earlier in this run.
+	jobs := make([]*uploadJob, len(items))
+	owner := make([]bool, len(items))
+	byKey := make(map[string]*uploadJob)
+
+	for i, item := range items {
+		if _, ok := c.ensured.Load(item.Key); ok {
+			continue
 		}
+		job, ok := byKey[item.Key]
+		if !ok {
+			job = &uploadJob{item: item, done: make(chan struct{})}
+			byKey[item.Key] = job
+			owner[i] = true
+		}
+		jobs[i] = job
+	}
 
-		if have {
-			if err = onPresent(item); err != nil {
-				return err
+	queue := make(chan *uploadJob)
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(queue)
+		for i, job := range jobs {
+			if !owner[i] {
+				continue
+			}
+			select {
+			case queue <- job:
+			case <-ctx.Done():
+				return
 			}
-			continue
 		}
+	}()
 
-		if err = c.uploadBlob(ctx, item); err != nil {
-			return err
+	go syncutil.RunWithGroup(c.cfg.GwUploadConcurrency(),
+		func() {
+			for job := range queue {
+				c.ensureBlob(ctx, job)
+			}
+		},
+		func() {
+			close(finished)
+		},
+	)
+
+	// Whatever happens, don't return until all workers have stopped.
+	defer func() {
+		cancel()
+		<-finished
+	}()
+
+	// Blobs are processed concurrently, but callbacks are always invoked
+	// in the same order as items, and the returned error is always the
+	// error of the earliest failing item.
+	for i, item := range items {
+		job := jobs[i]
+
+		if job != nil {
+			select {
+			case <-job.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			if job.err != nil {
+				return job.err
+			}
+		}
+
+		var err error
+		if owner[i] && job.uploaded {
+			err = onUploaded(item)
+		} else {
+			err = onPresent(item)
 		}
-		if err = onUploaded(item); err != nil {
+		if err != nil {
 			return err
 		}
 	}
@@ -184,13 +337,20 @@ func (impl) NewClient(ctx context.Context, cfg conf.Config) (Client, error) {
 		return nil, fmt.Errorf("can't load cert/key: %w", err)
 	}
 
-	out := &client{cfg: cfg}
+	out := &client{
+		cfg:            cfg,
+		requestLimiter: ratelimit.New(float64(cfg.GwMaxRequestRate())),
+		uploadLimiter:  ratelimit.New(float64(cfg.BwLimit())),
+	}
 
 	transport := http.Transport{
 		TLSClientConfig: &tls.Config{
 			Certificates: []tls.Certificate{cert},
 		},
 	}
+	if timeout := cfg.Timeout(); timeout > 0 {
+		setTimeout(&transport, time.Duration(timeout)*time.Second)
+	}
 	out.httpClient = &http.Client{Transport: &transport}
 
 	awsLogLevel := aws.LogOff
@@ -205,9 +365,11 @@ func (impl) NewClient(ctx context.Context, cfg conf.Config) (Client, error) {
 			S3ForcePathStyle: aws.Bool(true),
 			Region:           aws.String("us-east-1"),
 			Credentials:      credentials.AnonymousCredentials,
-			HTTPClient:       out.httpClient,
-			Logger:           log.FromContext(ctx),
-			LogLevel:         aws.LogLevel(awsLogLevel),
+			// Retries are handled by our own retry policy.
+			MaxRetries: aws.Int(0),
+			HTTPClient: out.httpClient,
+			Logger:     log.FromContext(ctx),
+			LogLevel:   aws.LogLevel(awsLogLevel),
 		},
 	})
 	if err != nil {
@@ -215,7 +377,9 @@ func (impl) NewClient(ctx context.Context, cfg conf.Config) (Client, error) {
 	}
 
 	out.s3 = s3.New(sess)
-	out.uploader = s3manager.NewUploaderWithClient(out.s3)
+	out.uploader = s3manager.NewUploaderWithClient(out.s3, func(u *s3manager.Uploader) {
+		u.RequestOptions = append(u.RequestOptions, out.limitRequest)
+	})
 
 	return out, nil
 }
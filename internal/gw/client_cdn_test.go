@@ -0,0 +1,121 @@
+package gw
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/release-engineering/exodus-rsync/internal/conf"
+)
+
+// A RoundTripper serving HEAD requests to the CDN, tracking the number of
+// requests in progress at once.
+type cdnTransport struct {
+	mu        sync.Mutex
+	requested []string
+	failures  map[string][]int
+
+	active    int32
+	maxActive int32
+}
+
+func (c *cdnTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	active := atomic.AddInt32(&c.active, 1)
+	defer atomic.AddInt32(&c.active, -1)
+
+	c.mu.Lock()
+	if active > c.maxActive {
+		c.maxActive = active
+	}
+	c.requested = append(c.requested, r.Method+" "+r.URL.String())
+
+	// Any failures for the path are returned first, one per request.
+	path := strings.TrimPrefix(r.URL.Path, "/content")
+	failures := c.failures[path]
+	if len(failures) > 0 {
+		c.failures[path] = failures[1:]
+	}
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	switch {
+	case len(failures) > 0:
+		return jsonResponse(failures[0], ""), nil
+	case strings.HasPrefix(path, "/published/"):
+		return jsonResponse(200, ""), nil
+	case strings.HasPrefix(path, "/gone/"):
+		return jsonResponse(410, ""), nil
+	}
+	return jsonResponse(404, ""), nil
+}
+
+func TestClientArePublished(t *testing.T) {
+	c, ctx := newRetryTestClient(t)
+
+	transport := &cdnTransport{failures: map[string][]int{"/published/retried": {503, 502}}}
+	c.httpClient.Transport = transport
+
+	uris := []string{
+		"/published/a", "/other/b", "/gone/c", "/published/retried",
+		"/published/e f", "/other/g", "/other/h", "/published/i",
+	}
+	got, err := c.ArePublished(ctx, uris)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []bool{true, false, false, true, true, false, false, true}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ArePublished() = %v, expected %v", got, expected)
+	}
+
+	// Each URI should have been checked on the CDN, with transient errors
+	// retried.
+	if len(transport.requested) != len(uris)+2 {
+		t.Errorf("unexpected requests: %v", transport.requested)
+	}
+	for _, req := range transport.requested {
+		if !strings.HasPrefix(req, "HEAD https://cdn.example.com/content/") {
+			t.Errorf("unexpected request: %v", req)
+		}
+	}
+
+	// Checks should have been concurrent, within the configured limit.
+	if transport.maxActive < 2 || transport.maxActive > 4 {
+		t.Errorf("unexpected number of concurrent requests: %v", transport.maxActive)
+	}
+}
+
+func TestClientArePublishedErrors(t *testing.T) {
+	c, ctx := newRetryTestClient(t)
+
+	transport := &cdnTransport{failures: map[string][]int{"/other/broken": {500}}}
+	c.httpClient.Transport = transport
+
+	_, err := c.ArePublished(ctx, []string{"/published/a", "/other/broken", "/other/c"})
+	if err == nil || err.Error() != "HEAD https://cdn.example.com/content/other/broken: 500 Internal Server Error" {
+		t.Errorf("did not get expected error, got %v", err)
+	}
+
+	// Without 'cdnurl', nothing can be checked.
+	cfg := conf.NewMockConfig(gomock.NewController(t))
+	cfg.EXPECT().CdnURL().AnyTimes().Return("")
+	c.cfg = cfg
+
+	_, err = c.ArePublished(ctx, []string{"/published/a"})
+	if err != ErrNoCdnURL {
+		t.Errorf("did not get expected error, got %v", err)
+	}
+
+	// ...though there's no need to check nothing.
+	got, err := c.ArePublished(ctx, nil)
+	if err != nil || len(got) != 0 {
+		t.Errorf("ArePublished() = %v, %v for no URIs", got, err)
+	}
+}
@@ -0,0 +1,128 @@
+package gw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestClientHTTPError(t *testing.T) {
+	longBody := strings.Repeat("x", 5000)
+
+	tests := []struct {
+		name       string
+		code       int
+		body       string
+		wantDetail string
+		wantBody   string
+		wantError  string
+	}{
+		{"string detail", 404, `{"detail": "Publish not found"}`,
+			"Publish not found", `{"detail": "Publish not found"}`,
+			"GET https://exodus-gw.example.com/whoami: 404 Not Found: Publish not found"},
+
+		{"structured detail", 422, `{"detail": [{"loc": ["body"], "msg": "bad"}]}`,
+			`[{"loc": ["body"], "msg": "bad"}]`, `{"detail": [{"loc": ["body"], "msg": "bad"}]}`,
+			`422 Unprocessable Entity: [{"loc": ["body"], "msg": "bad"}]`},
+
+		{"non-JSON body", 400, longBody,
+			"", longBody,
+			"400 Bad Request, " + longBody},
+
+		{"no body", 403, "",
+			"", "",
+			"GET https://exodus-gw.example.com/whoami: 403 Forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ctx := newRetryTestClient(t)
+			c.httpClient.Transport = &sequenceTransport{
+				responses: []*http.Response{jsonResponse(tt.code, tt.body)},
+			}
+
+			_, err := c.WhoAmI(ctx)
+
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("did not get HTTPError, got: %v", err)
+			}
+
+			if httpErr.Method != "GET" || httpErr.URL != "https://exodus-gw.example.com/whoami" {
+				t.Errorf("unexpected request info: %v %v", httpErr.Method, httpErr.URL)
+			}
+			if httpErr.StatusCode != tt.code {
+				t.Errorf("unexpected status code %v", httpErr.StatusCode)
+			}
+			if httpErr.Detail != tt.wantDetail {
+				t.Errorf("unexpected detail %q", httpErr.Detail)
+			}
+			if httpErr.Body != tt.wantBody {
+				t.Errorf("unexpected body %q", httpErr.Body)
+			}
+			if !strings.HasSuffix(err.Error(), tt.wantError) {
+				t.Errorf("unexpected error message: %v", err)
+			}
+
+			if !IsProtocolError(err) {
+				t.Error("HTTPError not considered a protocol error")
+			}
+		})
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	timeout := &net.DNSError{Err: "timed out", IsTimeout: true}
+	reset := &url.Error{Op: "Get", URL: "/", Err: syscall.ECONNRESET}
+
+	tests := []struct {
+		name        string
+		err         error
+		timeout     bool
+		connection  bool
+		protocol    bool
+		unsupported bool
+	}{
+		{"plain", fmt.Errorf("oops"), false, false, false, false},
+		{"file error", &os.PathError{Op: "lstat", Path: "/x", Err: syscall.ENOENT}, false, false, false, false},
+		{"cancelled", context.Canceled, false, false, false, false},
+		{"timeout", fmt.Errorf("request: %w", timeout), true, true, false, false},
+		{"reset", reset, false, true, false, false},
+		{"aws wrapping timeout",
+			awserr.New("RequestError", "send request failed", timeout), true, true, false, false},
+		{"aws request failure",
+			awserr.NewRequestFailure(awserr.New("Forbidden", "denied", nil), 403, "id"),
+			false, false, true, false},
+		{"invalid JSON", fmt.Errorf("GET /: %w", &json.SyntaxError{}), false, false, true, false},
+		{"HTTP error", &HTTPError{StatusCode: 500}, false, false, true, false},
+		{"HTTP not found", fmt.Errorf("GET /: %w", &HTTPError{StatusCode: 404}), false, false, true, true},
+		{"HTTP method not allowed", &HTTPError{StatusCode: 405}, false, false, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTimeout(tt.err); got != tt.timeout {
+				t.Errorf("IsTimeout = %v", got)
+			}
+			if got := IsConnectionError(tt.err); got != tt.connection {
+				t.Errorf("IsConnectionError = %v", got)
+			}
+			if got := IsProtocolError(tt.err); got != tt.protocol {
+				t.Errorf("IsProtocolError = %v", got)
+			}
+			if got := IsUnsupported(tt.err); got != tt.unsupported {
+				t.Errorf("IsUnsupported = %v", got)
+			}
+		})
+	}
+}
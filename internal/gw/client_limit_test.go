@@ -0,0 +1,102 @@
+package gw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/ratelimit"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+func TestClientRequestRate(t *testing.T) {
+	client, s3 := newClientWithFakeS3(t)
+	client.requestLimiter = ratelimit.New(20)
+
+	s3.blobs["abc123"] = nil
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := client.haveBlob(ctx, walk.SyncItem{Key: "abc123"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// It should have waited between each request.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("requests were not limited, took %v", elapsed)
+	}
+}
+
+func TestClientRequestRateCanceled(t *testing.T) {
+	client, _ := newClientWithFakeS3(t)
+	client.requestLimiter = ratelimit.New(0.001)
+
+	// Nothing should be sent while waiting.
+	newFakeGw(t, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	client.requestLimiter.Wait(ctx, 1)
+	cancel()
+
+	if _, err := client.WhoAmI(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := client.haveBlob(ctx, walk.SyncItem{Key: "abc123"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClientBwLimit(t *testing.T) {
+	client, _ := newClientWithFakeS3(t)
+	client.uploadLimiter = ratelimit.New(1000)
+
+	content := strings.Repeat("x", 300)
+	reader := client.limitReader(context.Background(), strings.NewReader(content))
+
+	start := time.Now()
+
+	buf := make([]byte, 100)
+	if _, err := reader.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	out := ""
+	for {
+		n, err := reader.Read(buf)
+		out += string(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if out != content {
+		t.Errorf("unexpected content: %q", out)
+	}
+
+	// Each read should have waited for the bytes of the previous read.
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("reads were not limited, took %v", elapsed)
+	}
+}
+
+func TestClientNoBwLimit(t *testing.T) {
+	client, _ := newClientWithFakeS3(t)
+
+	rd := strings.NewReader("x")
+	if client.limitReader(context.Background(), rd) != rd {
+		t.Error("reader was wrapped without a limit")
+	}
+}
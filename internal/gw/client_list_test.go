@@ -0,0 +1,57 @@
+package gw
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+)
+
+func TestClientListPublished(t *testing.T) {
+	cfg := testConfig(t)
+
+	clientIface, err := Package.NewClient(context.Background(), cfg)
+	if clientIface == nil {
+		t.Fatalf("failed to create client, err = %v", err)
+	}
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	gw := newFakeGw(t, clientIface.(*client))
+	gw.published = []string{
+		"/other/file",
+		"/some/dir/a",
+		"/some/dir/b",
+		"/some/dir/sub/c",
+		"/some/dir/sub/d",
+		"/some/dir/e & f",
+	}
+
+	// It should return everything under the prefix, across multiple pages.
+	got, err := clientIface.ListPublished(ctx, "/some/dir/")
+	if err != nil {
+		t.Fatalf("failed to list, err = %v", err)
+	}
+
+	expected := []string{
+		"/some/dir/a",
+		"/some/dir/b",
+		"/some/dir/sub/c",
+		"/some/dir/sub/d",
+		"/some/dir/e & f",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got unexpected items %v", got)
+	}
+
+	// Errors should be propagated.
+	gw.nextHTTPResponse = jsonResponse(500, `{"detail": "oops"}`)
+
+	_, err = clientIface.ListPublished(ctx, "/some/dir/")
+	if err == nil || err.Error() != "GET https://exodus-gw.example.com/env/published-items?prefix=%2Fsome%2Fdir%2F: 500 Internal Server Error: oops" {
+		t.Errorf("did not get expected error, got %v", err)
+	}
+}
@@ -130,7 +130,7 @@ func TestClientPublishErrors(t *testing.T) {
 			)),
 		}
 
-		err := publish.AddItems(ctx, []ItemInput{{"/some/uri", "abc123"}})
+		err := publish.AddItems(ctx, []ItemInput{{"/some/uri", "abc123", ""}})
 
 		if err == nil {
 			t.Error("Unexpectedly failed to return an error")
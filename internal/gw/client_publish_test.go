@@ -38,8 +38,9 @@ func TestClientPublish(t *testing.T) {
 
 	// It should be able to add some items
 	addItems := []ItemInput{
-		{"/some/path", "1234"},
-		{"/other/path", "223344"},
+		{"/some/path", "1234", ""},
+		{"/other/path", "223344", ""},
+		{"/link/path", "", "/some/path"},
 	}
 	err = publish.AddItems(ctx, addItems)
 	if err != nil {
@@ -99,8 +100,8 @@ func TestClientGetPublish(t *testing.T) {
 
 	// It should be able to add some items
 	addItems := []ItemInput{
-		{"/some/path", "1234"},
-		{"/other/path", "223344"},
+		{"/some/path", "1234", ""},
+		{"/other/path", "223344", ""},
 	}
 	err = p.AddItems(ctx, addItems)
 	if err != nil {
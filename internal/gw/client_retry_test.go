@@ -0,0 +1,237 @@
+package gw
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+// A RoundTripper returning a fixed sequence of responses.
+type sequenceTransport struct {
+	responses []*http.Response
+	errors    []error
+	requests  int
+}
+
+func (s *sequenceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	i := s.requests
+	s.requests++
+
+	if i < len(s.errors) && s.errors[i] != nil {
+		return nil, s.errors[i]
+	}
+	return s.responses[i], nil
+}
+
+func jsonResponse(code int, body string) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func newRetryTestClient(t *testing.T) (*client, context.Context) {
+	clientIface, err := Package.NewClient(context.Background(), testConfig(t))
+	if err != nil {
+		t.Fatalf("failed to create client, err = %v", err)
+	}
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	return clientIface.(*client), ctx
+}
+
+func TestClientRetryTransientResponse(t *testing.T) {
+	c, ctx := newRetryTestClient(t)
+
+	transport := &sequenceTransport{
+		responses: []*http.Response{
+			jsonResponse(503, ""),
+			nil,
+			jsonResponse(200, `{"a": "b"}`),
+		},
+		errors: []error{
+			nil,
+			&url.Error{Op: "Get", URL: "/whoami", Err: syscall.ECONNRESET},
+		},
+	}
+	c.httpClient.Transport = transport
+
+	out, err := c.WhoAmI(ctx)
+
+	// It should have succeeded on the third attempt.
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if out["a"] != "b" {
+		t.Errorf("unexpected response: %v", out)
+	}
+	if transport.requests != 3 {
+		t.Errorf("expected 3 requests, got %d", transport.requests)
+	}
+}
+
+func TestClientRetryGivesUp(t *testing.T) {
+	c, ctx := newRetryTestClient(t)
+
+	transport := &sequenceTransport{
+		responses: []*http.Response{
+			jsonResponse(502, ""),
+			jsonResponse(504, ""),
+			jsonResponse(429, ""),
+			jsonResponse(200, "{}"),
+		},
+	}
+	c.httpClient.Transport = transport
+
+	_, err := c.WhoAmI(ctx)
+
+	// It should have given up after the configured number of attempts.
+	if !strings.Contains(fmt.Sprint(err), "giving up after 3 attempts") {
+		t.Errorf("did not get expected error, got: %v", err)
+	}
+	if !strings.Contains(fmt.Sprint(err), "429 Too Many Requests") {
+		t.Errorf("error did not include last failure, got: %v", err)
+	}
+	if transport.requests != 3 {
+		t.Errorf("expected 3 requests, got %d", transport.requests)
+	}
+}
+
+func TestClientNoRetryNonIdempotent(t *testing.T) {
+	c, ctx := newRetryTestClient(t)
+
+	transport := &sequenceTransport{
+		responses: []*http.Response{
+			jsonResponse(503, ""),
+			jsonResponse(200, `{"id": "abc"}`),
+		},
+	}
+	c.httpClient.Transport = transport
+
+	_, err := c.NewPublish(ctx)
+
+	// Creating a publish is not idempotent, so it should not be retried.
+	if err == nil {
+		t.Error("unexpectedly succeeded")
+	}
+	if transport.requests != 1 {
+		t.Errorf("expected 1 request, got %d", transport.requests)
+	}
+}
+
+func TestClientNoRetryPermanentError(t *testing.T) {
+	c, ctx := newRetryTestClient(t)
+
+	transport := &sequenceTransport{
+		responses: []*http.Response{
+			jsonResponse(404, ""),
+			jsonResponse(200, "{}"),
+		},
+	}
+	c.httpClient.Transport = transport
+
+	_, err := c.WhoAmI(ctx)
+
+	if !strings.Contains(fmt.Sprint(err), "404 Not Found") {
+		t.Errorf("did not get expected error, got: %v", err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("expected 1 request, got %d", transport.requests)
+	}
+}
+
+func TestClientRetryHeadBlob(t *testing.T) {
+	client, s3 := newClientWithFakeS3(t)
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	unavailable := awserr.NewRequestFailure(
+		awserr.New("ServiceUnavailable", "try again", nil), 503, "req-id")
+
+	// The first HEAD fails, the next one finds the blob.
+	s3.blobs["abc123"] = []error{unavailable, nil}
+
+	have, err := client.haveBlob(ctx, walk.SyncItem{Key: "abc123"})
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !have {
+		t.Error("blob unexpectedly not present")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"absent", "", 0, 0},
+		{"seconds", "120", 2 * time.Minute, 2 * time.Minute},
+		{"date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+			59 * time.Minute, time.Hour},
+		{"garbage", "soon", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := jsonResponse(503, "")
+			if tt.value != "" {
+				resp.Header.Set("Retry-After", tt.value)
+			}
+
+			got := retryAfter(resp)
+			if got < tt.min || got > tt.max {
+				t.Errorf("unexpected delay %v", got)
+			}
+		})
+	}
+}
+
+func TestTransientReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"plain error", fmt.Errorf("oops"), ""},
+		{"connection reset", &url.Error{Op: "Get", Err: syscall.ECONNRESET}, "connection reset"},
+		{"unexpected EOF", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), "unexpected EOF"},
+		{"aws throttled",
+			awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), 429, "id"),
+			"Too Many Requests"},
+		{"aws not found",
+			awserr.NewRequestFailure(awserr.New("NotFound", "not found", nil), 404, "id"),
+			""},
+		{"aws wrapping reset",
+			fmt.Errorf("upload: %w", awserr.New("RequestError", "send failed",
+				&url.Error{Op: "Put", Err: syscall.ECONNRESET})),
+			"connection reset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transientReason(tt.err); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package gw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hang":
+			<-release
+		case "/slow":
+			// Takes longer than the timeout overall, but is never
+			// inactive for that long.
+			for i := 0; i < 6; i++ {
+				w.Write([]byte("x"))
+				w.(http.Flusher).Flush()
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	transport := &http.Transport{}
+	setTimeout(transport, 200*time.Millisecond)
+	client := http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL + "/slow")
+	if err != nil {
+		t.Fatalf("slow request failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "xxxxxx" {
+		t.Fatalf("slow request failed: %q, %v", body, err)
+	}
+
+	_, err = client.Get(server.URL + "/hang")
+	if err == nil {
+		t.Fatal("hung request did not fail")
+	}
+	if !IsTimeout(err) {
+		t.Errorf("not a timeout: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package gw
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/walk"
+)
+
+func TestClientUploadManyItems(t *testing.T) {
+	client, s3 := newClientWithFakeS3(t)
+
+	chdirInTest(t, "../../test/data/srctrees/just-files")
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	// Half of these keys already exist, and every key is used by
+	// several items.
+	items := []walk.SyncItem{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i%20)
+		if i < 20 && i%2 == 0 {
+			s3.blobs[key] = []error{}
+		}
+		items = append(items, walk.SyncItem{SrcPath: "hello-copy-one", Key: key})
+	}
+
+	var got []string
+
+	err := client.EnsureUploaded(ctx, items, func(item walk.SyncItem) error {
+		got = append(got, "uploaded "+item.Key)
+		return nil
+	}, func(item walk.SyncItem) error {
+		got = append(got, "present "+item.Key)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("got unexpected error %v", err)
+	}
+
+	// Callbacks should have been invoked in the same order as items,
+	// with only the first item of each missing key counting as uploaded.
+	var want []string
+	for i, item := range items {
+		if i < 20 && i%2 == 1 {
+			want = append(want, "uploaded "+item.Key)
+		} else {
+			want = append(want, "present "+item.Key)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected callbacks: %v", got)
+	}
+
+	// Each missing blob should have been uploaded exactly once.
+	for key, count := range s3.puts {
+		if count != 1 {
+			t.Errorf("blob %s uploaded %d times", key, count)
+		}
+	}
+	if len(s3.puts) != 10 {
+		t.Errorf("expected 10 uploads, got %d", len(s3.puts))
+	}
+
+	// A later call should not upload or even check any of the same blobs.
+	s3.reset()
+	presentCount := 0
+
+	err = client.EnsureUploaded(ctx, items, func(item walk.SyncItem) error {
+		t.Error("unexpectedly uploaded", item)
+		return nil
+	}, func(item walk.SyncItem) error {
+		presentCount++
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("got unexpected error %v", err)
+	}
+	if presentCount != len(items) {
+		t.Errorf("expected all items present, got %d", presentCount)
+	}
+}
+
+func TestClientUploadFirstError(t *testing.T) {
+	client, s3 := newClientWithFakeS3(t)
+
+	chdirInTest(t, "../../test/data/srctrees/just-files")
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	items := []walk.SyncItem{}
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key%d", i)
+		items = append(items, walk.SyncItem{SrcPath: "hello-copy-one", Key: key})
+	}
+
+	// Several items will fail, but we should always be told about
+	// the earliest of them.
+	s3.blobs["key10"] = []error{fmt.Errorf("error 10")}
+	s3.blobs["key20"] = []error{fmt.Errorf("error 20")}
+	s3.blobs["key30"] = []error{fmt.Errorf("error 30")}
+
+	callbacks := 0
+	count := func(walk.SyncItem) error {
+		callbacks++
+		return nil
+	}
+
+	err := client.EnsureUploaded(ctx, items, count, count)
+
+	if !strings.Contains(fmt.Sprint(err), "checking for presence of key10: error 10") {
+		t.Errorf("did not get expected error, got err = %v", err)
+	}
+
+	// Callbacks should have been invoked for every item prior to the failure.
+	if callbacks != 10 {
+		t.Errorf("expected 10 callbacks, got %d", callbacks)
+	}
+}
@@ -1,13 +1,17 @@
 package gw
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/release-engineering/exodus-rsync/internal/args"
 	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/progress"
 	"github.com/release-engineering/exodus-rsync/internal/walk"
 )
 
@@ -104,3 +108,40 @@ func TestClientPresentCallbackError(t *testing.T) {
 		t.Errorf("did not get expected error, got: %v", err)
 	}
 }
+
+func TestClientUploadProgress(t *testing.T) {
+	client, _ := newClientWithFakeS3(t)
+
+	chdirInTest(t, "../../test/data/srctrees/just-files")
+
+	buf := &bytes.Buffer{}
+	reporter := progress.New(buf, progress.PerFile, false)
+
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+	ctx = progress.NewContext(ctx, reporter)
+
+	info, err := os.Stat("hello-copy-one")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []walk.SyncItem{
+		{SrcPath: "hello-copy-one", Key: "abc123", Info: info},
+	}
+
+	err = client.EnsureUploaded(ctx, items, func(item walk.SyncItem) error {
+		return nil
+	}, func(item walk.SyncItem) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got unexpected error %v", err)
+	}
+
+	// It should have reported the upload as complete.
+	if !strings.Contains(buf.String(), "uploading hello-copy-one\n") ||
+		!strings.Contains(buf.String(), "(xfr#1, ir-chk=0/0)") {
+		t.Errorf("unexpected progress output: %q", buf.String())
+	}
+}
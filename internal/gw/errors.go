@@ -0,0 +1,143 @@
+package gw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Max number of bytes read from the body of an unsuccessful response.
+const maxErrorBody = 64 * 1024
+
+// HTTPError is returned when exodus-gw responds to a request with an
+// unsuccessful HTTP status.
+type HTTPError struct {
+	// Method and URL of the failed request.
+	Method string
+	URL    string
+
+	// Status of the response, e.g. StatusCode 404 and Status "404 Not Found".
+	StatusCode int
+	Status     string
+
+	// The "detail" from the body of the response, if exodus-gw returned an
+	// error in its usual JSON format. Details which are not strings (such as
+	// validation errors) are provided as JSON.
+	Detail string
+
+	// The raw body of the response, possibly truncated.
+	Body string
+}
+
+func (e *HTTPError) Error() string {
+	out := fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+	if e.Detail != "" {
+		return out + ": " + e.Detail
+	}
+	if e.Body != "" {
+		return out + ", " + e.Body
+	}
+	return out
+}
+
+func newHTTPError(req *http.Request, resp *http.Response) *HTTPError {
+	out := &HTTPError{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	out.Body = strings.TrimSpace(string(body))
+
+	parsed := struct {
+		Detail json.RawMessage `json:"detail"`
+	}{}
+	if json.Unmarshal(body, &parsed) == nil && len(parsed.Detail) > 0 {
+		if json.Unmarshal(parsed.Detail, &out.Detail) != nil {
+			out.Detail = string(parsed.Detail)
+		}
+	}
+
+	return out
+}
+
+// causes returns err followed by every error it wraps, including errors
+// wrapped by the AWS SDK which can't be found via errors.Unwrap.
+func causes(err error) []error {
+	var out []error
+
+	for err != nil {
+		out = append(out, err)
+
+		if awsErr, isAwsErr := err.(awserr.Error); isAwsErr {
+			err = awsErr.OrigErr()
+		} else {
+			err = errors.Unwrap(err)
+		}
+	}
+
+	return out
+}
+
+// IsTimeout returns true if err was caused by a timeout while communicating
+// with exodus-gw.
+func IsTimeout(err error) bool {
+	for _, cause := range causes(err) {
+		if netErr, ok := cause.(net.Error); ok && netErr.Timeout() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConnectionError returns true if err was caused by a failure to connect
+// to or communicate with exodus-gw at the network level.
+func IsConnectionError(err error) bool {
+	if IsTimeout(err) {
+		return true
+	}
+	for _, cause := range causes(err) {
+		switch cause.(type) {
+		case *net.OpError, *net.DNSError:
+			return true
+		}
+		if errors.Is(cause, syscall.ECONNRESET) || errors.Is(cause, syscall.ECONNREFUSED) ||
+			errors.Is(cause, syscall.EPIPE) || errors.Is(cause, io.ErrUnexpectedEOF) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsProtocolError returns true if err was caused by exodus-gw refusing a
+// request or returning a response which could not be understood.
+func IsProtocolError(err error) bool {
+	for _, cause := range causes(err) {
+		switch cause.(type) {
+		case *HTTPError, awserr.RequestFailure, *json.SyntaxError, *json.UnmarshalTypeError:
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnsupported returns true if err was caused by exodus-gw not providing
+// the API used by a request.
+func IsUnsupported(err error) bool {
+	for _, cause := range causes(err) {
+		if httpErr, ok := cause.(*HTTPError); ok {
+			return httpErr.StatusCode == http.StatusNotFound ||
+				httpErr.StatusCode == http.StatusMethodNotAllowed
+		}
+	}
+	return false
+}
@@ -43,6 +43,10 @@ type Client interface {
 	//
 	// In either case, returning from the callback with an error will cause EnsureUploaded
 	// to stop and return the same error.
+	//
+	// Blobs are checked and uploaded concurrently, but callbacks are always invoked
+	// from the calling goroutine in the same order as items. Each blob is uploaded
+	// at most once per client, even if several items share the same key.
 	EnsureUploaded(ctx context.Context, items []walk.SyncItem,
 		onUploaded func(walk.SyncItem) error,
 		onPresent func(walk.SyncItem) error,
@@ -58,6 +62,22 @@ type Client interface {
 	// time any write operation is attempted on the publish.
 	GetPublish(string) Publish
 
+	// ListPublished returns the URIs of every item currently published beneath
+	// the given URI prefix, in no particular order.
+	//
+	// This requires an exodus-gw server providing the published-items API,
+	// which is not part of upstream exodus-gw; otherwise, the error satisfies
+	// IsUnsupported.
+	ListPublished(ctx context.Context, prefix string) ([]string, error)
+
+	// ArePublished returns, for each of uris, whether the URI is already
+	// published, as determined by a HEAD request to the CDN at 'cdnurl'.
+	//
+	// URIs are checked concurrently, with requests retried and limited in the
+	// same way as requests to exodus-gw. If 'cdnurl' is not configured, any
+	// non-empty uris fail with ErrNoCdnURL.
+	ArePublished(ctx context.Context, uris []string) ([]bool, error)
+
 	// WhoAmI returns raw authentication & authorization info for this exodus-gw client
 	// in the format provided by the "/whoami" endpoint.
 	//
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -20,6 +22,9 @@ type fakeGw struct {
 	// Existing publish objects
 	publishes publishMap
 
+	// URIs of items which are considered already published
+	published []string
+
 	// If non-nil, forces next HTTP request to return this error.
 	nextHTTPError error
 
@@ -82,6 +87,10 @@ func (f *fakeGw) RoundTrip(r *http.Request) (*http.Response, error) {
 	}
 	route = route[1:]
 
+	if len(route) == 1 && route[0] == "published-items" && r.Method == "GET" {
+		return f.listPublished(r), nil
+	}
+
 	if len(route) == 1 && route[0] == "publish" && r.Method == "POST" {
 		return f.createPublish(), nil
 	}
@@ -147,6 +156,39 @@ func (f *fakeGw) createPublish() *http.Response {
 	return out
 }
 
+// Returns published items matching prefix, two items per page.
+func (f *fakeGw) listPublished(r *http.Request) *http.Response {
+	prefix := r.URL.Query().Get("prefix")
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	var matched []map[string]string
+	for _, uri := range f.published {
+		if strings.HasPrefix(uri, prefix) {
+			matched = append(matched, map[string]string{"web_uri": uri})
+		}
+	}
+
+	page := map[string]interface{}{"links": map[string]string{}}
+	if offset+2 < len(matched) {
+		page["links"] = map[string]string{
+			"next": fmt.Sprintf("/env/published-items?prefix=%s&offset=%d",
+				url.QueryEscape(prefix), offset+2),
+		}
+		matched = matched[offset : offset+2]
+	} else {
+		matched = matched[offset:]
+	}
+	page["items"] = matched
+
+	content, _ := json.Marshal(page)
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(string(content))),
+	}
+}
+
 func (f *fakeGw) addPublishItems(r *http.Request, id string) *http.Response {
 	out := &http.Response{}
 
@@ -170,7 +212,7 @@ func (f *fakeGw) addPublishItems(r *http.Request, id string) *http.Response {
 	}
 
 	for _, item := range requestItems {
-		publish.items = append(publish.items, ItemInput{item["web_uri"], item["object_key"]})
+		publish.items = append(publish.items, ItemInput{item["web_uri"], item["object_key"], item["link_to"]})
 	}
 
 	out.Status = "200 OK"
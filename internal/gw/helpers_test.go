@@ -34,8 +34,16 @@ func testConfig(t *testing.T) conf.Config {
 	cfg.EXPECT().GwKey().AnyTimes().Return("../../test/data/service-key.pem")
 	cfg.EXPECT().GwURL().AnyTimes().Return("https://exodus-gw.example.com")
 	cfg.EXPECT().GwPollInterval().AnyTimes().Return(1)
+	cfg.EXPECT().GwMaxAttempts().AnyTimes().Return(3)
+	cfg.EXPECT().GwRetryBackoff().AnyTimes().Return(1)
+	cfg.EXPECT().GwMaxRetryBackoff().AnyTimes().Return(5)
 	cfg.EXPECT().GwEnv().AnyTimes().Return("env")
 	cfg.EXPECT().GwBatchSize().AnyTimes().Return(3)
+	cfg.EXPECT().GwUploadConcurrency().AnyTimes().Return(4)
+	cfg.EXPECT().GwMaxRequestRate().AnyTimes().Return(0)
+	cfg.EXPECT().CdnURL().AnyTimes().Return("https://cdn.example.com/content/")
+	cfg.EXPECT().BwLimit().AnyTimes().Return(int64(0))
+	cfg.EXPECT().Timeout().AnyTimes().Return(0)
 	cfg.EXPECT().LogLevel().AnyTimes().Return("info")
 	cfg.EXPECT().Verbosity().AnyTimes().Return(3)
 
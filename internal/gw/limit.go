@@ -0,0 +1,50 @@
+package gw
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/release-engineering/exodus-rsync/internal/progress"
+)
+
+// limitRequest is a request.Option which delays an S3 request as needed
+// to keep within the max request rate.
+func (c *client) limitRequest(r *request.Request) {
+	// Sign handlers run before each attempt to send the request.
+	r.Handlers.Sign.PushBack(func(r *request.Request) {
+		if err := c.requestLimiter.Wait(r.Context(), 1); err != nil {
+			r.Error = err
+		}
+	})
+}
+
+type limitedReader struct {
+	progress.ReadSeekerAt
+	c   *client
+	ctx context.Context
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeekerAt.Read(p)
+	if waitErr := r.c.uploadLimiter.Wait(r.ctx, int64(n)); err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+func (r *limitedReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ReadSeekerAt.ReadAt(p, off)
+	if waitErr := r.c.uploadLimiter.Wait(r.ctx, int64(n)); err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+// limitReader returns a reader of rd which is delayed as needed to keep
+// within the max upload bandwidth, shared by all uploads.
+func (c *client) limitReader(ctx context.Context, rd progress.ReadSeekerAt) progress.ReadSeekerAt {
+	if c.uploadLimiter == nil {
+		return rd
+	}
+	return &limitedReader{rd, c, ctx}
+}
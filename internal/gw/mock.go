@@ -89,6 +89,21 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// ArePublished mocks base method.
+func (m *MockClient) ArePublished(ctx context.Context, uris []string) ([]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArePublished", ctx, uris)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArePublished indicates an expected call of ArePublished.
+func (mr *MockClientMockRecorder) ArePublished(ctx, uris interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArePublished", reflect.TypeOf((*MockClient)(nil).ArePublished), ctx, uris)
+}
+
 // EnsureUploaded mocks base method.
 func (m *MockClient) EnsureUploaded(ctx context.Context, items []walk.SyncItem, onUploaded, onPresent func(walk.SyncItem) error) error {
 	m.ctrl.T.Helper()
@@ -117,6 +132,21 @@ func (mr *MockClientMockRecorder) GetPublish(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPublish", reflect.TypeOf((*MockClient)(nil).GetPublish), arg0)
 }
 
+// ListPublished mocks base method.
+func (m *MockClient) ListPublished(ctx context.Context, prefix string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPublished", ctx, prefix)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPublished indicates an expected call of ListPublished.
+func (mr *MockClientMockRecorder) ListPublished(ctx, prefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPublished", reflect.TypeOf((*MockClient)(nil).ListPublished), ctx, prefix)
+}
+
 // NewPublish mocks base method.
 func (m *MockClient) NewPublish(arg0 context.Context) (Publish, error) {
 	m.ctrl.T.Helper()
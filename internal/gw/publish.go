@@ -3,6 +3,7 @@ package gw
 import (
 	"context"
 	"fmt"
+	"net/url"
 
 	"github.com/release-engineering/exodus-rsync/internal/log"
 )
@@ -17,10 +18,49 @@ type publish struct {
 	}
 }
 
+// AbsentKey may be used as the ObjectKey of an item to remove any content
+// previously published at the item's URI.
+const AbsentKey = "absent"
+
 // ItemInput is a single item accepted for publish by the AddItems method.
+//
+// Exactly one of ObjectKey or LinkTo should be set. If LinkTo is set, the
+// item is published as a link to the item at that URI.
 type ItemInput struct {
 	WebURI    string `json:"web_uri"`
-	ObjectKey string `json:"object_key"`
+	ObjectKey string `json:"object_key,omitempty"`
+	LinkTo    string `json:"link_to,omitempty"`
+}
+
+// ListPublished returns the URIs of all items currently published beneath
+// the given prefix, using the published-items API. This is an extension not
+// provided by upstream exodus-gw.
+func (c *client) ListPublished(ctx context.Context, prefix string) ([]string, error) {
+	next := "/" + c.cfg.GwEnv() + "/published-items?" + url.Values{"prefix": {prefix}}.Encode()
+
+	var out []string
+
+	// Results are paginated, with each page linking to the next.
+	for next != "" {
+		page := struct {
+			Items []struct {
+				WebURI string `json:"web_uri"`
+			}
+			Links map[string]string
+		}{}
+
+		if err := c.doJSONRequest(ctx, "GET", next, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Items {
+			out = append(out, item.WebURI)
+		}
+
+		next = page.Links["next"]
+	}
+
+	return out, nil
 }
 
 // NewPublish creates and returns a new publish object within exodus-gw.
@@ -0,0 +1,162 @@
+package gw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+)
+
+// transientError wraps an error which might not occur again if the failed
+// operation is retried.
+type transientError struct {
+	err        error
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// Returns true for HTTP status codes which are worth retrying.
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Returns the delay requested by a Retry-After header, or 0 if there's no
+// valid header.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when)
+	}
+
+	return 0
+}
+
+// Returns a reason why err might be resolved by retrying, or an empty
+// string if err is not considered transient.
+func transientReason(err error) string {
+	for _, cause := range causes(err) {
+		switch {
+		case errors.Is(cause, syscall.ECONNRESET):
+			return "connection reset"
+		case errors.Is(cause, syscall.ECONNREFUSED):
+			return "connection refused"
+		case errors.Is(cause, syscall.EPIPE):
+			return "broken pipe"
+		case errors.Is(cause, io.ErrUnexpectedEOF):
+			return "unexpected EOF"
+		}
+
+		if netErr, ok := cause.(net.Error); ok && netErr.Timeout() {
+			return "timeout"
+		}
+
+		if reqErr, ok := cause.(awserr.RequestFailure); ok && isTransientStatus(reqErr.StatusCode()) {
+			return http.StatusText(reqErr.StatusCode())
+		}
+	}
+
+	return ""
+}
+
+// Wraps err as a transientError if it's worth retrying, using resp (if any)
+// to look up the delay requested by the server.
+func asTransient(err error, resp *http.Response) error {
+	if err == nil {
+		return nil
+	}
+
+	reason := transientReason(err)
+	if reason == "" {
+		return err
+	}
+
+	return &transientError{err: err, reason: reason, retryAfter: retryAfter(resp)}
+}
+
+// retry invokes fn until it succeeds, fails with an error not wrapped as
+// a transientError, or the max number of attempts is reached.
+//
+// fn must be safe to invoke multiple times, i.e. it should only be used
+// for idempotent operations.
+func (c *client) retry(ctx context.Context, operation string, fn func() error) error {
+	logger := log.FromContext(ctx)
+
+	maxAttempts := c.cfg.GwMaxAttempts()
+	backoff := time.Millisecond * time.Duration(c.cfg.GwRetryBackoff())
+	maxBackoff := time.Millisecond * time.Duration(c.cfg.GwMaxRetryBackoff())
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+
+		var transient *transientError
+		if err == nil || !errors.As(err, &transient) {
+			return err
+		}
+
+		if attempt >= maxAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		// Wait somewhere between 50% and 100% of the current backoff, unless
+		// the server told us to wait longer.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if transient.retryAfter > delay {
+			delay = transient.retryAfter
+		}
+
+		logger.F(
+			"operation", operation, "attempt", attempt, "reason", transient.reason,
+			"delay", delay, "error", transient.err,
+		).Warn("Retrying after transient error")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
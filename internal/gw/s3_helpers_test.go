@@ -17,10 +17,13 @@ type fakeS3 struct {
 	mu sync.Mutex
 
 	blobs blobMap
+
+	// Number of PUT requests received for each key.
+	puts map[string]int
 }
 
 func newFakeS3(t *testing.T, client *client) *fakeS3 {
-	out := fakeS3{t: t, blobs: make(blobMap)}
+	out := fakeS3{t: t, blobs: make(blobMap), puts: make(map[string]int)}
 
 	out.install(client)
 
@@ -29,6 +32,7 @@ func newFakeS3(t *testing.T, client *client) *fakeS3 {
 
 func (f *fakeS3) reset() {
 	f.blobs = make(blobMap)
+	f.puts = make(map[string]int)
 }
 
 func (f *fakeS3) install(client *client) {
@@ -82,6 +86,8 @@ func (f *fakeS3) putObject(r *request.Request, input *s3.PutObjectInput) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	f.puts[*input.Key]++
+
 	errors, haveBlob := f.blobs[*input.Key]
 	if !haveBlob {
 		// Mark that we have this blob, and don't return any errors for it.
@@ -0,0 +1,47 @@
+package gw
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// timeoutConn is a connection which fails if no data can be read or written
+// for the given timeout, as with rsync --timeout.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// setTimeout configures transport so that any connection, or any request,
+// fails if inactive for the given timeout.
+func setTimeout(transport *http.Transport, timeout time.Duration) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &timeoutConn{conn, timeout}, nil
+	}
+
+	// Idle connections are otherwise closed by the read timeout, which could
+	// happen just as a new request is sent.
+	transport.IdleConnTimeout = timeout / 2
+}
@@ -0,0 +1,364 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Mode selects the kind of progress output.
+type Mode int
+
+const (
+	// PerFile outputs the progress of each file, as rsync --progress.
+	PerFile Mode = iota + 1
+
+	// Total outputs the progress of the whole publish, as rsync
+	// --info=progress2.
+	Total
+)
+
+// Operation is something done to the content of a file.
+type Operation string
+
+// Operations for which progress is reported.
+const (
+	Hashing   Operation = "hashing"
+	Uploading Operation = "uploading"
+)
+
+// How often progress is redrawn on a terminal, or printed elsewhere.
+const (
+	ttyInterval    = 250 * time.Millisecond
+	nonTTYInterval = 10 * time.Second
+)
+
+type contextKey struct{}
+
+// NewContext returns a context holding the given reporter, which can then
+// be accessed via FromContext.
+func NewContext(ctx context.Context, r *Reporter) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the reporter within a context previously created via
+// NewContext, or nil if unset. A nil reporter reports nothing.
+func FromContext(ctx context.Context) *Reporter {
+	r, _ := ctx.Value(contextKey{}).(*Reporter)
+	return r
+}
+
+// Reporter writes progress of a publish in the formats used by rsync.
+//
+// On a terminal, progress is redrawn in place. Elsewhere, progress lines are
+// printed periodically.
+//
+// Reporter also implements io.Writer, so that other output can be written
+// without being mixed up with progress drawn in place.
+//
+// Reporter is safe for concurrent use. All methods may be called on a nil
+// *Reporter, in which case they do nothing.
+type Reporter struct {
+	w        io.Writer
+	mode     Mode
+	tty      bool
+	interval time.Duration
+
+	// Used in place of time.Now, for testing.
+	now func() time.Time
+
+	mu    sync.Mutex
+	start time.Time
+
+	// Time at which progress was last output.
+	lastOutput time.Time
+
+	// True if a progress line has been drawn in place and not yet ended.
+	drawn bool
+
+	// Bytes processed and total bytes to be processed, for all operations
+	// on all files so far.
+	done  int64
+	total int64
+
+	// Counts of files found (i.e. started hashing), files finished hashing
+	// and files finished uploading.
+	found    int
+	hashed   int
+	uploaded int
+
+	// The file which most recently made progress.
+	current *File
+}
+
+// New returns a reporter writing to w in the given mode. tty should be true
+// if w is a terminal.
+func New(w io.Writer, mode Mode, tty bool) *Reporter {
+	out := &Reporter{w: w, mode: mode, tty: tty, now: time.Now}
+	out.start = out.now()
+
+	out.interval = nonTTYInterval
+	if tty {
+		out.interval = ttyInterval
+	}
+
+	return out
+}
+
+// File tracks an operation on a single file.
+type File struct {
+	r     *Reporter
+	op    Operation
+	name  string
+	size  int64
+	done  int64
+	start time.Time
+}
+
+// Start begins tracking op on the file with the given name and size.
+func (r *Reporter) Start(op Operation, name string, size int64) *File {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if op == Hashing {
+		r.found++
+	}
+	r.total += size
+
+	return &File{r: r, op: op, name: name, size: size, start: r.now()}
+}
+
+// Add records that n more bytes of the file have been processed.
+func (f *File) Add(n int64) {
+	if f == nil {
+		return
+	}
+
+	r := f.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Content read more than once (e.g. due to retries) isn't counted twice.
+	if f.done+n > f.size {
+		n = f.size - f.done
+	}
+	f.done += n
+	r.done += n
+	r.current = f
+
+	if now := r.now(); now.Sub(r.lastOutput) >= r.interval {
+		r.lastOutput = now
+		r.output()
+	}
+}
+
+// Restart records that the file will be processed again from the beginning.
+func (f *File) Restart() {
+	if f == nil {
+		return
+	}
+
+	r := f.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done -= f.done
+	f.done = 0
+	f.start = r.now()
+}
+
+// Finish records that the operation on the file has completed.
+func (f *File) Finish() {
+	if f == nil {
+		return
+	}
+
+	r := f.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Anything not read (e.g. if the file was truncated) is done anyway.
+	r.done += f.size - f.done
+	f.done = f.size
+
+	if f.op == Hashing {
+		r.hashed++
+	} else {
+		r.uploaded++
+	}
+
+	if r.current == f {
+		r.current = nil
+	}
+
+	if r.mode == PerFile {
+		r.endLine()
+		fmt.Fprintf(r.w, "%s %s\n%s (%s)\n", f.op, f.name,
+			progressLine(f.size, f.size, r.now().Sub(f.start), true), r.counts("ir-chk"))
+		r.lastOutput = r.now()
+	}
+}
+
+// ReadSeekerAt is implemented by readers of files, such as *os.File.
+type ReadSeekerAt interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+type fileReader struct {
+	ReadSeekerAt
+	f *File
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeekerAt.Read(p)
+	r.f.Add(int64(n))
+	return n, err
+}
+
+func (r *fileReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ReadSeekerAt.ReadAt(p, off)
+	r.f.Add(int64(n))
+	return n, err
+}
+
+// Reader returns a reader of rd which records progress of f as content is
+// read. Seeking isn't taken into account, but any content read more than
+// once is only counted once.
+func (f *File) Reader(rd ReadSeekerAt) ReadSeekerAt {
+	if f == nil {
+		return rd
+	}
+	return &fileReader{rd, f}
+}
+
+// Close ends progress output, printing the final totals if requested.
+func (r *Reporter) Close() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.endLine()
+	if r.mode == Total {
+		fmt.Fprintf(r.w, "%s (%s)\n", progressLine(r.done, r.total, r.now().Sub(r.start), true), r.counts("to-chk"))
+	}
+}
+
+// Write writes p to the underlying writer, first ending any progress line
+// drawn in place.
+func (r *Reporter) Write(p []byte) (int, error) {
+	if r == nil {
+		return len(p), nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.endLine()
+	return r.w.Write(p)
+}
+
+// endLine ends any progress line drawn in place.
+func (r *Reporter) endLine() {
+	if r.drawn {
+		fmt.Fprint(r.w, "\r\033[K")
+		r.drawn = false
+	}
+}
+
+// counts returns the counts of files as in the final part of rsync's
+// progress lines, e.g. "xfr#3, to-chk=2/10". As in rsync, the label is
+// "ir-chk" while more files may yet be found, and "to-chk" afterward.
+func (r *Reporter) counts(label string) string {
+	return fmt.Sprintf("xfr#%d, %s=%d/%d", r.uploaded, label, r.found-r.hashed, r.found)
+}
+
+// output writes the current progress.
+func (r *Reporter) output() {
+	var line string
+
+	switch r.mode {
+	case PerFile:
+		f := r.current
+		if f == nil {
+			return
+		}
+		line = fmt.Sprintf("%s  %s %s", progressLine(f.done, f.size, r.now().Sub(f.start), false), f.op, f.name)
+
+	case Total:
+		line = fmt.Sprintf("%s (%s)", progressLine(r.done, r.total, r.now().Sub(r.start), false), r.counts("ir-chk"))
+	}
+
+	if r.tty {
+		fmt.Fprintf(r.w, "\r\033[K%s", line)
+		r.drawn = true
+	} else {
+		fmt.Fprintln(r.w, line)
+	}
+}
+
+// formatCount formats n with thousands separators, as rsync does.
+func formatCount(n int64) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatTime formats a number of seconds as rsync does, e.g. "0:01:23".
+func formatTime(seconds int64) string {
+	if seconds < 0 || seconds > 9999*3600 {
+		return "  ??:??:??"
+	}
+	return fmt.Sprintf("%4d:%02d:%02d", seconds/3600, (seconds/60)%60, seconds%60)
+}
+
+// progressLine returns a line of progress as rsync would output it, e.g.
+// "      1,238,099  12%   11.80MB/s    0:00:07".
+//
+// For a finished operation, the time shown is the time taken; otherwise,
+// it's the estimated time remaining.
+func progressLine(done int64, size int64, elapsed time.Duration, finished bool) string {
+	pct := int64(100)
+	if size > 0 {
+		pct = done * 100 / size
+	}
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(done) / elapsed.Seconds()
+	}
+
+	var seconds int64
+	switch {
+	case finished:
+		seconds = int64(elapsed.Seconds())
+	case rate > 0:
+		seconds = int64(float64(size-done) / rate)
+	default:
+		seconds = -1
+	}
+
+	units := "kB/s"
+	rate /= 1024
+	if rate > 1024 {
+		units = "MB/s"
+		rate /= 1024
+		if rate > 1024 {
+			units = "GB/s"
+			rate /= 1024
+		}
+	}
+
+	return fmt.Sprintf("%15s %3d%% %7.2f%s %s", formatCount(done), pct, rate, units, formatTime(seconds))
+}
@@ -0,0 +1,160 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a reporter writing to a buffer, with a clock advanced only by
+// the returned function.
+func testReporter(mode Mode, tty bool) (*Reporter, *bytes.Buffer, func(time.Duration)) {
+	buf := &bytes.Buffer{}
+	now := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	r := New(buf, mode, tty)
+	r.now = func() time.Time { return now }
+	r.start = now
+
+	return r, buf, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestProgressLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		done     int64
+		size     int64
+		elapsed  time.Duration
+		finished bool
+		expected string
+	}{
+		{"in progress", 1238099, 10317491, time.Second,
+			false, "      1,238,099  12%    1.18MB/s    0:00:07"},
+		{"finished", 2000, 2000, 2 * time.Second,
+			true, "          2,000 100%    0.98kB/s    0:00:02"},
+		{"no rate", 0, 1000, 0,
+			false, "              0   0%    0.00kB/s   ??:??:??"},
+		{"empty", 0, 0, 0,
+			true, "              0 100%    0.00kB/s    0:00:00"},
+		{"fast", 5 << 30, 10 << 30, time.Second,
+			false, "  5,368,709,120  50%    5.00GB/s    0:00:01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := progressLine(tt.done, tt.size, tt.elapsed, tt.finished)
+			if got != tt.expected {
+				t.Errorf("got %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPerFile(t *testing.T) {
+	r, buf, advance := testReporter(PerFile, false)
+
+	f := r.Start(Hashing, "some/file", 3000)
+
+	// First progress is output immediately, then only after the interval.
+	advance(time.Second)
+	f.Add(1000)
+	advance(time.Second)
+	f.Add(1000)
+	advance(nonTTYInterval)
+	f.Add(500)
+
+	advance(time.Second)
+	f.Finish()
+	r.Close()
+
+	expected := "" +
+		"          1,000  33%    0.98kB/s    0:00:02  hashing some/file\n" +
+		"          2,500  83%    0.20kB/s    0:00:02  hashing some/file\n" +
+		"hashing some/file\n" +
+		"          3,000 100%    0.23kB/s    0:00:13 (xfr#0, ir-chk=0/1)\n"
+
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%q", buf.String())
+	}
+}
+
+func TestTotalTTY(t *testing.T) {
+	r, buf, advance := testReporter(Total, true)
+
+	hash := r.Start(Hashing, "file", 1024)
+	advance(time.Second)
+	hash.Add(1024)
+	hash.Finish()
+
+	// Other output should end the line drawn in place.
+	r.Write([]byte("some output\n"))
+
+	upload := r.Start(Uploading, "file", 1024)
+	upload.Add(2048)
+	upload.Restart()
+	advance(time.Second)
+	upload.Add(512)
+	upload.Finish()
+
+	advance(time.Second)
+	r.Close()
+
+	expected := "" +
+		"\r\033[K          1,024 100%    1.00kB/s    0:00:00 (xfr#0, ir-chk=1/1)" +
+		"\r\033[Ksome output\n" +
+		"\r\033[K          1,536  75%    0.75kB/s    0:00:00 (xfr#0, ir-chk=0/1)" +
+		"\r\033[K          2,048 100%    0.67kB/s    0:00:03 (xfr#1, to-chk=0/1)\n"
+
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%q", buf.String())
+	}
+}
+
+func TestReader(t *testing.T) {
+	r, _, _ := testReporter(Total, false)
+	f := r.Start(Uploading, "file", 10)
+
+	reader := f.Reader(strings.NewReader("0123456789"))
+
+	buf := make([]byte, 4)
+	if _, err := reader.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ReadAt(buf, 8); err != nil && err.Error() != "EOF" {
+		t.Fatal(err)
+	}
+
+	if r.done != 6 {
+		t.Errorf("unexpected progress: %v", r.done)
+	}
+}
+
+func TestNilReporter(t *testing.T) {
+	r := FromContext(context.Background())
+	if r != nil {
+		t.Fatal("got reporter from empty context")
+	}
+
+	// None of these should crash.
+	f := r.Start(Hashing, "file", 10)
+	f.Add(1)
+	f.Restart()
+	f.Finish()
+	r.Close()
+
+	rd := strings.NewReader("x")
+	if f.Reader(rd) != rd {
+		t.Error("nil reader didn't return original reader")
+	}
+	if n, _ := r.Write([]byte("abc")); n != 3 {
+		t.Error("nil write didn't consume data")
+	}
+
+	// Reporter should be available from context once set.
+	r = New(&bytes.Buffer{}, Total, false)
+	if FromContext(NewContext(context.Background(), r)) != r {
+		t.Error("couldn't get reporter from context")
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of some quantity, such as bytes sent or requests
+// made, shared between any number of goroutines.
+//
+// All methods may be called on a nil *Limiter, in which case there is no
+// limit.
+type Limiter struct {
+	// Duration of a single unit at the limited rate.
+	interval time.Duration
+
+	// Used in place of time.Now and time.After, for testing.
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+
+	mu sync.Mutex
+
+	// Time at which all units reserved so far have been used up.
+	next time.Time
+}
+
+// New returns a limiter for the given rate, in units per second, or nil if
+// rate is not positive.
+func New(rate float64) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &Limiter{
+		interval: time.Duration(float64(time.Second) / rate),
+		now:      time.Now,
+		after:    time.After,
+	}
+}
+
+// reserve reserves n units and returns the time to wait before using them.
+func (l *Limiter) reserve(n int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Time during which nothing was reserved is not saved up for later.
+	now := l.now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(n) * l.interval)
+
+	return delay
+}
+
+// Wait blocks until n units may be used without exceeding the limit, or ctx
+// is done.
+//
+// Units are reserved in the order in which Wait is called. The wait is
+// based on units reserved by earlier calls, so units can be used first and
+// waited for afterward (e.g. if the number of units isn't known in advance);
+// the limit is still enforced on average.
+func (l *Limiter) Wait(ctx context.Context, n int64) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	delay := l.reserve(n)
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.after(delay):
+		return nil
+	}
+}
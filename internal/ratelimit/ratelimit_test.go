@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Returns a limiter whose waits are recorded rather than performed, and
+// which only advances in time when waiting.
+func testLimiter(rate float64) (*Limiter, *[]time.Duration) {
+	now := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	waits := []time.Duration{}
+
+	l := New(rate)
+	l.now = func() time.Time { return now }
+	l.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		now = now.Add(d)
+
+		c := make(chan time.Time, 1)
+		c <- now
+		return c
+	}
+
+	return l, &waits
+}
+
+func TestWait(t *testing.T) {
+	l, waits := testLimiter(10)
+	ctx := context.Background()
+
+	for _, n := range []int64{1, 1, 5, 0, 1} {
+		if err := l.Wait(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each wait should be for the units reserved by the previous call.
+	expected := []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond}
+	if !reflect.DeepEqual(*waits, expected) {
+		t.Errorf("unexpected waits: %v", *waits)
+	}
+}
+
+func TestWaitIdle(t *testing.T) {
+	l, waits := testLimiter(1)
+	ctx := context.Background()
+
+	l.Wait(ctx, 1)
+
+	// Time spent idle can't be used for a burst later.
+	l.now = func() time.Time { return time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC) }
+	l.Wait(ctx, 1)
+	l.Wait(ctx, 1)
+
+	if !reflect.DeepEqual(*waits, []time.Duration{time.Second}) {
+		t.Errorf("unexpected waits: %v", *waits)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	l := New(0.001)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l.Wait(ctx, 1)
+	cancel()
+
+	if err := l.Wait(ctx, 1); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNoLimit(t *testing.T) {
+	l := New(0)
+	if l != nil {
+		t.Fatal("got limiter for no limit")
+	}
+
+	// Should not block or crash.
+	if err := l.Wait(context.Background(), 100); err != nil {
+		t.Error(err)
+	}
+}
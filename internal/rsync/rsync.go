@@ -50,11 +50,20 @@ var ext = struct {
 func Arguments(ctx context.Context, args args.Config) []string {
 	logger := log.FromContext(ctx)
 
+	// Arguments which were only peeked at are passed through as given.
+	if argv := args.RsyncArgs(); argv != nil {
+		logger.F("argv", argv).Debug("prepared rsync command")
+		return argv
+	}
+
 	argv := []string{}
 
 	if args.Verbose != 0 {
 		argv = append(argv, "-"+strings.Repeat("v", args.Verbose))
 	}
+	if args.Quiet {
+		argv = append(argv, "--quiet")
+	}
 	if args.Archive {
 		argv = append(argv, "--archive")
 	}
@@ -64,12 +73,24 @@ func Arguments(ctx context.Context, args args.Config) []string {
 	if args.Relative {
 		argv = append(argv, "--relative")
 	}
+	if args.NoRelative {
+		argv = append(argv, "--no-relative")
+	}
+	if args.OneFileSystem {
+		argv = append(argv, "--one-file-system")
+	}
 	if args.Links {
 		argv = append(argv, "--links")
 	}
 	if args.CopyLinks {
 		argv = append(argv, "--copy-links")
 	}
+	if args.CopyUnsafeLinks {
+		argv = append(argv, "--copy-unsafe-links")
+	}
+	if args.SafeLinks {
+		argv = append(argv, "--safe-links")
+	}
 	if args.KeepDirlinks {
 		argv = append(argv, "--keep-dirlinks")
 	}
@@ -115,12 +136,24 @@ func Arguments(ctx context.Context, args args.Config) []string {
 	if args.Rsh != "" {
 		argv = append(argv, "--rsh", args.Rsh)
 	}
+	if args.Port != 0 {
+		argv = append(argv, fmt.Sprintf("--port=%d", args.Port))
+	}
 	if args.IgnoreExisting {
 		argv = append(argv, "--ignore-existing")
 	}
+	if args.RemoveSourceFiles {
+		argv = append(argv, "--remove-source-files")
+	}
 	if args.Delete {
 		argv = append(argv, "--delete")
 	}
+	if args.DeleteExcluded {
+		argv = append(argv, "--delete-excluded")
+	}
+	if limit, ok := args.MaxDelete.Get(); ok {
+		argv = append(argv, fmt.Sprintf("--max-delete=%d", limit))
+	}
 	if args.PruneEmptyDirs {
 		argv = append(argv, "--prune-empty-dirs")
 	}
@@ -130,26 +163,64 @@ func Arguments(ctx context.Context, args args.Config) []string {
 	if args.Compress {
 		argv = append(argv, "--compress")
 	}
-	for _, rule := range args.Filter {
-		argv = append(argv, "--filter", fmt.Sprint(rule))
+	if args.Partial {
+		argv = append(argv, "--partial")
 	}
-	for _, ex := range args.Exclude {
-		argv = append(argv, "--exclude", fmt.Sprint(ex))
+	if args.Progress {
+		argv = append(argv, "--progress")
 	}
-	for _, in := range args.Include {
-		argv = append(argv, "--include", fmt.Sprint(in))
+	if len(args.Info) > 0 {
+		argv = append(argv, "--info="+strings.Join(args.Info, ","))
+	}
+	if len(args.Debug) > 0 {
+		argv = append(argv, "--debug="+strings.Join(args.Debug, ","))
+	}
+	if args.BwLimit != "" {
+		argv = append(argv, "--bwlimit="+string(args.BwLimit))
+	}
+	if args.CvsExclude {
+		argv = append(argv, "--cvs-exclude")
+	}
+	// All filter arguments are passed as --filter to retain their order.
+	for _, rule := range args.FilterRules() {
+		argv = append(argv, "--filter", rule)
 	}
 	if args.FilesFrom != "" {
 		argv = append(argv, "--files-from", fmt.Sprint(args.FilesFrom))
 	}
+	if args.From0 {
+		argv = append(argv, "--from0")
+	}
+	if args.MaxSize != "" {
+		argv = append(argv, "--max-size="+string(args.MaxSize))
+	}
+	if args.MinSize != "" {
+		argv = append(argv, "--min-size="+string(args.MinSize))
+	}
+	if args.ListOnly {
+		argv = append(argv, "--list-only")
+	}
 	if args.Stats {
 		argv = append(argv, "--stats")
 	}
 	if args.ItemizeChanges {
 		argv = append(argv, "--itemize-changes")
 	}
+	if args.OutFormat != "" {
+		argv = append(argv, "--out-format", string(args.OutFormat))
+	}
+	if args.LogFileFormat != "" {
+		argv = append(argv, "--log-file-format", string(args.LogFileFormat))
+	}
+	if args.HumanReadable != 0 {
+		argv = append(argv, "-"+strings.Repeat("h", args.HumanReadable))
+	}
+	// Everything else accepted for compatibility, including negations,
+	// which must come after any options they negate.
+	argv = append(argv, args.Other()...)
 
-	argv = append(argv, args.Src, args.Dest)
+	argv = append(argv, args.Src...)
+	argv = append(argv, args.Dest)
 
 	logger.F("argv", argv).Debug("prepared rsync command")
 
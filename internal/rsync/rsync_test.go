@@ -68,22 +68,28 @@ func TestExec(t *testing.T) {
 	}{
 		{"basic",
 			args.Config{
-				Src:  "some-src",
+				Src:  []string{"some-src"},
 				Dest: "some-dest",
 			},
 			[]string{"../../test/bin/rsync", "some-src", "some-dest"},
 		},
 
+		{"multiple sources",
+			args.Config{
+				Src:  []string{"src1", "src2/", "src3"},
+				Dest: "some-dest",
+			},
+			[]string{"../../test/bin/rsync", "src1", "src2/", "src3", "some-dest"},
+		},
+
 		{"all args",
 			args.Config{
-				Src:     "src",
+				Src:     []string{"src"},
 				Dest:    "dest",
 				Verbose: 3,
 				IgnoredConfig: args.IgnoredConfig{
+					Quiet:          true,
 					Archive:        true,
-					Recursive:      true,
-					Links:          true,
-					CopyLinks:      true,
 					KeepDirlinks:   true,
 					HardLinks:      true,
 					Perms:          true,
@@ -99,29 +105,53 @@ func TestExec(t *testing.T) {
 					Crtimes:        true,
 					OmitDirTimes:   true,
 					Rsh:            "some-rsh",
-					Delete:         true,
 					PruneEmptyDirs: true,
-					Timeout:        1234,
 					Compress:       true,
-					Stats:          true,
-					ItemizeChanges: true,
+					Partial:        true,
+					HumanReadable:  2,
+					Port:           8873,
+					Debug:          []string{"FILTER", "DEL2"},
 				},
-				Relative:       true,
-				IgnoreExisting: true,
-				Filter:         []string{"some-filter"},
-				Exclude:        []string{".*"},
-				Include:        []string{"**/dir"},
-				FilesFrom:      "sources.txt",
+				Recursive:         true,
+				Relative:          true,
+				ListOnly:          true,
+				NoRelative:        true,
+				OneFileSystem:     true,
+				Stats:             true,
+				ItemizeChanges:    true,
+				OutFormat:         "%i %n",
+				LogFileFormat:     "%o %f",
+				Progress:          true,
+				Info:              []string{"progress2", "stats"},
+				BwLimit:           "1.5M",
+				Timeout:           1234,
+				Links:             true,
+				CopyLinks:         true,
+				CopyUnsafeLinks:   true,
+				SafeLinks:         true,
+				Delete:            true,
+				DeleteExcluded:    true,
+				IgnoreExisting:    true,
+				RemoveSourceFiles: true,
+				Filter:            []string{"some-filter"},
+				Exclude:           []string{".*"},
+				Include:           []string{"**/dir"},
+				CvsExclude:        true,
+				FilesFrom:         "sources.txt",
+				From0:             true,
+				MaxSize:           "4G",
+				MinSize:           "1",
 			},
 			[]string{
-				"../../test/bin/rsync", "-vvv",
-				"--archive", "--recursive", "--relative", "--links", "--copy-links",
-				"--keep-dirlinks", "--hard-links", "--perms", "--executability", "--acls",
+				"../../test/bin/rsync", "-vvv", "--quiet",
+				"--archive", "--recursive", "--relative", "--no-relative", "--one-file-system", "--links", "--copy-links",
+				"--copy-unsafe-links", "--safe-links", "--keep-dirlinks", "--hard-links", "--perms", "--executability", "--acls",
 				"--xattrs", "--owner", "--group", "--devices", "--specials", "--times",
-				"--atimes", "--crtimes", "--omit-dir-times", "--rsh", "some-rsh",
-				"--ignore-existing", "--delete", "--prune-empty-dirs", "--timeout", "1234",
-				"--compress", "--filter", "some-filter", "--exclude", ".*", "--include", "**/dir",
-				"--files-from", "sources.txt", "--stats", "--itemize-changes",
+				"--atimes", "--crtimes", "--omit-dir-times", "--rsh", "some-rsh", "--port=8873",
+				"--ignore-existing", "--remove-source-files", "--delete", "--delete-excluded", "--prune-empty-dirs", "--timeout", "1234",
+				"--compress", "--partial", "--progress", "--info=progress2,stats", "--debug=FILTER,DEL2", "--bwlimit=1.5M", "--cvs-exclude", "--filter", "some-filter", "--filter", "- .*", "--filter", "+ **/dir",
+				"--files-from", "sources.txt", "--from0", "--max-size=4G", "--min-size=1", "--list-only", "--stats", "--itemize-changes",
+				"--out-format", "%i %n", "--log-file-format", "%o %f", "-hh",
 				"src", "dest",
 			},
 		},
@@ -157,3 +187,41 @@ func TestExec(t *testing.T) {
 		})
 	}
 }
+
+func TestArgumentsCompat(t *testing.T) {
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	parsed := args.Parse([]string{
+		"rsync", "-avzh", "--no-perms", "--checksum", "--del", "-B1024", "--protect-args",
+		"--exclude-from=ex.txt", "src", "dest",
+	}, "", nil)
+
+	// Options should be regenerated with the same effect, with negations
+	// after the options they negate.
+	expected := []string{
+		"-v", "--archive", "--delete", "--compress", "--filter", "merge,- ex.txt", "-h",
+		"--no-perms", "--checksum", "--del", "--block-size=1024", "--protect-args",
+		"src", "dest",
+	}
+
+	if got := Arguments(ctx, parsed); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got unexpected arguments %q", got)
+	}
+}
+
+func TestArgumentsPeeked(t *testing.T) {
+	ctx := context.Background()
+	ctx = log.NewContext(ctx, log.Package.NewLogger(args.Config{}))
+
+	peeked := args.Peek([]string{
+		"rsync", "-avzY", "--exodus-conf", "my.conf", "--some-future-option=x", "src", "dest",
+	})
+
+	// Arguments which were only peeked at should be passed through as given.
+	expected := []string{"-avzY", "--some-future-option=x", "src", "dest"}
+
+	if got := Arguments(ctx, peeked); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got unexpected arguments %q", got)
+	}
+}
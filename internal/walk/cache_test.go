@@ -0,0 +1,76 @@
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/apex/log/handlers/cli"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+)
+
+// fakeCache is a ChecksumCache holding checksums by path.
+type fakeCache struct {
+	mu     sync.Mutex
+	sums   map[string]string
+	stored map[string]string
+}
+
+func (f *fakeCache) Lookup(path string, info fs.FileInfo) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sum, ok := f.sums[filepath.Base(path)]
+	return sum, ok
+}
+
+func (f *fakeCache) Store(path string, info fs.FileInfo, sum string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stored[filepath.Base(path)] = sum
+}
+
+func TestWalkChecksumCache(t *testing.T) {
+	ctx := context.Background()
+	logger := log.Logger{}
+	logger.Handler = cli.New(os.Stdout)
+	ctx = log.NewContext(ctx, &logger)
+
+	root := makeFilterTree(t, nil)
+
+	cache := &fakeCache{
+		sums:   map[string]string{"a.txt": "cached-a"},
+		stored: map[string]string{},
+	}
+
+	got := map[string]string{}
+	err := Walk(ctx, root, Options{Cache: cache}, func(item SyncItem) error {
+		got[filepath.Base(item.SrcPath)] = item.Key
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached checksum should be used as-is, without hashing.
+	if got["a.txt"] != "cached-a" {
+		t.Errorf("cached checksum not used, got %v", got["a.txt"])
+	}
+
+	// Everything else should be hashed and stored.
+	if _, ok := cache.stored["a.txt"]; ok {
+		t.Error("cached checksum was stored again")
+	}
+	delete(got, "a.txt")
+	if !reflect.DeepEqual(got, cache.stored) {
+		t.Errorf("stored %v, expected %v", cache.stored, got)
+	}
+	if got["c.txt"] != "2e7d2c03a9507ae265ecf5b5356885a53393a2029d241394997265a1a25aefc6" {
+		t.Errorf("unexpected checksum for c.txt: %v", got["c.txt"])
+	}
+}
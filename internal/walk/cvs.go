@@ -0,0 +1,50 @@
+package walk
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+)
+
+// Patterns excluded by -C before any others, as in rsync.
+var cvsDefaultIgnores = []string{
+	"RCS", "SCCS", "CVS", "CVS.adm", "RCSLOG", "cvslog.*", "tags", "TAGS",
+	".make.state", ".nse_depinfo", "*~", "#*", ".#*", ",*", "_$*", "*$",
+	"*.old", "*.bak", "*.BAK", "*.orig", "*.rej", ".del-*", "*.a", "*.olb",
+	"*.o", "*.obj", "*.so", "*.exe", "*.Z", "*.elc", "*.ln", "core",
+	".svn/", ".git/", ".hg/", ".bzr/",
+}
+
+// cvsExcludes returns the entries inserted in place of a "-C" rule: the
+// default CVS ignore list, then patterns from ~/.cvsignore and $CVSIGNORE.
+// As in rsync, these are perishable and split on whitespace.
+func cvsExcludes(rule args.FilterRule, text string, base string) (filterList, error) {
+	patterns := cvsDefaultIgnores[:len(cvsDefaultIgnores):len(cvsDefaultIgnores)]
+
+	if home, err := os.UserHomeDir(); err == nil {
+		content, err := os.ReadFile(filepath.Join(home, ".cvsignore"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		patterns = append(patterns, strings.Fields(string(content))...)
+	}
+
+	patterns = append(patterns, strings.Fields(os.Getenv("CVSIGNORE"))...)
+
+	out := make(filterList, 0, len(patterns))
+	for _, pattern := range patterns {
+		exclude := args.FilterRule{
+			Action:     args.FilterExclude,
+			Side:       rule.Side,
+			Pattern:    pattern,
+			Perishable: true,
+		}
+		out = append(out, newFilterEntry(exclude, text, base))
+	}
+
+	return out, nil
+}
@@ -22,7 +22,7 @@ func TestFillItemInfoError(t *testing.T) {
 	item.SrcPath = "some/file"
 	item.Entry = entry
 	c := make(chan syncItemPrivate)
-	err := fillItem(context.TODO(), c, item)
+	err := fillItem(context.TODO(), c, item, Options{})
 
 	// It should propagate the error.
 	if fmt.Sprint(err) != "get file info for some/file: simulated error" {
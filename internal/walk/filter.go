@@ -0,0 +1,440 @@
+package walk
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+)
+
+// filterEntry is a single rule within a filterList.
+type filterEntry struct {
+	rule args.FilterRule
+
+	// The rule as originally written, for use in messages.
+	text string
+
+	// Directory, relative to the top of the transfer, of the per-directory
+	// merge file from which this rule was loaded. Anchored patterns are
+	// relative to this directory.
+	base string
+
+	// The pattern with any trailing slash removed, and whether the slash
+	// was present (i.e. the pattern only matches directories).
+	pattern string
+	dirOnly bool
+
+	// True if the pattern is matched against the full path rather than only
+	// the final component.
+	fullPath bool
+
+	// True if the pattern ends with "/***", so matches a directory as well
+	// as its contents.
+	dirContents bool
+
+	matcher *matcher
+
+	// For dir-merge rules, the rules loaded from per-directory files for the
+	// current directory, including those inherited from parent directories.
+	merged filterList
+}
+
+// filterList is an ordered list of filter rules, evaluated in first-match order.
+type filterList []filterEntry
+
+// Filter evaluates filter rules against paths within a source tree, as rsync
+// does.
+type Filter struct {
+	root string
+
+	// Working directory, for resolving absolute paths.
+	cwd string
+
+	// Prefix added to paths relative to root, to produce paths relative to
+	// the top of the transfer (see relPath).
+	prefix string
+
+	deleteExcluded bool
+
+	global filterList
+
+	// Lists in effect within each directory, after processing per-directory
+	// merge files. Directories with no state use the list of their parent.
+	dirs map[string]filterList
+}
+
+func newFilterEntry(rule args.FilterRule, text string, base string) filterEntry {
+	out := filterEntry{rule: rule, text: text, base: base, pattern: rule.Pattern}
+
+	if strings.HasSuffix(out.pattern, "/") {
+		out.dirOnly = true
+		out.pattern = strings.TrimRight(out.pattern, "/")
+	}
+
+	out.fullPath = strings.Contains(out.pattern, "/") || strings.Contains(out.pattern, "**")
+	out.dirContents = strings.HasSuffix(out.pattern, "/***")
+	out.matcher = compileMatcher(out.pattern)
+
+	return out
+}
+
+// readFilterFile reads filter rules from a merge file, as requested by the
+// given merge or dir-merge rule.
+//
+// If the file contains a clear rule, cleared is true and only the rules
+// following the last clear rule are returned.
+func readFilterFile(filename string, merge args.FilterRule, base string) (rules filterList, cleared bool, err error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, false, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		texts := []string{line}
+		if merge.WordSplit {
+			texts = strings.Fields(line)
+		}
+
+		for _, text := range texts {
+			var rule args.FilterRule
+
+			if text == "!" {
+				rule.Action = args.FilterClear
+			} else if merge.MergeAction != 0 {
+				// File contains only patterns.
+				rule = args.FilterRule{Action: merge.MergeAction, Pattern: text}
+			} else if rule, err = args.ParseFilterRule(text); err != nil {
+				return nil, false, fmt.Errorf("%s: %w", filename, err)
+			}
+
+			if rule.Side == args.FilterBothSides {
+				rule.Side = merge.Side
+			}
+
+			switch rule.Action {
+			case args.FilterClear:
+				rules = nil
+				cleared = true
+
+			case args.FilterMerge:
+				mergePath := rule.Pattern
+				if merge.Action == args.FilterDirMerge && !filepath.IsAbs(mergePath) {
+					mergePath = filepath.Join(filepath.Dir(filename), mergePath)
+				}
+
+				nested, nestedCleared, err := readFilterFile(mergePath, rule, base)
+				if err != nil {
+					return nil, false, err
+				}
+				if nestedCleared {
+					rules = nil
+					cleared = true
+				}
+				rules = append(rules, nested...)
+
+			case args.FilterDirMerge:
+				if merge.Action == args.FilterDirMerge {
+					return nil, false, fmt.Errorf(
+						"%s: dir-merge rules within per-directory merge files are not supported", filename)
+				}
+				rules = append(rules, newFilterEntry(rule, text, base))
+
+			case args.FilterExclude:
+				if !rule.CVS {
+					rules = append(rules, newFilterEntry(rule, text, base))
+					break
+				}
+				cvs, err := cvsExcludes(rule, text, base)
+				if err != nil {
+					return nil, false, fmt.Errorf("%s: %w", filename, err)
+				}
+				rules = append(rules, cvs...)
+
+			default:
+				rules = append(rules, newFilterEntry(rule, text, base))
+			}
+		}
+	}
+
+	return rules, cleared, nil
+}
+
+// NewFilter returns a Filter for the rules in opts, applied to the source tree
+// at root.
+func NewFilter(root string, opts Options) (*Filter, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Filter{
+		root:           filepath.Clean(root),
+		cwd:            cwd,
+		deleteExcluded: opts.DeleteExcluded,
+		dirs:           make(map[string]filterList),
+	}
+
+	// Paths are matched as they'll appear within the destination, which
+	// includes the basename of root unless it has a trailing slash.
+	if root != "." && !strings.HasSuffix(root, "/") {
+		out.prefix = filepath.Base(root)
+	}
+
+	// As in rsync, rules from --cvs-exclude come after all others,
+	// wherever the argument was given.
+	rules := opts.Rules
+	if opts.CVSExclude {
+		rules = append(rules[:len(rules):len(rules)], ":C", "-C")
+	}
+
+	for _, text := range rules {
+		rule, err := args.ParseFilterRule(text)
+		if err != nil {
+			return nil, err
+		}
+
+		switch rule.Action {
+		case args.FilterClear:
+			out.global = nil
+
+		case args.FilterMerge:
+			rules, cleared, err := readFilterFile(rule.Pattern, rule, "")
+			if err != nil {
+				return nil, fmt.Errorf("could not process filter rule `%s`: %w", text, err)
+			}
+			if cleared {
+				out.global = nil
+			}
+			out.global = append(out.global, rules...)
+
+		case args.FilterExclude:
+			if !rule.CVS {
+				out.global = append(out.global, newFilterEntry(rule, text, ""))
+				break
+			}
+			cvs, err := cvsExcludes(rule, text, "")
+			if err != nil {
+				return nil, fmt.Errorf("could not process filter rule `%s`: %w", text, err)
+			}
+			out.global = append(out.global, cvs...)
+
+		default:
+			out.global = append(out.global, newFilterEntry(rule, text, ""))
+		}
+	}
+
+	return out, nil
+}
+
+// relPath returns the path of an item within the source tree, relative to
+// the top of the transfer.
+func (f *Filter) relPath(srcPath string) string {
+	rel, err := filepath.Rel(f.root, filepath.Clean(srcPath))
+	if err != nil || rel == "." {
+		rel = ""
+	}
+	return path.Join(f.prefix, filepath.ToSlash(rel))
+}
+
+// listFor returns the rules in effect for items within dir.
+func (f *Filter) listFor(dir string) filterList {
+	dir = filepath.Clean(dir)
+	for {
+		if list, ok := f.dirs[dir]; ok {
+			return list
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return f.global
+		}
+		dir = parent
+	}
+}
+
+// enterDir prepares the rules in effect for items within dir, by loading
+// any per-directory merge files present in dir.
+func (f *Filter) enterDir(dir string) error {
+	dir = filepath.Clean(dir)
+
+	var list filterList
+	merges := false
+
+	for _, entry := range f.listFor(filepath.Dir(dir)) {
+		if entry.rule.Action != args.FilterDirMerge {
+			list = append(list, entry)
+			continue
+		}
+		merges = true
+
+		var inherited filterList
+		if !entry.rule.NoInherit {
+			inherited = entry.merged
+		}
+
+		// As the rule applies only to files within the tree, any leading
+		// slash is ignored.
+		filename := filepath.Join(dir, path.Base(entry.rule.Pattern))
+
+		own, cleared, err := readFilterFile(filename, entry.rule, f.relPath(dir))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+			return fmt.Errorf("could not process filter rule `%s`: %w", entry.text, err)
+		}
+
+		if cleared {
+			inherited = nil
+		}
+
+		// Rules from this directory take precedence over inherited rules.
+		entry.merged = append(own[:len(own):len(own)], inherited...)
+		list = append(list, entry)
+	}
+
+	// Without any dir-merge rules, the parent's list applies unchanged.
+	if merges {
+		f.dirs[dir] = list
+	}
+
+	return nil
+}
+
+// matches returns true if the entry matches an item whose absolute path is
+// abs, and whose path relative to the top of the transfer is rel.
+func (e *filterEntry) matches(abs string, rel string, isDir bool) bool {
+	if e.dirOnly && !isDir {
+		return e.rule.Negate
+	}
+
+	var name string
+	switch {
+	case e.rule.Absolute:
+		name = filepath.ToSlash(abs)
+
+	case e.fullPath:
+		if e.base != "" {
+			rel = strings.TrimPrefix(rel, e.base+"/")
+		}
+		name = "/" + rel
+
+	default:
+		name = path.Base(rel)
+	}
+
+	if e.dirContents && isDir {
+		// Lets "dir/***" match the directory itself.
+		name += "/"
+	}
+
+	return e.matcher.match(name) != e.rule.Negate
+}
+
+// appliesTo returns true if the entry should be considered for the given side.
+func (e *filterEntry) appliesTo(side args.FilterSide, deleteExcluded bool) bool {
+	if e.rule.Side == args.FilterBothSides {
+		// With --delete-excluded, rules without an explicit side only apply
+		// to the sender.
+		return !(deleteExcluded && side == args.FilterReceiver)
+	}
+	return e.rule.Side == side
+}
+
+// evaluate returns the first rule in the list matching the given item, or nil.
+func (l filterList) evaluate(abs string, rel string, isDir bool,
+	side args.FilterSide, deleteExcluded bool) *filterEntry {
+	for i := range l {
+		entry := &l[i]
+
+		if entry.rule.Action == args.FilterDirMerge {
+			if entry.rule.ExcludeSelf && path.Base(rel) == path.Base(entry.rule.Pattern) {
+				return entry
+			}
+
+			if found := entry.merged.evaluate(abs, rel, isDir, side, deleteExcluded); found != nil {
+				return found
+			}
+			continue
+		}
+
+		if entry.appliesTo(side, deleteExcluded) && entry.matches(abs, rel, isDir) {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// excluded returns true if the item at srcPath is excluded on the given side.
+func (f *Filter) excluded(srcPath string, isDir bool, side args.FilterSide) bool {
+	srcPath = filepath.Clean(srcPath)
+
+	rel := f.relPath(srcPath)
+	if rel == "" {
+		// The top of the transfer can't be excluded.
+		return false
+	}
+
+	abs := srcPath
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(f.cwd, abs)
+	}
+
+	entry := f.listFor(filepath.Dir(srcPath)).evaluate(abs, rel, isDir, side, f.deleteExcluded)
+	if entry == nil {
+		return false
+	}
+
+	// A dir-merge rule is only returned when excluding its own merge file.
+	return entry.rule.Action != args.FilterInclude
+}
+
+// Protected returns true if an item at srcPath, which may or may not exist,
+// is protected from deletion by the filter rules. This is the case if the
+// item or any directory containing it is excluded on the receiving side.
+func (f *Filter) Protected(srcPath string) (bool, error) {
+	srcPath = filepath.Clean(srcPath)
+
+	rel, err := filepath.Rel(f.root, srcPath)
+	if err != nil {
+		return false, err
+	}
+
+	dir := f.root
+	for _, component := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if _, ok := f.dirs[dir]; !ok {
+			if err := f.enterDir(dir); err != nil {
+				return false, err
+			}
+		}
+
+		if component == "." {
+			break
+		}
+		dir = filepath.Join(dir, component)
+
+		if f.excluded(dir, true, args.FilterReceiver) {
+			return true, nil
+		}
+	}
+
+	if _, ok := f.dirs[dir]; !ok {
+		if err := f.enterDir(dir); err != nil {
+			return false, err
+		}
+	}
+
+	return f.excluded(srcPath, false, args.FilterReceiver), nil
+}
@@ -0,0 +1,326 @@
+package walk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/apex/log/handlers/cli"
+	"github.com/release-engineering/exodus-rsync/internal/log"
+)
+
+// Creates a source tree for filter tests beneath a temporary directory,
+// with any extra files from extra, returning the path of the tree.
+func makeFilterTree(t *testing.T, extra map[string]string) string {
+	root := filepath.Join(t.TempDir(), "src")
+
+	files := map[string]string{
+		"a.txt":          "a",
+		"b.tmp":          "b",
+		"keep.tmp":       "keep",
+		"sub/c.txt":      "c",
+		"sub/d.tmp":      "d",
+		"sub/deep/e.txt": "e",
+		"other/f.txt":    "f",
+	}
+	for name, content := range extra {
+		files[name] = content
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestWalkFilterRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		extra    map[string]string
+		expected []string
+	}{
+		{"no rules", nil, nil, []string{
+			"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/c.txt", "sub/d.tmp", "sub/deep/e.txt"}},
+
+		{"first match wins",
+			[]string{"+ keep.tmp", "- *.tmp"}, nil,
+			[]string{"a.txt", "keep.tmp", "other/f.txt", "sub/c.txt", "sub/deep/e.txt"}},
+
+		{"later include has no effect",
+			[]string{"- *.tmp", "+ keep.tmp"}, nil,
+			[]string{"a.txt", "other/f.txt", "sub/c.txt", "sub/deep/e.txt"}},
+
+		{"excluded dir not descended",
+			[]string{"- sub", "+ sub/c.txt"}, nil,
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt"}},
+
+		{"dir-only pattern",
+			[]string{"- *.txt/", "- deep/"}, nil,
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/c.txt", "sub/d.tmp"}},
+
+		{"anchored to top of transfer",
+			[]string{"- /src/a.txt", "- /c.txt"}, nil,
+			[]string{"b.tmp", "keep.tmp", "other/f.txt", "sub/c.txt", "sub/d.tmp", "sub/deep/e.txt"}},
+
+		{"negated",
+			[]string{"+ */", "-! *.txt"}, nil,
+			[]string{"a.txt", "other/f.txt", "sub/c.txt", "sub/deep/e.txt"}},
+
+		{"hide and show",
+			[]string{"show keep.tmp", "H *.tmp"}, nil,
+			[]string{"a.txt", "keep.tmp", "other/f.txt", "sub/c.txt", "sub/deep/e.txt"}},
+
+		{"receiver rules ignored",
+			[]string{"P *.txt", "-r *.tmp", "R other/"}, nil,
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/c.txt", "sub/d.tmp", "sub/deep/e.txt"}},
+
+		{"clear",
+			[]string{"- *.txt", "!", "- *.tmp"}, nil,
+			[]string{"a.txt", "other/f.txt", "sub/c.txt", "sub/deep/e.txt"}},
+
+		{"dir-merge",
+			[]string{": .filt"},
+			map[string]string{"sub/.filt": "# only txt files\n- *.txt\n"},
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/.filt", "sub/d.tmp"}},
+
+		{"dir-merge excluding self",
+			[]string{"dir-merge,e .filt"},
+			map[string]string{"sub/.filt": "- *.txt\n"},
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/d.tmp"}},
+
+		{"dir-merge without inheritance",
+			[]string{":n .filt", "- .filt"},
+			map[string]string{"sub/.filt": "- *.txt\n"},
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/d.tmp", "sub/deep/e.txt"}},
+
+		{"dir-merge deeper rules first",
+			[]string{": .filt", "- .filt"},
+			map[string]string{".filt": "- *.txt\n", "sub/deep/.filt": "+ e.txt\n"},
+			[]string{"b.tmp", "keep.tmp", "sub/d.tmp", "sub/deep/e.txt"}},
+
+		{"dir-merge clears inherited",
+			[]string{": .filt", "- .filt"},
+			map[string]string{".filt": "- *.txt\n", "sub/.filt": "!\n- *.tmp\n"},
+			[]string{"b.tmp", "keep.tmp", "sub/c.txt", "sub/deep/e.txt"}},
+
+		{"dir-merge anchored to dir",
+			[]string{": .filt", "- .filt"},
+			map[string]string{"sub/.filt": "- /deep\n", "other/deep/g.txt": "g"},
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/deep/g.txt", "other/f.txt", "sub/c.txt", "sub/d.tmp"}},
+
+		{"dir-merge of patterns",
+			[]string{":w- .filt", "- .filt"},
+			map[string]string{"sub/.filt": "c.txt d.tmp\n"},
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/deep/e.txt"}},
+
+		{"-F",
+			[]string{"dir-merge /.rsync-filter", "- .rsync-filter"},
+			map[string]string{"sub/.rsync-filter": "- d.tmp\n- deep/\n"},
+			[]string{"a.txt", "b.tmp", "keep.tmp", "other/f.txt", "sub/c.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			logger := log.Logger{}
+			logger.Handler = cli.New(os.Stdout)
+			ctx = log.NewContext(ctx, &logger)
+
+			root := makeFilterTree(t, tt.extra)
+
+			got := []string{}
+			err := Walk(ctx, root, Options{Rules: tt.rules}, func(item SyncItem) error {
+				rel, _ := filepath.Rel(root, item.SrcPath)
+				got = append(got, rel)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unexpected items: %v", got)
+			}
+		})
+	}
+}
+
+func TestWalkFilterMerge(t *testing.T) {
+	ctx := context.Background()
+	logger := log.Logger{}
+	logger.Handler = cli.New(os.Stdout)
+	ctx = log.NewContext(ctx, &logger)
+
+	root := makeFilterTree(t, nil)
+
+	rulesFile := filepath.Join(t.TempDir(), "rules")
+	err := os.WriteFile(rulesFile, []byte("# comment\n\n; another comment\n+ keep.tmp\n- *.tmp\n- sub/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	err = Walk(ctx, root+"/", Options{Rules: []string{"merge " + rulesFile}}, func(item SyncItem) error {
+		rel, _ := filepath.Rel(root, item.SrcPath)
+		got = append(got, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(got)
+	expected := []string{"a.txt", "keep.tmp", "other/f.txt"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected items: %v", got)
+	}
+}
+
+func TestWalkCVSExclude(t *testing.T) {
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, ".cvsignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("CVSIGNORE", "a.txt keep.tmp")
+
+	extra := map[string]string{
+		".git/config":    "git",
+		"b.txt~":         "backup",
+		"core":           "core",
+		"sub/a.orig":     "orig",
+		"sub/.cvsignore": "c.txt\n",
+		"sub/deep/x.log": "log",
+		"sub/deep/c.txt": "c",
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{"option", Options{CVSExclude: true}, []string{
+			"b.tmp", "other/f.txt", "sub/.cvsignore", "sub/d.tmp", "sub/deep/c.txt", "sub/deep/e.txt"}},
+
+		{"rules", Options{Rules: []string{"-C", ":C"}}, []string{
+			"b.tmp", "other/f.txt", "sub/.cvsignore", "sub/d.tmp", "sub/deep/c.txt", "sub/deep/e.txt"}},
+
+		// Rules from the option come after any others.
+		{"after other rules", Options{Rules: []string{"+ core"}, CVSExclude: true}, []string{
+			"b.tmp", "core", "other/f.txt", "sub/.cvsignore", "sub/d.tmp", "sub/deep/c.txt", "sub/deep/e.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			logger := log.Logger{}
+			logger.Handler = cli.New(os.Stdout)
+			ctx = log.NewContext(ctx, &logger)
+
+			root := makeFilterTree(t, extra)
+
+			got := []string{}
+			err := Walk(ctx, root, tt.opts, func(item SyncItem) error {
+				rel, _ := filepath.Rel(root, item.SrcPath)
+				got = append(got, rel)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unexpected items: %v", got)
+			}
+		})
+	}
+}
+
+func TestWalkFilterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		extra    map[string]string
+		expected string
+	}{
+		{"missing merge file", []string{". /nonexistent/rules"}, nil,
+			"could not process filter rule `. /nonexistent/rules`: open /nonexistent/rules: no such file or directory"},
+
+		{"bad rule in dir-merge file", []string{": .filt"}, map[string]string{"sub/.filt": "x foo\n"},
+			"unsupported filter 'x foo'"},
+
+		{"nested dir-merge", []string{": .filt"}, map[string]string{"sub/.filt": ": .other\n"},
+			"dir-merge rules within per-directory merge files are not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			logger := log.Logger{}
+			logger.Handler = cli.New(os.Stdout)
+			ctx = log.NewContext(ctx, &logger)
+
+			root := makeFilterTree(t, tt.extra)
+
+			err := Walk(ctx, root, Options{Rules: tt.rules}, func(item SyncItem) error { return nil })
+
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("did not get expected error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFilterProtected(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		path      string
+		protected bool
+	}{
+		{"no rules", Options{}, "sub/gone.tmp", false},
+		{"excluded", Options{Rules: []string{"- *.tmp"}}, "sub/gone.tmp", true},
+		{"not excluded", Options{Rules: []string{"- *.tmp"}}, "sub/gone.txt", false},
+		{"excluded dir", Options{Rules: []string{"- old/"}}, "old/x/gone.txt", true},
+		{"hidden", Options{Rules: []string{"H *.tmp"}}, "gone.tmp", false},
+		{"protected", Options{Rules: []string{"P *.tmp"}}, "gone.tmp", true},
+		{"risked", Options{Rules: []string{"R keep.tmp", "- *.tmp"}}, "keep.tmp", false},
+		{"delete-excluded", Options{Rules: []string{"- *.tmp"}, DeleteExcluded: true}, "gone.tmp", false},
+		{"delete-excluded protect", Options{Rules: []string{"P *.tmp"}, DeleteExcluded: true}, "gone.tmp", true},
+		{"dir-merge", Options{Rules: []string{": .filt"}}, "sub/deep/gone.bak", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := makeFilterTree(t, map[string]string{"sub/.filt": "- *.bak\n"})
+
+			filter, err := NewFilter(root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			protected, err := filter.Protected(filepath.Join(root, tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if protected != tt.protected {
+				t.Errorf("Protected(%s) = %v, expected %v", tt.path, protected, tt.protected)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package walk
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -18,7 +19,7 @@ func TestHashError(t *testing.T) {
 	// make it fail
 	mockHash.EXPECT().Write(gomock.Any()).Return(0, fmt.Errorf("simulated error"))
 
-	_, err := fileHash("walk.go", mockHash)
+	_, err := fileHash(context.TODO(), "walk.go", mockHash)
 
 	// It should propagate the error.
 	if fmt.Sprint(err) != "simulated error" {
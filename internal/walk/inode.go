@@ -0,0 +1,78 @@
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"syscall"
+)
+
+// fileID identifies a file by device and inode, shared by all of its hard
+// links.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// statOf returns the underlying stat of info, or nil if unavailable.
+func statOf(info fs.FileInfo) *syscall.Stat_t {
+	st, _ := info.Sys().(*syscall.Stat_t)
+	return st
+}
+
+// inodeSum is the checksum of a single inode, which is available once done
+// is closed.
+type inodeSum struct {
+	done chan struct{}
+	sum  string
+	err  error
+}
+
+// InodeSums records the checksums of files having several hard links, so
+// that each inode is read and hashed only once, however many links to it are
+// walked. It is safe for concurrent use.
+type InodeSums struct {
+	mu   sync.Mutex
+	sums map[fileID]*inodeSum
+}
+
+// NewInodeSums returns an empty InodeSums.
+func NewInodeSums() *InodeSums {
+	return &InodeSums{sums: make(map[fileID]*inodeSum)}
+}
+
+// checksum returns the checksum of the file with the given info, using calc
+// unless the checksum was already calculated via another link to the same
+// inode. If the checksum is being calculated via another link, it waits for
+// that calculation to complete.
+//
+// It's safe to call on a nil InodeSums, which always uses calc.
+func (s *InodeSums) checksum(ctx context.Context, info fs.FileInfo, calc func() (string, error)) (string, error) {
+	st := statOf(info)
+	if s == nil || st == nil || st.Nlink < 2 {
+		return calc()
+	}
+
+	id := fileID{uint64(st.Dev), uint64(st.Ino)}
+
+	s.mu.Lock()
+	entry, found := s.sums[id]
+	if !found {
+		entry = &inodeSum{done: make(chan struct{})}
+		s.sums[id] = entry
+	}
+	s.mu.Unlock()
+
+	if !found {
+		entry.sum, entry.err = calc()
+		close(entry.done)
+		return entry.sum, entry.err
+	}
+
+	select {
+	case <-entry.done:
+		return entry.sum, entry.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,125 @@
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/release-engineering/exodus-rsync/internal/log"
+)
+
+// infoEntry is a fs.DirEntry for a path found via lstat rather than by
+// reading its parent directory.
+type infoEntry struct {
+	fs.FileInfo
+}
+
+func (e infoEntry) Type() fs.FileMode {
+	return e.Mode().Type()
+}
+
+func (e infoEntry) Info() (fs.FileInfo, error) {
+	return e.FileInfo, nil
+}
+
+// walkListed passes each path in opts.OnlyThese to walkFn, in sorted order,
+// without walking anything else within root other than listed directories.
+func walkListed(ctx context.Context, root string, opts Options, filter *Filter, walkFn fs.WalkDirFunc) error {
+	logger := log.FromContext(ctx)
+
+	root = filepath.Clean(root)
+
+	listed := make(map[string]bool, len(opts.OnlyThese))
+	for _, path := range opts.OnlyThese {
+		listed[filepath.Clean(path)] = true
+	}
+
+	paths := make([]string, 0, len(listed))
+	for path := range listed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Returns true if path is beneath another listed path, in which case
+	// it's walked along with that path.
+	includedByParent := func(path string) bool {
+		for dir := path; dir != root; {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			if listed[parent] {
+				return true
+			}
+			dir = parent
+		}
+		return false
+	}
+
+	// Filter rules from per-directory merge files apply to listed paths
+	// just as when walking the whole tree, so each directory from root
+	// down to a listed path must be entered before the path is filtered.
+	entered := make(map[string]bool)
+	var enter func(dir string) error
+	enter = func(dir string) error {
+		if entered[dir] {
+			return nil
+		}
+		if parent := filepath.Dir(dir); dir != root && parent != dir {
+			if err := enter(parent); err != nil {
+				return err
+			}
+		}
+		entered[dir] = true
+		return filter.enterDir(dir)
+	}
+
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if opts.Recursive && includedByParent(path) {
+			continue
+		}
+
+		if path != root {
+			if err := enter(filepath.Dir(path)); err != nil {
+				return err
+			}
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			if err = walkFn(path, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		isDir := info.IsDir()
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err == nil {
+				isDir = target.IsDir()
+			}
+		}
+
+		if isDir && !opts.Recursive {
+			logger.F("path", path).Debug("skipping directory; use -r to include its content")
+			continue
+		}
+
+		if info.IsDir() {
+			err = filepath.WalkDir(path, walkFn)
+		} else {
+			err = walkFn(path, infoEntry{info}, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
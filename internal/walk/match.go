@@ -0,0 +1,305 @@
+package walk
+
+import "strings"
+
+// Results of matching, as in rsync's wildmatch.c. The abort results allow
+// matching to end early when no amount of backtracking could help.
+const (
+	wildNoMatch = iota
+	wildMatch
+	wildAbortAll
+	wildAbortToStarStar
+)
+
+type tokenKind int
+
+const (
+	tokLiteral    tokenKind = iota
+	tokAny                  // "?"
+	tokStar                 // "*"
+	tokDoubleStar           // "**" (or more)
+	tokClass                // "[...]"
+)
+
+type token struct {
+	kind  tokenKind
+	lit   byte
+	class *[256]bool
+}
+
+// matcher is a compiled rsync pattern, as described in "INCLUDE/EXCLUDE
+// PATTERN RULES" within "man rsync".
+type matcher struct {
+	tokens []token
+
+	// True if the pattern starts with "/" and so must match the whole name.
+	anchored bool
+
+	// True if the pattern starts with "**", which can match the top level
+	// even if the pattern goes on to use "/".
+	leadingStarStar bool
+
+	// True if the pattern can never match anything (e.g. an unterminated
+	// character class, which rsync silently treats as a failed match).
+	never bool
+}
+
+// Named character classes usable within "[...]".
+var charClasses = map[string]func(c byte) bool{
+	"alnum":  func(c byte) bool { return isAlpha(c) || isDigit(c) },
+	"alpha":  isAlpha,
+	"blank":  func(c byte) bool { return c == ' ' || c == '\t' },
+	"cntrl":  func(c byte) bool { return c < 0x20 || c == 0x7f },
+	"digit":  isDigit,
+	"graph":  func(c byte) bool { return c > 0x20 && c < 0x7f },
+	"lower":  func(c byte) bool { return c >= 'a' && c <= 'z' },
+	"print":  func(c byte) bool { return c >= 0x20 && c < 0x7f },
+	"punct":  func(c byte) bool { return c > 0x20 && c < 0x7f && !isAlpha(c) && !isDigit(c) },
+	"space":  func(c byte) bool { return c == ' ' || (c >= '\t' && c <= '\r') },
+	"upper":  func(c byte) bool { return c >= 'A' && c <= 'Z' },
+	"xdigit": func(c byte) bool { return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') },
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compileClass compiles the character class at the start of pattern (just
+// after the opening "["), returning the class and the remainder of pattern
+// after the closing "]". ok is false if the class is invalid.
+func compileClass(pattern string) (class *[256]bool, rest string, ok bool) {
+	class = new([256]bool)
+
+	negated := false
+	if len(pattern) > 0 && (pattern[0] == '!' || pattern[0] == '^') {
+		negated = true
+		pattern = pattern[1:]
+	}
+
+	// The first character is never the end of the class, so "[]]" is a
+	// class matching "]".
+	var prev byte
+	for first := true; first || len(pattern) == 0 || pattern[0] != ']'; first = false {
+		if len(pattern) == 0 {
+			return nil, "", false
+		}
+
+		c := pattern[0]
+		switch {
+		case c == '\\':
+			if len(pattern) < 2 {
+				return nil, "", false
+			}
+			c = pattern[1]
+			class[c] = true
+			pattern = pattern[1:]
+
+		case c == '-' && prev != 0 && len(pattern) > 1 && pattern[1] != ']':
+			end := pattern[1]
+			pattern = pattern[1:]
+			if end == '\\' {
+				if len(pattern) < 2 {
+					return nil, "", false
+				}
+				end = pattern[1]
+				pattern = pattern[1:]
+			}
+			for b := int(prev); b <= int(end); b++ {
+				class[b] = true
+			}
+			// A range can't start another range.
+			c = 0
+
+		case c == '[' && len(pattern) > 1 && pattern[1] == ':':
+			end := strings.Index(pattern[2:], ":]")
+			if end == -1 {
+				// Not a named class, just a literal "[".
+				class['['] = true
+				break
+			}
+			isClass, known := charClasses[pattern[2:2+end]]
+			if !known {
+				return nil, "", false
+			}
+			for b := 0; b < 256; b++ {
+				if isClass(byte(b)) {
+					class[b] = true
+				}
+			}
+			pattern = pattern[2+end+1:]
+			c = 0
+
+		default:
+			class[c] = true
+		}
+
+		prev = c
+		pattern = pattern[1:]
+	}
+
+	if negated {
+		for b := range class {
+			class[b] = !class[b]
+		}
+	}
+
+	// Classes never match a slash.
+	class['/'] = false
+
+	return class, pattern[1:], true
+}
+
+// compileMatcher compiles an rsync pattern for use in matching. The pattern
+// should not have any trailing slash.
+func compileMatcher(pattern string) *matcher {
+	out := &matcher{
+		anchored:        strings.HasPrefix(pattern, "/"),
+		leadingStarStar: strings.HasPrefix(pattern, "**"),
+	}
+
+	// Backslash is only an escape character if the pattern has wildcards.
+	if !strings.ContainsAny(pattern, "*?[") {
+		for i := 0; i < len(pattern); i++ {
+			out.tokens = append(out.tokens, token{kind: tokLiteral, lit: pattern[i]})
+		}
+		return out
+	}
+
+	for len(pattern) > 0 {
+		c := pattern[0]
+		pattern = pattern[1:]
+
+		switch c {
+		case '\\':
+			if len(pattern) > 0 {
+				c = pattern[0]
+				pattern = pattern[1:]
+			}
+			out.tokens = append(out.tokens, token{kind: tokLiteral, lit: c})
+
+		case '?':
+			out.tokens = append(out.tokens, token{kind: tokAny})
+
+		case '*':
+			kind := tokStar
+			if strings.HasPrefix(pattern, "*") {
+				kind = tokDoubleStar
+				pattern = strings.TrimLeft(pattern, "*")
+			}
+			out.tokens = append(out.tokens, token{kind: kind})
+
+		case '[':
+			class, rest, ok := compileClass(pattern)
+			if !ok {
+				out.never = true
+				return out
+			}
+			pattern = rest
+			out.tokens = append(out.tokens, token{kind: tokClass, class: class})
+
+		default:
+			out.tokens = append(out.tokens, token{kind: tokLiteral, lit: c})
+		}
+	}
+
+	return out
+}
+
+// dowild matches tokens against the whole of text, following the algorithm
+// of rsync's wildmatch.c.
+func dowild(tokens []token, text string) int {
+	for i, tok := range tokens {
+		if len(text) == 0 && tok.kind != tokStar && tok.kind != tokDoubleStar {
+			return wildAbortAll
+		}
+
+		switch tok.kind {
+		case tokLiteral:
+			if text[0] != tok.lit {
+				return wildNoMatch
+			}
+
+		case tokAny:
+			if text[0] == '/' {
+				return wildNoMatch
+			}
+
+		case tokClass:
+			if !tok.class[text[0]] {
+				return wildNoMatch
+			}
+
+		case tokStar, tokDoubleStar:
+			special := tok.kind == tokDoubleStar
+			rest := tokens[i+1:]
+
+			if len(rest) == 0 {
+				// Trailing "**" matches everything; trailing "*" matches
+				// only if there are no more slashes.
+				if !special && strings.Contains(text, "/") {
+					return wildNoMatch
+				}
+				return wildMatch
+			}
+
+			for ; len(text) > 0; text = text[1:] {
+				if matched := dowild(rest, text); matched != wildNoMatch {
+					if !special || matched != wildAbortToStarStar {
+						return matched
+					}
+				} else if !special && text[0] == '/' {
+					return wildAbortToStarStar
+				}
+			}
+			return wildAbortAll
+		}
+
+		text = text[1:]
+	}
+
+	if len(text) == 0 {
+		return wildMatch
+	}
+	return wildNoMatch
+}
+
+// match returns true if name matches the pattern.
+//
+// Anchored patterns must match the whole of name, which should then start
+// with "/". Other patterns may match any trailing components of name.
+func (m *matcher) match(name string) bool {
+	if m.never {
+		return false
+	}
+
+	if m.anchored {
+		return dowild(m.tokens, name) == wildMatch
+	}
+
+	if m.leadingStarStar && strings.HasPrefix(name, "/") {
+		// Allows e.g. "**/foo" to match "foo" at the top level.
+		if dowild(m.tokens, name) == wildMatch {
+			return true
+		}
+	}
+
+	text := strings.TrimPrefix(name, "/")
+	for {
+		switch dowild(m.tokens, text) {
+		case wildMatch:
+			return true
+		case wildAbortAll:
+			return false
+		}
+
+		idx := strings.IndexByte(text, '/')
+		if idx == -1 {
+			return false
+		}
+		text = text[idx+1:]
+	}
+}
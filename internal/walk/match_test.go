@@ -0,0 +1,110 @@
+package walk
+
+import (
+	"testing"
+
+	"github.com/release-engineering/exodus-rsync/internal/args"
+)
+
+// Expected results follow "INCLUDE/EXCLUDE PATTERN RULES" in "man rsync"
+// and the behavior of wildmatch.c within rsync.
+func TestMatchConformance(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		// Patterns without a slash match the final component anywhere.
+		{"*.o", "foo.o", false, true},
+		{"*.o", "dir/foo.o", false, true},
+		{"*.o", "foo.oo", false, false},
+		{"foo*", "foo", false, true},
+		{"*", "foo", false, true},
+
+		// Regexp metacharacters are not special.
+		{"*.txt", "atxt", false, false},
+		{"a+b", "a+b", false, true},
+		{"a+b", "aab", false, false},
+		{"a(b", "a(b", false, true},
+		{"^a$", "^a$", false, true},
+
+		// Leading slash anchors to top of transfer.
+		{"/foo", "foo", false, true},
+		{"/foo", "dir/foo", false, false},
+		{"/foo/bar", "foo/bar", false, true},
+		{"/foo/bar", "x/foo/bar", false, false},
+
+		// Trailing slash matches only directories.
+		{"foo/", "foo", true, true},
+		{"foo/", "foo", false, false},
+
+		// Other patterns with a slash match trailing components.
+		{"foo/bar", "foo/bar", false, true},
+		{"foo/bar", "x/foo/bar", false, true},
+		{"foo/bar", "xfoo/bar", false, false},
+		{"foo/*", "foo/a", false, true},
+		{"foo/*", "foo/a/b", false, false},
+		{"x/a*b", "x/a/b", false, false},
+		{"*/foo", "foo", false, false},
+		{"*/foo", "a/foo", false, true},
+
+		// "**" matches across slashes.
+		{"foo/**", "foo/a/b", false, true},
+		{"foo/**", "foo", true, false},
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/b", false, false},
+		{"foo**bar", "foo/x/bar", false, true},
+		{"**", "a/b/c", false, true},
+
+		// "dir/***" matches the directory and everything in it.
+		{"foo/***", "foo", true, true},
+		{"foo/***", "foo", false, false},
+		{"foo/***", "foo/a/b", false, true},
+		{"foo/***", "x/foo/a", false, true},
+
+		// "?" matches any one character other than a slash.
+		{"foo?", "foo1", false, true},
+		{"foo?", "foo", false, false},
+		{"a?b/c", "a/b/c", false, false},
+
+		// Character classes.
+		{"[abc].txt", "b.txt", false, true},
+		{"[abc].txt", "d.txt", false, false},
+		{"[!abc].txt", "d.txt", false, true},
+		{"[!abc].txt", "a.txt", false, false},
+		{"[^abc].txt", "d.txt", false, true},
+		{"[a-c]x", "bx", false, true},
+		{"[a-c]x", "dx", false, false},
+		{"[]]", "]", false, true},
+		{"[!]]", "a", false, true},
+		{"[a-]", "-", false, true},
+		{"[[:digit:]]*", "9lives", false, true},
+		{"[[:digit:]]*", "nine", false, false},
+		{"[[:upper:][:digit:]]", "Q", false, true},
+		{"[[:bogus:]]", "b", false, false},
+		{"[abc", "[abc", false, false},
+		{"a[!x]b/c", "a/b/c", false, false},
+
+		// Backslash escapes wildcards, but only in patterns with wildcards.
+		{`\*`, "*", false, true},
+		{`\*`, "x", false, false},
+		{`*\?`, "what?", false, true},
+		{`*\?`, "whatx", false, false},
+		{`foo\bar`, `foo\bar`, false, true},
+		{`foo\bar`, `foobar`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			entry := newFilterEntry(args.FilterRule{Action: args.FilterExclude, Pattern: tt.pattern}, "", "")
+
+			got := entry.matches("/"+tt.path, tt.path, tt.isDir)
+			if got != tt.expected {
+				t.Errorf("pattern %q, path %q: got %v, expected %v", tt.pattern, tt.path, got, tt.expected)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"runtime"
 
 	"github.com/release-engineering/exodus-rsync/internal/log"
+	"github.com/release-engineering/exodus-rsync/internal/progress"
 	"github.com/release-engineering/exodus-rsync/internal/syncutil"
 )
 
@@ -20,8 +21,75 @@ import (
 // If it returns an error, the walk process is stopped.
 type SyncItemHandler func(item SyncItem) error
 
+// Options control which items are discovered by Walk.
+type Options struct {
+	// Filter rules in rsync syntax, evaluated in order (see Filter).
+	Rules []string
+
+	// If true, rules which don't specify a side apply only to the sender,
+	// so excluded items aren't protected from deletion.
+	DeleteExcluded bool
+
+	// If true, files are excluded in the same way as CVS, as with rsync's
+	// --cvs-exclude. This adds rules after all of Rules.
+	CVSExclude bool
+
+	// If Listed is true, only the paths in OnlyThese within the source tree
+	// are walked, rather than the whole tree; if OnlyThese is empty, nothing
+	// is walked. Listed directories are walked only if Recursive is true,
+	// and are otherwise skipped.
+	Listed    bool
+	OnlyThese []string
+	Recursive bool
+
+	// If true, symlinks pointing within the source tree are preserved
+	// (see SyncItem.LinkTo) rather than followed.
+	Links bool
+
+	// Controls the handling of symlinks pointing outside of the source tree
+	// when Links is true: with CopyUnsafeLinks, such links are followed;
+	// with SafeLinks, they are ignored. Otherwise they are an error.
+	CopyUnsafeLinks bool
+	SafeLinks       bool
+
+	// If true, directories on a different filesystem from the root aren't
+	// walked.
+	OneFileSystem bool
+
+	// If non-nil, checksums are looked up in this cache before hashing any
+	// file, and stored in it after hashing.
+	Cache ChecksumCache
+
+	// If non-nil, files with several hard links are hashed only once, with
+	// the checksum kept here for any other links to the same inode.
+	Inodes *InodeSums
+
+	// Files larger than MaxSize (if non-zero) or smaller than MinSize, in
+	// bytes, are skipped.
+	MaxSize int64
+	MinSize int64
+}
+
+// ChecksumCache holds checksums of files calculated by previous walks.
+// It must be safe for concurrent use.
+type ChecksumCache interface {
+	// Lookup returns the checksum of the file at path, with the given info,
+	// and true if a checksum is available.
+	Lookup(path string, info fs.FileInfo) (string, bool)
+
+	// Store records the checksum of the file at path, with the given info.
+	Store(path string, info fs.FileInfo, sum string)
+}
+
+// SizeSkipped returns true if a file of the given size should be skipped
+// due to MaxSize or MinSize.
+func (o Options) SizeSkipped(size int64) bool {
+	return (o.MaxSize > 0 && size > o.MaxSize) || size < o.MinSize
+}
+
 type walkItem struct {
 	SrcPath string
+	LinkTo  string
 	Entry   fs.DirEntry
 	Error   error
 }
@@ -31,6 +99,11 @@ type SyncItem struct {
 	SrcPath string
 	Key     string
 	Info    fs.FileInfo
+
+	// If non-empty, this item is a link to the item at this path within the
+	// source tree and should be published as a link rather than a blob.
+	// Key is empty for links.
+	LinkTo string
 }
 
 type syncItemPrivate struct {
@@ -38,21 +111,62 @@ type syncItemPrivate struct {
 	Error error
 }
 
-func fileHash(path string, hasher hash.Hash) (string, error) {
+func fileHash(ctx context.Context, path string, hasher hash.Hash) (string, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 
-	if _, err := io.Copy(hasher, file); err != nil {
+	var reader io.Reader = file
+	var fileProgress *progress.File
+
+	if reporter := progress.FromContext(ctx); reporter != nil {
+		info, err := file.Stat()
+		if err != nil {
+			return "", err
+		}
+		fileProgress = reporter.Start(progress.Hashing, path, info.Size())
+		reader = fileProgress.Reader(file)
+	}
+
+	if _, err := io.Copy(hasher, reader); err != nil {
 		return "", err
 	}
 
+	fileProgress.Finish()
+
 	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
 
-func fillItem(ctx context.Context, c chan<- syncItemPrivate, w walkItem) error {
+// checksum returns the checksum of the file at path, using cache if non-nil.
+func checksum(ctx context.Context, path string, cache ChecksumCache) (string, error) {
+	if cache == nil {
+		return fileHash(ctx, path, sha256.New())
+	}
+
+	// Stat rather than using the walked entry, which may be a symlink.
+	// This happens before hashing so that any change to the file while
+	// it's being hashed will invalidate the cached checksum.
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if sum, ok := cache.Lookup(path, info); ok {
+		return sum, nil
+	}
+
+	sum, err := fileHash(ctx, path, sha256.New())
+	if err != nil {
+		return "", err
+	}
+
+	cache.Store(path, info, sum)
+	return sum, nil
+}
+
+func fillItem(ctx context.Context, c chan<- syncItemPrivate, w walkItem, opts Options) error {
 	logger := log.FromContext(ctx)
 
 	if w.Error != nil {
@@ -64,32 +178,53 @@ func fillItem(ctx context.Context, c chan<- syncItemPrivate, w walkItem) error {
 		return fmt.Errorf("get file info for %s: %w", w.SrcPath, err)
 	}
 
+	// A symlink being followed is represented by the file it points to.
+	if info.Mode()&fs.ModeSymlink != 0 && w.LinkTo == "" {
+		if info, err = os.Stat(w.SrcPath); err != nil {
+			return fmt.Errorf("get file info for %s: %w", w.SrcPath, err)
+		}
+	}
+
 	if info.Mode().IsDir() {
 		// Nothing to do
 		return nil
 	}
 
-	key, err := fileHash(w.SrcPath, sha256.New())
-	if err != nil {
-		return fmt.Errorf("checksum %s: %w", w.SrcPath, err)
+	// As in rsync, size limits don't apply to links.
+	if w.LinkTo == "" && opts.SizeSkipped(info.Size()) {
+		logger.F("path", w.SrcPath, "size", info.Size()).Debug("skipping; outside of --min-size/--max-size")
+		return nil
 	}
 
 	item := syncItemPrivate{
 		SyncItem{
 			SrcPath: w.SrcPath,
 			Info:    info,
-			Key:     key,
+			LinkTo:  w.LinkTo,
 		},
 		nil,
 	}
 
+	if w.LinkTo == "" {
+		item.Key, err = opts.Inodes.checksum(ctx, info, func() (string, error) {
+			return checksum(ctx, w.SrcPath, opts.Cache)
+		})
+		if err != nil {
+			return fmt.Errorf("checksum %s: %w", w.SrcPath, err)
+		}
+	}
+
 	logger.F("goroutines", runtime.NumGoroutine(), "item", item).Debug("send item")
 
-	c <- item
-	return nil
+	select {
+	case c <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func fillItems(ctx context.Context, in <-chan walkItem, c chan<- syncItemPrivate) {
+func fillItems(ctx context.Context, in <-chan walkItem, c chan<- syncItemPrivate, opts Options) {
 	logger := log.FromContext(ctx)
 
 	for {
@@ -105,29 +240,44 @@ func fillItems(ctx context.Context, in <-chan walkItem, c chan<- syncItemPrivate
 				return
 			}
 
-			if err := fillItem(ctx, c, item); err != nil {
-				c <- syncItemPrivate{Error: err}
+			if err := fillItem(ctx, c, item, opts); err != nil {
+				select {
+				case c <- syncItemPrivate{Error: err}:
+				case <-ctx.Done():
+				}
 			}
 		}
 	}
 }
 
-func getSyncItems(ctx context.Context, path string, exclude []string, include []string, onlyThese []string) <-chan syncItemPrivate {
+// getSyncItems returns a channel of items found at path. The channel is
+// closed only once every goroutine started here has exited.
+func getSyncItems(ctx context.Context, path string, opts Options) <-chan syncItemPrivate {
 	c := make(chan syncItemPrivate, 10)
 	walkItemCh := make(chan walkItem, 10)
+	walkDone := make(chan struct{})
 
 	go func() {
-		err := walkDirWithLinks(ctx, path, exclude, include, onlyThese,
-			func(path string, d fs.DirEntry, err error) error {
-				if err != nil {
-					return err
+		defer close(walkDone)
+
+		err := walkDirWithLinks(ctx, path, opts,
+			func(item walkItem) error {
+				if item.Error != nil {
+					return item.Error
+				}
+				select {
+				case walkItemCh <- item:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
 				}
-				walkItemCh <- walkItem{SrcPath: path, Entry: d}
-				return nil
 			})
 
 		if err != nil {
-			walkItemCh <- walkItem{Error: err}
+			select {
+			case walkItemCh <- walkItem{Error: err}:
+			case <-ctx.Done():
+			}
 		}
 
 		close(walkItemCh)
@@ -135,9 +285,11 @@ func getSyncItems(ctx context.Context, path string, exclude []string, include []
 
 	go syncutil.RunWithGroup(20,
 		func() {
-			fillItems(ctx, walkItemCh, c)
+			fillItems(ctx, walkItemCh, c, opts)
 		},
 		func() {
+			// Workers may stop early on cancel, before the walk does.
+			<-walkDone
 			close(c)
 		},
 	)
@@ -147,10 +299,21 @@ func getSyncItems(ctx context.Context, path string, exclude []string, include []
 
 // Walk will walk the directory tree at the given path and invoke a handler
 // for every discovered item eligible for sync.
-func Walk(ctx context.Context, path string, exclude []string, include []string, onlyThese []string, handler SyncItemHandler) error {
+func Walk(ctx context.Context, path string, opts Options, handler SyncItemHandler) error {
 	logger := log.FromContext(ctx)
 
-	for item := range getSyncItems(ctx, path, exclude, include, onlyThese) {
+	// If we return early, ensure all goroutines feeding us have stopped
+	// before returning, so that nothing is hashed, cached or logged after
+	// the walk.
+	ctx, cancel := context.WithCancel(ctx)
+	items := getSyncItems(ctx, path, opts)
+	defer func() {
+		cancel()
+		for range items {
+		}
+	}()
+
+	for item := range items {
 		logger.F("item", item).Debug("got item")
 
 		if ctx.Err() != nil {
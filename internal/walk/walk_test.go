@@ -2,9 +2,17 @@ package walk
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/apex/log/handlers/cli"
 	"github.com/release-engineering/exodus-rsync/internal/log"
@@ -34,7 +42,7 @@ func TestWalkEarlyCancel(t *testing.T) {
 		return nil
 	}
 
-	err := Walk(ctx, ".", []string{}, []string{}, []string{}, handler)
+	err := Walk(ctx, ".", Options{}, handler)
 
 	// It should have returned the cancelled error
 	if err != ctx.Err() {
@@ -61,7 +69,7 @@ func TestWalkCancelInProgress(t *testing.T) {
 		return nil
 	}
 
-	err := Walk(ctx, ".", []string{}, []string{}, []string{}, handler)
+	err := Walk(ctx, ".", Options{}, handler)
 
 	// It should have returned the cancelled error
 	if err != ctx.Err() {
@@ -80,7 +88,7 @@ func TestWalkHandlerError(t *testing.T) {
 		return fmt.Errorf("simulated error")
 	}
 
-	err := Walk(ctx, ".", []string{}, []string{}, []string{}, handler)
+	err := Walk(ctx, ".", Options{}, handler)
 
 	// It should have returned the error from handler
 	if err.Error() != "simulated error" {
@@ -88,94 +96,356 @@ func TestWalkHandlerError(t *testing.T) {
 	}
 }
 
-func TestWalkExcludeMatchError(t *testing.T) {
+// busyCache is a ChecksumCache which is slow to look up checksums, while
+// counting lookups in progress.
+type busyCache struct {
+	active int32
+	stored int32
+}
+
+func (b *busyCache) Lookup(path string, info fs.FileInfo) (string, bool) {
+	atomic.AddInt32(&b.active, 1)
+	defer atomic.AddInt32(&b.active, -1)
+
+	time.Sleep(5 * time.Millisecond)
+	return "", false
+}
+
+func (b *busyCache) Store(path string, info fs.FileInfo, sum string) {
+	atomic.AddInt32(&b.stored, 1)
+}
+
+func TestWalkHandlerErrorStopsWorkers(t *testing.T) {
 	ctx := context.Background()
 	logger := log.Logger{}
 	logger.Handler = cli.New(os.Stdout)
 
 	ctx = log.NewContext(ctx, &logger)
 
-	handler := func(item SyncItem) error {
-		return nil
+	root := t.TempDir()
+	for i := 0; i < 100; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprint(i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	err := Walk(ctx, ".", []string{"a(b"}, []string{}, []string{}, handler)
+	cache := &busyCache{}
+	err := Walk(ctx, root, Options{Cache: cache}, func(item SyncItem) error {
+		return fmt.Errorf("simulated error")
+	})
+	if err == nil || err.Error() != "simulated error" {
+		t.Errorf("returned unexpected error %v", err)
+	}
 
-	// It should have caused a regexp error
-	msg := "could not process --exclude `a(b`: error parsing regexp: missing closing ): `a(b`"
-	if err.Error() != msg {
-		t.Errorf("unexpected success")
+	// Nothing should still be running once Walk has returned.
+	stored := atomic.LoadInt32(&cache.stored)
+	if active := atomic.LoadInt32(&cache.active); active != 0 {
+		t.Errorf("%v lookups still in progress after Walk returned", active)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := atomic.LoadInt32(&cache.stored); got != stored {
+		t.Errorf("checksums stored after Walk returned: %v, then %v", stored, got)
 	}
 }
 
-func TestWalkIncludeMatchError(t *testing.T) {
+func TestWalkLinksLoop(t *testing.T) {
+	tests := []struct {
+		name  string
+		links map[string]string
+		opts  Options
+	}{
+		{"link to parent", map[string]string{"a/loop": ".."}, Options{}},
+		{"link to root", map[string]string{"a/loop": "../../root"}, Options{}},
+		{"indirect loop", map[string]string{"a/l1": "../b", "b/l2": "../a"}, Options{}},
+		{"loop with links preserved", map[string]string{"a/loop": ".."}, Options{Links: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			logger := log.Logger{}
+			logger.Handler = cli.New(os.Stdout)
+			ctx = log.NewContext(ctx, &logger)
+
+			root := filepath.Join(t.TempDir(), "root")
+			for _, dir := range []string{"a", "b"} {
+				if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for src, target := range tt.links {
+				if err := os.Symlink(target, filepath.Join(root, src)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := Walk(ctx, root, tt.opts, func(item SyncItem) error { return nil })
+
+			// It should stop rather than walking forever.
+			if err == nil || !strings.Contains(err.Error(), "symlink loop detected") {
+				t.Errorf("did not get expected error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWalkLinksPreserved(t *testing.T) {
 	ctx := context.Background()
 	logger := log.Logger{}
 	logger.Handler = cli.New(os.Stdout)
-
 	ctx = log.NewContext(ctx, &logger)
 
-	handler := func(item SyncItem) error {
-		return nil
+	// Simulate a typical "latest => version" layout.
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.MkdirAll(filepath.Join(root, "9.2/os"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "9.2/os/repomd.xml"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("9.2", filepath.Join(root, "latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative paths are relative to the parent of root.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Chdir(filepath.Dir(root)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"absolute", root},
+		{"relative", "root/"},
 	}
 
-	err := Walk(ctx, ".", []string{"*"}, []string{"a(b"}, []string{}, handler)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			err := Walk(ctx, tt.src, Options{Links: true}, func(item SyncItem) error {
+				rel, _ := filepath.Rel(tt.src, item.SrcPath)
+				if item.LinkTo != "" {
+					target, _ := filepath.Rel(tt.src, item.LinkTo)
+					got[rel] = "-> " + target
+				} else {
+					got[rel] = item.Key
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	// It should have caused a regexp error
-	msg := "could not process --include `a(b`: error parsing regexp: missing closing ): `a(b`"
-	if err.Error() != msg {
-		t.Errorf("unexpected success")
+			expected := map[string]string{
+				"9.2/os/repomd.xml":    "8f434346648f6b96df89dda901c5176b10a6d83961dd3c1ac88b59b2dc327aa4",
+				"latest/os/repomd.xml": "-> 9.2/os/repomd.xml",
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("unexpected items: %v", got)
+			}
+		})
 	}
 }
 
-func TestWalkLinksMatchPattern(t *testing.T) {
+func TestWalkSizeLimits(t *testing.T) {
+	ctx := context.Background()
+	logger := log.Logger{}
+	logger.Handler = cli.New(os.Stdout)
+	ctx = log.NewContext(ctx, &logger)
+
+	root := t.TempDir()
+	for name, size := range map[string]int{"empty": 0, "small": 10, "medium": 100, "large": 1000} {
+		content := []byte(strings.Repeat("x", size))
+		if err := os.WriteFile(filepath.Join(root, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("large", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
-		path    string
-		pattern string
+		name     string
+		opts     Options
+		expected []string
 	}{
-		{"/foo/bar/baz", "."},
-		{"/foo/bar", "/foo"},
-		{"foo/bar", "bar/"},
-		{"foo/bar", `foo/***`},
-		{"test.txt", `.txt`},
-		{"test?.txt", `\?.txt`},
-		{"foo/bars", `bar?`},
-		{"foo/bar/baz/buzz/bats.oog", `foo/**/bats.oog`},
-		{"foo/4/baz", `foo/[0-9]/baz`},
-		{"foo/bar/baz", `foo/[a-z]+/baz`},
+		{"no limits", Options{}, []string{"empty", "large", "link", "medium", "small"}},
+		{"max size", Options{MaxSize: 100}, []string{"empty", "medium", "small"}},
+		{"min size", Options{MinSize: 1}, []string{"large", "link", "medium", "small"}},
+		{"both", Options{MinSize: 10, MaxSize: 99}, []string{"small"}},
+
+		// Links are not subject to limits, unlike the files they point to.
+		{"links", Options{Links: true, MaxSize: 100}, []string{"empty", "link", "medium", "small"}},
 	}
+
 	for _, tt := range tests {
-		t.Run(tt.path, func(t *testing.T) {
-			matched, _ := matchPattern(tt.path, tt.pattern)
-			if matched == false {
-				t.Errorf("'%s' did not match, '%s", tt.pattern, tt.path)
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			err := Walk(ctx, root, tt.opts, func(item SyncItem) error {
+				got = append(got, filepath.Base(item.SrcPath))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unexpected items: %v", got)
 			}
 		})
 	}
 }
 
-func TestWalkLinksFilterPathAll(t *testing.T) {
+func TestWalkOnlyThese(t *testing.T) {
 	ctx := context.Background()
 	logger := log.Logger{}
 	logger.Handler = cli.New(os.Stdout)
-
 	ctx = log.NewContext(ctx, &logger)
 
-	err := filterPath(log.FromContext(ctx), "/some/dir", []string{"*"}, []string{}, true)
-	if err != nil && err.Error() != "filtered '/some/dir'" {
-		t.Errorf("failed to filter '/some/dir' for exclude pattern `*`")
+	root := t.TempDir()
+	for _, name := range []string{"a/1", "a/b/2", "c/3", "d"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "c/.exclude"), []byte("- 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		listed    []string
+		recursive bool
+		rules     []string
+		expected  []string
+	}{
+		{"empty", nil, true, nil, []string{}},
+		{"files", []string{"d", "a/b/2", "d"}, false, nil, []string{"a/b/2", "d"}},
+		{"dirs skipped", []string{"a", "link", "d"}, false, nil, []string{"d"}},
+		{"dirs recursive", []string{"a", "link/b", "d"}, true, nil, []string{"a/1", "a/b/2", "d", "link/b/2"}},
+		{"nested", []string{"a/b/2", "a"}, true, nil, []string{"a/1", "a/b/2"}},
+		{"root", []string{"."}, true, []string{"- .exclude", "- link"}, []string{"a/1", "a/b/2", "c/3", "d"}},
+		{"filtered", []string{"a/1", "d"}, false, []string{"- d"}, []string{"a/1"}},
+		{"dir-merge", []string{"c/3", "d"}, false, []string{"dir-merge .exclude"}, []string{"d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Listed: true, Recursive: tt.recursive, Rules: tt.rules}
+			for _, name := range tt.listed {
+				opts.OnlyThese = append(opts.OnlyThese, filepath.Join(root, name))
+			}
+
+			got := []string{}
+			err := Walk(ctx, root, opts, func(item SyncItem) error {
+				rel, _ := filepath.Rel(root, item.SrcPath)
+				got = append(got, rel)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unexpected items: %v", got)
+			}
+		})
+	}
+
+	// A listed path which doesn't exist is an error.
+	opts := Options{Listed: true, OnlyThese: []string{filepath.Join(root, "d"), filepath.Join(root, "missing")}}
+	err := Walk(ctx, root, opts, func(item SyncItem) error { return nil })
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
 
-func TestWalkLinksFilterPathInclude(t *testing.T) {
+func TestWalkHardLinks(t *testing.T) {
 	ctx := context.Background()
 	logger := log.Logger{}
 	logger.Handler = cli.New(os.Stdout)
+	ctx = log.NewContext(ctx, &logger)
 
+	root := t.TempDir()
+	for name, content := range map[string]string{"a": "same", "c": "other"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(root, "a"), filepath.Join(root, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache records each file actually hashed.
+	cache := &fakeCache{sums: map[string]string{}, stored: map[string]string{}}
+
+	got := map[string]string{}
+	opts := Options{Cache: cache, Inodes: NewInodeSums()}
+	err := Walk(ctx, root, opts, func(item SyncItem) error {
+		got[filepath.Base(item.SrcPath)] = item.Key
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every link should be published with the same checksum...
+	if len(got) != 3 || got["a"] != got["b"] || got["a"] == got["c"] {
+		t.Errorf("unexpected items: %v", got)
+	}
+
+	// ...but only one of them should have been hashed.
+	if len(cache.stored) != 2 {
+		t.Errorf("unexpected files hashed: %v", cache.stored)
+	}
+}
+
+func TestWalkOneFileSystem(t *testing.T) {
+	ctx := context.Background()
+	logger := log.Logger{}
+	logger.Handler = cli.New(os.Stdout)
 	ctx = log.NewContext(ctx, &logger)
 
-	err := filterPath(log.FromContext(ctx), "/some/dir", []string{"*"}, []string{"*/", "**/dir"}, true)
+	root := t.TempDir()
+
+	procInfo, err := os.Stat("/proc")
+	rootInfo, _ := os.Stat(root)
+	if err != nil || statOf(procInfo).Dev == statOf(rootInfo).Dev {
+		t.Skip("needs /proc on a separate filesystem")
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "file"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/proc", filepath.Join(root, "proc")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	err = Walk(ctx, root, Options{OneFileSystem: true}, func(item SyncItem) error {
+		got = append(got, filepath.Base(item.SrcPath))
+		return nil
+	})
 	if err != nil {
-		t.Errorf("unexpected error `%s`", err)
+		t.Fatal(err)
+	}
+
+	// Nothing from /proc should have been walked.
+	if !reflect.DeepEqual(got, []string{"file"}) {
+		t.Errorf("unexpected items: %v", got)
 	}
 }
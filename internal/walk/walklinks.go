@@ -6,9 +6,9 @@ import (
 	fs "io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	"github.com/release-engineering/exodus-rsync/internal/args"
 	"github.com/release-engineering/exodus-rsync/internal/log"
 )
 
@@ -21,145 +21,206 @@ func pathRewriter(src string, dest string, fn fs.WalkDirFunc) fs.WalkDirFunc {
 	}
 }
 
-// Determines if path matches pattern, striving for parity with rsync,
-// ("Include/Exclude Pattern Rules", https://linux.die.net/man/1/rsync).
-func matchPattern(path string, pattern string) (bool, error) {
-	if strings.ContainsAny(pattern, "*?") {
-		converted := ""
-		chars := []rune(pattern)
-		for i := 0; i < len(chars); i++ {
-			char := string(chars[i])
-			switch char {
-			case `\`:
-				i++
-				converted += (char + string(chars[i]))
-			case `*`:
-				converted += `[^/]+`
-			case `?`:
-				converted += `[^/]`
-			default:
-				converted += char
-			}
-		}
-		pattern = strings.Replace(converted, `[^/]+[^/]+`, `.*`, -1)
-	}
+// Returns true if path is equal to or located beneath dir.
+func isWithin(dir string, path string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
 
-	if strings.HasPrefix(pattern, "/") {
-		pattern = `^` + pattern
+// Like filepath.WalkDir but resolves symlinks to directories.
+//
+// If opts.Links is true, symlinks pointing within root are instead passed
+// to fn with LinkTo set to the (unresolved) path of their target. Symlinks
+// to directories are expanded into a link per file within the directory.
+//
+// Symlink loops, where following a link would lead back to a directory
+// already being walked, are reported as an error.
+func walkDirWithLinks(ctx context.Context, root string, opts Options, fn func(walkItem) error) error {
+	logger := log.FromContext(ctx)
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err == nil {
+		resolvedRoot, err = filepath.Abs(resolvedRoot)
 	}
-	if strings.HasSuffix(pattern, "/") {
-		pattern = strings.TrimRight(pattern, "/") + `\z`
+	if err != nil {
+		return fn(walkItem{SrcPath: root, Error: err})
 	}
 
-	re, err := regexp.Compile(pattern)
+	filter, err := NewFilter(root, opts)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	return re.MatchString(path), nil
-}
-
-func contains(s []string, str string) bool {
-	for _, v := range s {
-		if v == str {
-			return true
+	// With OneFileSystem, the device of every directory is compared with
+	// that of the root.
+	var rootDev uint64
+	if opts.OneFileSystem {
+		info, err := os.Stat(root)
+		if err != nil {
+			return fn(walkItem{SrcPath: root, Error: err})
+		}
+		if st := statOf(info); st != nil {
+			rootDev = uint64(st.Dev)
 		}
 	}
-	return false
-}
+	otherDevice := func(d fs.DirEntry) bool {
+		if !opts.OneFileSystem {
+			return false
+		}
+		info, err := d.Info()
+		if err != nil {
+			return false
+		}
+		st := statOf(info)
+		return st != nil && uint64(st.Dev) != rootDev
+	}
 
-func filterPath(logger *log.Logger, path string, exclude []string, include []string, isDir bool) error {
-	filtErr := fmt.Errorf("filtered '%s'", path)
+	// Returns the path within the source tree of a resolved path which is
+	// known to be located within the tree.
+	treePath := func(resolved string) string {
+		rel, _ := filepath.Rel(resolvedRoot, resolved)
+		return filepath.Join(root, rel)
+	}
 
-	for _, ex := range exclude {
-		isExcluded, matchErr := matchPattern(path, ex)
-		if matchErr != nil {
-			return fmt.Errorf("could not process --exclude `%s`: %w", ex, matchErr)
+	var walker func(stack []string, linked bool) fs.WalkDirFunc
+
+	// Walks the directory at resolved, which is reached via a link at path.
+	//
+	// stack holds every resolved directory currently being walked, either
+	// the root or via links. If resolved contains any of them, walking it
+	// would lead back to this link again, forever.
+	walkLinkedDir := func(path string, resolved string, stack []string, linked bool) error {
+		for _, dir := range stack {
+			if isWithin(resolved, dir) {
+				return fn(walkItem{
+					SrcPath: path,
+					Error:   fmt.Errorf("symlink loop detected: %s points to %s", path, resolved),
+				})
+			}
 		}
 
-		if isExcluded {
-			for _, in := range include {
-				if in == "*/" {
-					// Automatically include dirs, do not apply pattern otherwise.
-					if isDir {
-						return nil
-					}
-					continue
-				}
+		logger.F("path", resolved).Debug("walking dir via link")
+
+		// We need to call WalkDir on the target of the symlink, but we want
+		// the callback function to receive the pre-resolution paths, so we
+		// rewrite on the fly.
+		stack = append(stack[:len(stack):len(stack)], resolved)
+		thisWalker := pathRewriter(resolved, path, walker(stack, linked))
+		return filepath.WalkDir(resolved, thisWalker)
+	}
+
+	// Returns a walk function. linked is true when walking a directory reached
+	// via a preserved link, in which case every file is published as a link.
+	walker = func(stack []string, linked bool) fs.WalkDirFunc {
+		return func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 
-				isIncluded, err := matchPattern(path, in)
-				if err != nil {
-					return fmt.Errorf("could not process --include `%s`: %w", in, err)
+			if d != nil {
+				isDir := d.IsDir()
+				if d.Type()&fs.ModeSymlink != 0 && !opts.Links {
+					// A link which will be followed is filtered as its target.
+					if info, err := os.Stat(path); err == nil {
+						isDir = info.IsDir()
+					}
 				}
 
-				if isIncluded {
-					logger.F("path", path, "include", in).Debug("path included")
+				if filter.excluded(path, isDir, args.FilterSender) {
+					logger.F("path", path).Debug("path excluded")
+					if d.IsDir() {
+						return fs.SkipDir
+					}
 					return nil
 				}
-			}
-
-			logger.F("path", path, "exclude", ex).Debug("path excluded")
-			return filtErr
-		}
-	}
-	return nil
-}
 
-// Like filepath.WalkDir but resolves symlinks to directories.
-func walkDirWithLinks(ctx context.Context, root string, exclude []string, include []string, onlyThese []string, fn fs.WalkDirFunc) error {
-	logger := log.FromContext(ctx)
+				if d.IsDir() && otherDevice(d) {
+					logger.F("path", path).Debug("skipping; on another filesystem (--one-file-system)")
+					return fs.SkipDir
+				}
 
-	var walkFunc fs.WalkDirFunc
+				if d.IsDir() {
+					if err := filter.enterDir(path); err != nil {
+						return err
+					}
+				}
+			}
 
-	walkFunc = func(path string, d fs.DirEntry, err error) error {
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
+			if err != nil {
+				return fn(walkItem{SrcPath: path, Entry: d, Error: err})
+			}
 
-		if len(onlyThese) > 0 && !contains(onlyThese, path) {
-			logger.F("path", path).Debug("skipping; not included in --files-from file")
-			return nil
-		}
+			if d.Type()&fs.ModeSymlink == 0 {
+				if linked && !d.IsDir() {
+					// A file within a linked directory; publish as a link to
+					// the same file at its real location.
+					resolved, err := filepath.EvalSymlinks(path)
+					if err == nil {
+						resolved, err = filepath.Abs(resolved)
+					}
+					if err != nil {
+						return fn(walkItem{SrcPath: path, Error: fmt.Errorf("resolving link %s: %w", path, err)})
+					}
+					return fn(walkItem{SrcPath: path, LinkTo: treePath(resolved), Entry: d})
+				}
 
-		filterErr := filterPath(logger, path, exclude, include, d.IsDir())
-		if filterErr != nil {
-			if strings.Contains(filterErr.Error(), fmt.Sprintf("filtered '%s'", path)) {
-				return nil
+				// Not a symlink, just call the real handler.
+				return fn(walkItem{SrcPath: path, Entry: d})
 			}
-			return filterErr
-		}
-
-		if err != nil {
-			return fn(path, d, err)
-		}
 
-		if d.Type()&fs.ModeSymlink != 0 {
 			var info fs.FileInfo
 
 			resolved, err := filepath.EvalSymlinks(path)
+			if err == nil {
+				resolved, err = filepath.Abs(resolved)
+			}
 			if err == nil {
 				info, err = os.Stat(resolved)
 			}
 
 			if err != nil {
-				return fn(path, d, fmt.Errorf("resolving link %s: %w", path, err))
+				return fn(walkItem{SrcPath: path, Error: fmt.Errorf("resolving link %s: %w", path, err)})
+			}
+
+			// The root itself is always followed, as there's nothing it could
+			// be a link to within the tree.
+			if opts.Links && path != root {
+				if isWithin(resolvedRoot, resolved) {
+					if info.IsDir() {
+						return walkLinkedDir(path, resolved, stack, true)
+					}
+					return fn(walkItem{SrcPath: path, LinkTo: treePath(resolved), Entry: d})
+				}
+
+				if opts.SafeLinks {
+					logger.F("path", path, "target", resolved).Info("ignoring unsafe symlink")
+					return nil
+				}
+
+				if !opts.CopyUnsafeLinks {
+					return fn(walkItem{SrcPath: path, Error: fmt.Errorf(
+						"symlink %s points outside of source tree "+
+							"(use --copy-unsafe-links or --safe-links)", path)})
+				}
+
+				// Unsafe link is followed, then anything beneath it is copied
+				// rather than linked.
+				if info.IsDir() {
+					return walkLinkedDir(path, resolved, stack, false)
+				}
 			}
 
 			if info.IsDir() {
-				// Walk this entire directory too.
-				logger.F("path", resolved).Debug("walking dir via link")
-
-				// We need to call WalkDir on the target of the symlink, but we want
-				// the callback function to receive the pre-resolution paths, so we
-				// rewrite on the fly.
-				thisWalker := pathRewriter(resolved, path, walkFunc)
-				return filepath.WalkDir(resolved, thisWalker)
+				return walkLinkedDir(path, resolved, stack, linked)
 			}
+
+			// We are not looking at a symlink-to-dir, just call the real handler.
+			return fn(walkItem{SrcPath: path, Entry: d})
 		}
+	}
 
-		// We are not looking at a symlink-to-dir, just call the real handler.
-		return fn(path, d, err)
+	if opts.Listed {
+		return walkListed(ctx, root, opts, filter, walker([]string{resolvedRoot}, false))
 	}
 
-	return filepath.WalkDir(root, walkFunc)
+	return filepath.WalkDir(root, walker([]string{resolvedRoot}, false))
 }